@@ -0,0 +1,172 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package autograd
+
+import (
+	"fmt"
+	"github.com/kuroko1t/gmat"
+	"math"
+	"testing"
+)
+
+func ExpNearCheck(zReal, zExp []float64, tol float64, t *testing.T) {
+	success := len(zReal) == len(zExp)
+	for i := range zExp {
+		if success && math.Abs(zExp[i]-zReal[i]) > tol*math.Max(1, math.Abs(zExp[i])) {
+			success = false
+		}
+	}
+	if !success {
+		fmt.Println("Real:", zReal)
+		fmt.Println("Exp:", zExp)
+		t.Fatal("failed Test!")
+	}
+}
+
+// numGrad differentiates the scalar f with respect to leaf by central
+// differences.
+func numGrad(f func() *Variable, leaf *Variable) []float64 {
+	value := leaf.Value
+	data := gmat.ToSlice(value)
+	grad := make([]float64, len(data))
+	eps := 1e-6
+	for i := range data {
+		orig := data[i]
+		data[i] = orig + eps
+		leaf.Value = gmat.FromSlice(data, value.Shape...)
+		plus := gmat.ToSlice(f().Value)[0]
+		data[i] = orig - eps
+		leaf.Value = gmat.FromSlice(data, value.Shape...)
+		minus := gmat.ToSlice(f().Value)[0]
+		data[i] = orig
+		grad[i] = (plus - minus) / (2 * eps)
+	}
+	leaf.Value = value
+	return grad
+}
+
+func checkGrads(f func() *Variable, leaves []*Variable, t *testing.T) {
+	for _, leaf := range leaves {
+		leaf.ZeroGrad()
+	}
+	f().Backward()
+	for _, leaf := range leaves {
+		ExpNearCheck(gmat.ToSlice(leaf.Grad), numGrad(f, leaf), 1e-5, t)
+	}
+}
+
+func TestBackwardSuccess(t *testing.T) {
+	x := Constant(gmat.Normal(0, 1, 4, 3))
+	w1 := NewVariable(gmat.Normal(0, 1, 3, 5), true)
+	w2 := NewVariable(gmat.Normal(0, 1, 5, 2), true)
+	b := NewVariable(gmat.Normal(0, 1, 1, 2), true)
+	f := func() *Variable {
+		h := Tanh(Dot(x, w1))
+		return SparseSoftmaxCrossEntropy(AddBias(Dot(h, w2), b), []int{0, 1, 1, 0})
+	}
+	checkGrads(f, []*Variable{w1, w2, b}, t)
+	if x.HasGrad() {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestElementwiseSuccess(t *testing.T) {
+	a := NewVariable(gmat.Normal(0, 1, 2, 3), true)
+	b := NewVariable(gmat.Uniform(1, 2, 2, 3), true)
+	f := func() *Variable {
+		z := Div(Mul(Sub(a, b), a), AddE(b, 3))
+		z = Add(z, MulE(Softmax(a, 0), 2))
+		z = Mul(z, LogSoftmax(Neg(b), 1))
+		z = Reshape(Permute(z, []int{1, 0}), []int{1, 6})
+		r := Sum(Mul(SumRow(Reshape(z, []int{3, 2})), Constant(gmat.FromSlice([]float64{1, -2}, 1, 2))))
+		c := Mean(Sigmoid(SumCol(T(a))))
+		return Add(Add(Sum(z), r), c)
+	}
+	checkGrads(f, []*Variable{a, b}, t)
+}
+
+func TestApplySuccess(t *testing.T) {
+	x := NewVariable(gmat.Normal(0, 1, 3, 2), true)
+	f := func() *Variable {
+		return Sum(Apply(x, math.Sin, math.Cos))
+	}
+	checkGrads(f, []*Variable{x}, t)
+}
+
+func TestConvSuccess(t *testing.T) {
+	x := NewVariable(gmat.Normal(0, 1, 2, 2, 6, 6), true)
+	w := NewVariable(gmat.Normal(0, 1, 3, 2, 3, 3), true)
+	b := NewVariable(gmat.Normal(0, 1, 1, 3), true)
+	weight := Constant(gmat.Normal(0, 1, 2, 3, 3, 3))
+	f := func() *Variable {
+		z := Conv2D(x, w, b, gmat.ConvParam{Padding: 1})
+		return Sum(Mul(MaxPool2D(Softplus(z), 2, 2, gmat.ConvParam{}), weight))
+	}
+	checkGrads(f, []*Variable{x, w, b}, t)
+
+	x1 := NewVariable(gmat.Normal(0, 1, 2, 2, 7), true)
+	w1 := NewVariable(gmat.Normal(0, 1, 2, 1, 3), true)
+	f = func() *Variable {
+		z := Conv1D(x1, w1, nil, gmat.ConvParam{Stride: 2, Groups: 2})
+		return Sum(Mul(z, z))
+	}
+	checkGrads(f, []*Variable{x1, w1}, t)
+}
+
+func TestAccumulateSuccess(t *testing.T) {
+	x := NewVariable(gmat.FromSlice([]float64{1, 2, 3}, 1, 3), true)
+	// x is used twice, so both paths add up
+	Sum(Mul(x, x)).Backward()
+	ExpNearCheck(gmat.ToSlice(x.Grad), []float64{2, 4, 6}, 0, t)
+	Sum(x).Backward()
+	ExpNearCheck(gmat.ToSlice(x.Grad), []float64{3, 5, 7}, 0, t)
+	x.ZeroGrad()
+	Sum(x).Backward()
+	ExpNearCheck(gmat.ToSlice(x.Grad), []float64{1, 1, 1}, 0, t)
+}
+
+func TestNoGradSuccess(t *testing.T) {
+	x := NewVariable(gmat.FromSlice([]float64{1, 2, 3}, 1, 3), true)
+	var z, other *Variable
+	NoGrad(func() {
+		z = Sum(Mul(x, x))
+		// a goroutine started in the scope records as usual
+		done := make(chan bool)
+		go func() {
+			other = Sum(x)
+			done <- true
+		}()
+		<-done
+	})
+	if z.RequiresGrad || !z.IsLeaf() || !other.RequiresGrad || !GradEnabled() {
+		t.Fatal("failed Test!")
+	}
+	z.Backward()
+	if x.HasGrad() || Sum(x.Detach()).RequiresGrad {
+		t.Fatal("failed Test!")
+	}
+	// a panic in the scope restores recording
+	func() {
+		defer func() { recover() }()
+		NoGrad(func() { panic("stop") })
+	}()
+	if !GradEnabled() || !Sum(x).RequiresGrad {
+		t.Fatal("failed Test!")
+	}
+}
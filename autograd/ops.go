@@ -0,0 +1,225 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package autograd
+
+import (
+	"github.com/kuroko1t/gmat"
+	"log"
+)
+
+func grads(g ...gmat.Tensor) []gmat.Tensor {
+	return g
+}
+
+// scalar reads the value of a [1, 1] gradient.
+func scalar(g gmat.Tensor) float64 {
+	return gmat.ToSlice(g)[0]
+}
+
+func Add(x, y *Variable) *Variable {
+	return record(gmat.Add(x.Value, y.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(g, g)
+	}, x, y)
+}
+
+func Sub(x, y *Variable) *Variable {
+	return record(gmat.Sub(x.Value, y.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(g, gmat.MulE(g, -1))
+	}, x, y)
+}
+
+func Mul(x, y *Variable) *Variable {
+	return record(gmat.Mul(x.Value, y.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Mul(g, y.Value), gmat.Mul(g, x.Value))
+	}, x, y)
+}
+
+func Div(x, y *Variable) *Variable {
+	z := gmat.Div(x.Value, y.Value)
+	return record(z, func(g gmat.Tensor) []gmat.Tensor {
+		gx := gmat.Div(g, y.Value)
+		return grads(gx, gmat.MulE(gmat.Mul(gx, z), -1))
+	}, x, y)
+}
+
+func MulE(x *Variable, c float64) *Variable {
+	return record(gmat.MulE(x.Value, c), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.MulE(g, c))
+	}, x)
+}
+
+func AddE(x *Variable, c float64) *Variable {
+	return record(gmat.AxpyE(x.Value, 1, c), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(g)
+	}, x)
+}
+
+func Neg(x *Variable) *Variable {
+	return MulE(x, -1)
+}
+
+func Dot(x, y *Variable) *Variable {
+	return record(gmat.Dot(x.Value, y.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Dot(g, gmat.T(y.Value)), gmat.Dot(gmat.T(x.Value), g))
+	}, x, y)
+}
+
+// AddBias adds the row vector b [1, n] to every row of x [m, n].
+func AddBias(x, b *Variable) *Variable {
+	if b.Value.Shape[0] != 1 || b.Value.Shape[1] != x.Value.Shape[1] {
+		log.Fatal("AddBias.mismatch bias shape")
+	}
+	z := gmat.Add(x.Value, gmat.Cast(b.Value, x.Value.Shape[0]))
+	return record(z, func(g gmat.Tensor) []gmat.Tensor {
+		return grads(g, gmat.SumRow(g))
+	}, x, b)
+}
+
+func T(x *Variable) *Variable {
+	return record(gmat.T(x.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.T(g))
+	}, x)
+}
+
+func Reshape(x *Variable, shape []int) *Variable {
+	return record(gmat.Reshape(x.Value, shape), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Reshape(g, x.Value.Shape))
+	}, x)
+}
+
+func Permute(x *Variable, axes []int) *Variable {
+	inverse := make([]int, len(axes))
+	for i, a := range axes {
+		inverse[a] = i
+	}
+	return record(gmat.Permute(x.Value, axes), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Permute(g, inverse))
+	}, x)
+}
+
+// Sum reduces all elements to a [1, 1] variable.
+func Sum(x *Variable) *Variable {
+	return record(gmat.Full(gmat.Sum(x.Value), 1, 1), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Full(scalar(g), x.Value.Shape...))
+	}, x)
+}
+
+func Mean(x *Variable) *Variable {
+	size := 1
+	for _, s := range x.Value.Shape {
+		size *= s
+	}
+	return MulE(Sum(x), 1/float64(size))
+}
+
+// SumRow sums the rows of x [m, n] into [1, n].
+func SumRow(x *Variable) *Variable {
+	return record(gmat.SumRow(x.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Cast(g, x.Value.Shape[0]))
+	}, x)
+}
+
+// SumCol sums the columns of x [m, n] into [m, 1].
+func SumCol(x *Variable) *Variable {
+	return record(gmat.SumCol(x.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Cast(g, x.Value.Shape[1]))
+	}, x)
+}
+
+// Activation applies f elementwise; df evaluates its derivative at the
+// input.
+func Activation(x *Variable, f, df func(gmat.Tensor) gmat.Tensor) *Variable {
+	return record(f(x.Value), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Mul(g, df(x.Value)))
+	}, x)
+}
+
+func ReLU(x *Variable) *Variable {
+	return Activation(x, gmat.ReLU, gmat.ReLUDerivative)
+}
+
+func LeakyReLU(x *Variable, alpha float64) *Variable {
+	return Activation(x, func(x gmat.Tensor) gmat.Tensor {
+		return gmat.LeakyReLU(x, alpha)
+	}, func(x gmat.Tensor) gmat.Tensor {
+		return gmat.LeakyReLUDerivative(x, alpha)
+	})
+}
+
+func ELU(x *Variable, alpha float64) *Variable {
+	return Activation(x, func(x gmat.Tensor) gmat.Tensor {
+		return gmat.ELU(x, alpha)
+	}, func(x gmat.Tensor) gmat.Tensor {
+		return gmat.ELUDerivative(x, alpha)
+	})
+}
+
+func GELU(x *Variable) *Variable {
+	return Activation(x, gmat.GELU, gmat.GELUDerivative)
+}
+
+func SiLU(x *Variable) *Variable {
+	return Activation(x, gmat.SiLU, gmat.SiLUDerivative)
+}
+
+func Sigmoid(x *Variable) *Variable {
+	return Activation(x, gmat.Sigmoid, gmat.SigmoidDerivative)
+}
+
+func Tanh(x *Variable) *Variable {
+	return Activation(x, gmat.Tanh, gmat.TanhDerivative)
+}
+
+func Softplus(x *Variable) *Variable {
+	return Activation(x, gmat.Softplus, gmat.SoftplusDerivative)
+}
+
+func HardSigmoid(x *Variable) *Variable {
+	return Activation(x, gmat.HardSigmoid, gmat.HardSigmoidDerivative)
+}
+
+func Mish(x *Variable) *Variable {
+	return Activation(x, gmat.Mish, gmat.MishDerivative)
+}
+
+func Softmax(x *Variable, axis int) *Variable {
+	y := gmat.Softmax(x.Value, axis)
+	return record(y, func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.SoftmaxBackward(y, g, axis))
+	}, x)
+}
+
+func LogSoftmax(x *Variable, axis int) *Variable {
+	y := gmat.LogSoftmax(x.Value, axis)
+	return record(y, func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.LogSoftmaxBackward(y, g, axis))
+	}, x)
+}
+
+// SoftmaxCrossEntropy returns the mean loss as a [1, 1] variable.
+func SoftmaxCrossEntropy(logits *Variable, target gmat.Tensor) *Variable {
+	loss, grad := gmat.SoftmaxCrossEntropy(logits.Value, target)
+	return record(gmat.Full(loss, 1, 1), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.MulE(grad, scalar(g)))
+	}, logits)
+}
+
+func SparseSoftmaxCrossEntropy(logits *Variable, labels []int) *Variable {
+	loss, grad := gmat.SparseSoftmaxCrossEntropy(logits.Value, labels)
+	return record(gmat.Full(loss, 1, 1), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.MulE(grad, scalar(g)))
+	}, logits)
+}
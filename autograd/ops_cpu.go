@@ -0,0 +1,77 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package autograd
+
+import (
+	"github.com/kuroko1t/gmat"
+)
+
+// Apply maps fn elementwise; dfn is its derivative.
+func Apply(x *Variable, fn, dfn func(float64) float64) *Variable {
+	return record(gmat.Apply(x.Value, fn), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.Mul(g, gmat.Apply(x.Value, dfn)))
+	}, x)
+}
+
+// Conv1D convolves x [N, C_in, L] with w [C_out, C_in/groups, K]. b may
+// be nil.
+func Conv1D(x, w, b *Variable, param gmat.ConvParam) *Variable {
+	var bias gmat.Tensor
+	if b != nil {
+		bias = b.Value
+	}
+	_, _, length := gmat.Shape3D(x.Value)
+	_, _, k := gmat.Shape3D(w.Value)
+	return record(gmat.Conv1D(x.Value, w.Value, bias, param), func(g gmat.Tensor) []gmat.Tensor {
+		gx := gmat.Conv1DBackwardInput(g, w.Value, length, param)
+		gw := gmat.Conv1DBackwardKernel(x.Value, g, k, param)
+		return grads(gx, gw, gmat.Conv1DBackwardBias(g))
+	}, x, w, b)
+}
+
+// Conv2D convolves x [N, C_in, H, W] with w [C_out, C_in/groups, kh, kw].
+// b may be nil.
+func Conv2D(x, w, b *Variable, param gmat.ConvParam) *Variable {
+	var bias gmat.Tensor
+	if b != nil {
+		bias = b.Value
+	}
+	_, _, h, width := gmat.Shape4D(x.Value)
+	_, _, kh, kw := gmat.Shape4D(w.Value)
+	return record(gmat.Conv2D(x.Value, w.Value, bias, param), func(g gmat.Tensor) []gmat.Tensor {
+		gx := gmat.Conv2DBackwardInput(g, w.Value, h, width, param)
+		gw := gmat.Conv2DBackwardKernel(x.Value, g, kh, kw, param)
+		return grads(gx, gw, gmat.Conv2DBackwardBias(g))
+	}, x, w, b)
+}
+
+func MaxPool2D(x *Variable, kh, kw int, param gmat.ConvParam) *Variable {
+	_, _, h, w := gmat.Shape4D(x.Value)
+	z, index := gmat.MaxPool2D(x.Value, kh, kw, param)
+	return record(z, func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.MaxPool2DBackward(g, index, h, w))
+	}, x)
+}
+
+func AvgPool2D(x *Variable, kh, kw int, param gmat.ConvParam) *Variable {
+	_, _, h, w := gmat.Shape4D(x.Value)
+	return record(gmat.AvgPool2D(x.Value, kh, kw, param), func(g gmat.Tensor) []gmat.Tensor {
+		return grads(gmat.AvgPool2DBackward(g, kh, kw, h, w, param))
+	}, x)
+}
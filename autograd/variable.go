@@ -0,0 +1,194 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package autograd records gmat operations on Variables and computes
+// gradients by reverse-mode differentiation.
+package autograd
+
+import (
+	"bytes"
+	"github.com/kuroko1t/gmat"
+	"log"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Variable is a tensor that remembers the operation which produced it.
+// Only leaves with RequiresGrad set receive Grad; gradients of repeated
+// Backward calls are accumulated until ZeroGrad.
+type Variable struct {
+	Value        gmat.Tensor
+	Grad         gmat.Tensor
+	RequiresGrad bool
+	parents      []*Variable
+	backward     func(grad gmat.Tensor) []gmat.Tensor
+}
+
+func NewVariable(value gmat.Tensor, requiresGrad bool) *Variable {
+	return &Variable{Value: value, RequiresGrad: requiresGrad}
+}
+
+func Constant(value gmat.Tensor) *Variable {
+	return NewVariable(value, false)
+}
+
+func (v *Variable) IsLeaf() bool {
+	return v.backward == nil
+}
+
+func (v *Variable) HasGrad() bool {
+	return len(v.Grad.Shape) > 0
+}
+
+func (v *Variable) ZeroGrad() {
+	v.Grad = gmat.Tensor{}
+}
+
+// Detach returns a leaf sharing the value but not the history of v.
+func (v *Variable) Detach() *Variable {
+	return Constant(v.Value)
+}
+
+var (
+	noGradMu sync.Mutex
+	// noGrad counts the open NoGrad scopes of every goroutine by id
+	noGrad map[uint64]int
+	// noGradScopes lets GradEnabled skip the lookup when no scope is open
+	noGradScopes int32
+)
+
+// NoGrad runs fn without recording operations on the calling goroutine.
+// Other goroutines, including those started by fn, keep recording, and the
+// previous state is restored even if fn panics.
+func NoGrad(fn func()) {
+	id := goroutineID()
+	noGradMu.Lock()
+	if noGrad == nil {
+		noGrad = map[uint64]int{}
+	}
+	noGrad[id]++
+	noGradMu.Unlock()
+	atomic.AddInt32(&noGradScopes, 1)
+	defer func() {
+		atomic.AddInt32(&noGradScopes, -1)
+		noGradMu.Lock()
+		if noGrad[id]--; noGrad[id] == 0 {
+			delete(noGrad, id)
+		}
+		noGradMu.Unlock()
+	}()
+	fn()
+}
+
+// GradEnabled reports whether the calling goroutine records operations.
+func GradEnabled() bool {
+	if atomic.LoadInt32(&noGradScopes) == 0 {
+		return true
+	}
+	id := goroutineID()
+	noGradMu.Lock()
+	defer noGradMu.Unlock()
+	return noGrad[id] == 0
+}
+
+// goroutineID parses the id from the "goroutine N [...]" stack header.
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		log.Fatal("autograd.unexpected goroutine header")
+	}
+	return id
+}
+
+// record wraps value into a Variable and keeps the graph only if one of
+// the parents needs a gradient. nil parents are allowed for optional
+// inputs such as a missing bias.
+func record(value gmat.Tensor, backward func(grad gmat.Tensor) []gmat.Tensor, parents ...*Variable) *Variable {
+	z := &Variable{Value: value}
+	if !GradEnabled() {
+		return z
+	}
+	for _, p := range parents {
+		if p != nil && p.RequiresGrad {
+			z.RequiresGrad = true
+		}
+	}
+	if z.RequiresGrad {
+		z.parents = parents
+		z.backward = backward
+	}
+	return z
+}
+
+// Backward seeds the gradient of v with ones, which is the usual case of
+// a scalar loss.
+func (v *Variable) Backward() {
+	v.BackwardWith(gmat.OnesLike(v.Value))
+}
+
+// BackwardWith propagates grad from v to every leaf that requires it.
+func (v *Variable) BackwardWith(grad gmat.Tensor) {
+	if !v.RequiresGrad {
+		return
+	}
+	var order []*Variable
+	visited := map[*Variable]bool{}
+	var visit func(n *Variable)
+	visit = func(n *Variable) {
+		if n == nil || !n.RequiresGrad || visited[n] {
+			return
+		}
+		visited[n] = true
+		for _, p := range n.parents {
+			visit(p)
+		}
+		order = append(order, n)
+	}
+	visit(v)
+	grads := map[*Variable]gmat.Tensor{v: grad}
+	for i := len(order) - 1; i >= 0; i-- {
+		n := order[i]
+		g, ok := grads[n]
+		if !ok {
+			continue
+		}
+		delete(grads, n)
+		if n.IsLeaf() {
+			n.Grad = accumulate(n.Grad, g)
+			continue
+		}
+		for j, pg := range n.backward(g) {
+			p := n.parents[j]
+			if p == nil || !p.RequiresGrad {
+				continue
+			}
+			grads[p] = accumulate(grads[p], pg)
+		}
+	}
+}
+
+func accumulate(sum, g gmat.Tensor) gmat.Tensor {
+	if len(sum.Shape) == 0 {
+		return g
+	}
+	return gmat.Add(sum, g)
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"math"
+)
+
+// Every activation comes with a Derivative function that evaluates the
+// derivative at the same input x, so backward passes multiply it with the
+// output gradient.
+
+func mapTensor(x Tensor, fn func(float64) float64) Tensor {
+	data := Flatten(x)
+	for i, v := range data {
+		data[i] = fn(v)
+	}
+	return Unflatten(data, x.Shape)
+}
+
+func sigmoid(x float64) float64 {
+	if x >= 0 {
+		return 1 / (1 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1 + e)
+}
+
+// softplus is log(1 + exp(x)) without overflow for large x.
+func softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+func ReLU(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		return math.Max(v, 0)
+	})
+}
+
+func ReLUDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return 0
+	})
+}
+
+func LeakyReLU(x Tensor, alpha float64) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		if v > 0 {
+			return v
+		}
+		return alpha * v
+	})
+}
+
+func LeakyReLUDerivative(x Tensor, alpha float64) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return alpha
+	})
+}
+
+func ELU(x Tensor, alpha float64) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		if v > 0 {
+			return v
+		}
+		return alpha * math.Expm1(v)
+	})
+}
+
+func ELUDerivative(x Tensor, alpha float64) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		if v > 0 {
+			return 1
+		}
+		return alpha * math.Exp(v)
+	})
+}
+
+// GELU uses the exact form x * Phi(x).
+func GELU(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		return 0.5 * v * (1 + math.Erf(v/math.Sqrt2))
+	})
+}
+
+func GELUDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		cdf := 0.5 * (1 + math.Erf(v/math.Sqrt2))
+		pdf := math.Exp(-0.5*v*v) / math.Sqrt(2*math.Pi)
+		return cdf + v*pdf
+	})
+}
+
+// SiLU is x * sigmoid(x), also known as Swish.
+func SiLU(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		return v * sigmoid(v)
+	})
+}
+
+func SiLUDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		s := sigmoid(v)
+		return s * (1 + v*(1-s))
+	})
+}
+
+func Swish(x Tensor) Tensor {
+	return SiLU(x)
+}
+
+func SwishDerivative(x Tensor) Tensor {
+	return SiLUDerivative(x)
+}
+
+func Sigmoid(x Tensor) Tensor {
+	return mapTensor(x, sigmoid)
+}
+
+func SigmoidDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		s := sigmoid(v)
+		return s * (1 - s)
+	})
+}
+
+func Tanh(x Tensor) Tensor {
+	return mapTensor(x, math.Tanh)
+}
+
+func TanhDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		t := math.Tanh(v)
+		return 1 - t*t
+	})
+}
+
+func Softplus(x Tensor) Tensor {
+	return mapTensor(x, softplus)
+}
+
+func SoftplusDerivative(x Tensor) Tensor {
+	return mapTensor(x, sigmoid)
+}
+
+// HardSigmoid is clip(x/6 + 1/2, 0, 1).
+func HardSigmoid(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		return math.Min(math.Max(v/6+0.5, 0), 1)
+	})
+}
+
+func HardSigmoidDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		if v > -3 && v < 3 {
+			return 1.0 / 6
+		}
+		return 0
+	})
+}
+
+// Mish is x * tanh(softplus(x)).
+func Mish(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		return v * math.Tanh(softplus(v))
+	})
+}
+
+func MishDerivative(x Tensor) Tensor {
+	return mapTensor(x, func(v float64) float64 {
+		t := math.Tanh(softplus(v))
+		return t + v*(1-t*t)*sigmoid(v)
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestActivationSuccess(t *testing.T) {
+	x := Tensor{CPU: [][]float64{{-2, 0, 3}}, Shape: []int{1, 3}}
+	ExpCheck1D(Flatten(ReLU(x)), []float64{0, 0, 3}, t)
+	ExpCheck1D(Flatten(LeakyReLU(x, 0.5)), []float64{-1, 0, 3}, t)
+	ExpNearCheck1D(Flatten(ELU(x, 1)), []float64{math.Exp(-2) - 1, 0, 3}, 1e-12, t)
+	ExpNearCheck1D(Flatten(HardSigmoid(x)), []float64{1.0 / 6, 0.5, 1}, 1e-12, t)
+	ExpNearCheck1D(Flatten(Sigmoid(x)), []float64{1 / (1 + math.Exp(2)), 0.5, 1 / (1 + math.Exp(-3))}, 1e-12, t)
+	ExpNearCheck1D(Flatten(Softplus(x)), []float64{math.Log(1 + math.Exp(-2)), math.Log(2), math.Log(1 + math.Exp(3))}, 1e-12, t)
+	// no overflow for large inputs
+	big := Tensor{CPU: [][]float64{{-1000, 1000}}, Shape: []int{1, 2}}
+	ExpNearCheck1D(Flatten(Softplus(big)), []float64{0, 1000}, 1e-12, t)
+	ExpNearCheck1D(Flatten(Sigmoid(big)), []float64{0, 1}, 1e-12, t)
+	ExpNearCheck1D(Flatten(Mish(big)), []float64{0, 1000}, 1e-12, t)
+}
+
+func TestActivationDerivativeSuccess(t *testing.T) {
+	// avoid the kinks of ReLU-like functions at 0 and +-3
+	x := Tensor{CPU: [][]float64{{-4, -2.5, -0.7, 0.3, 1.1, 2.9, 4}}, Shape: []int{1, 7}}
+	pairs := []struct {
+		f, df func(Tensor) Tensor
+	}{
+		{ReLU, ReLUDerivative},
+		{func(x Tensor) Tensor { return LeakyReLU(x, 0.1) }, func(x Tensor) Tensor { return LeakyReLUDerivative(x, 0.1) }},
+		{func(x Tensor) Tensor { return ELU(x, 1.5) }, func(x Tensor) Tensor { return ELUDerivative(x, 1.5) }},
+		{GELU, GELUDerivative},
+		{SiLU, SiLUDerivative},
+		{Swish, SwishDerivative},
+		{Sigmoid, SigmoidDerivative},
+		{Tanh, TanhDerivative},
+		{Softplus, SoftplusDerivative},
+		{HardSigmoid, HardSigmoidDerivative},
+		{Mish, MishDerivative},
+	}
+	xData := Flatten(x)
+	// elementwise, so the gradient of the plain sum is the derivative
+	weight := Flatten(Full(x.Shape, 1))
+	for _, pair := range pairs {
+		f := func() []float64 {
+			return Flatten(pair.f(Unflatten(xData, x.Shape)))
+		}
+		ExpNearCheck1D(Flatten(pair.df(x)), numGrad(xData, weight, f), 1e-6, t)
+	}
+}
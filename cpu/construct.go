@@ -0,0 +1,118 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+	"math"
+)
+
+func Full(shape []int, value float64) Tensor {
+	z := make([]float64, Size(shape))
+	if value != 0 {
+		for i := range z {
+			z[i] = value
+		}
+	}
+	return Unflatten(z, shape)
+}
+
+func Eye(n, m int) [][]float64 {
+	z := make2D(n, m)
+	for i := 0; i < n && i < m; i++ {
+		z[i][i] = 1
+	}
+	return z
+}
+
+func Diag(x [][]float64) [][]float64 {
+	n, m := Shape2D(x)
+	if n == 1 || m == 1 {
+		// vector -> diagonal matrix
+		v := Reshape2D1D(x)
+		z := make2D(len(v), len(v))
+		for i := range v {
+			z[i][i] = v[i]
+		}
+		return z
+	}
+	// matrix -> diagonal as row vector
+	size := n
+	if m < n {
+		size = m
+	}
+	z := make2D(1, size)
+	for i := 0; i < size; i++ {
+		z[0][i] = x[i][i]
+	}
+	return z
+}
+
+func Arange(start, stop, step float64) []float64 {
+	if step == 0 {
+		log.Fatal("Arange.step must not be zero")
+	}
+	n := int(math.Ceil((stop - start) / step))
+	if n < 0 {
+		n = 0
+	}
+	z := make([]float64, n)
+	for i := range z {
+		z[i] = start + float64(i)*step
+	}
+	return z
+}
+
+func Linspace(start, stop float64, num int) []float64 {
+	z := make([]float64, num)
+	if num == 1 {
+		z[0] = start
+		return z
+	}
+	step := (stop - start) / float64(num-1)
+	for i := range z {
+		z[i] = start + float64(i)*step
+	}
+	if num > 1 {
+		z[num-1] = stop
+	}
+	return z
+}
+
+func Tril(x [][]float64, k int) [][]float64 {
+	n, m := Shape2D(x)
+	z := make2D(n, m)
+	for i := range z {
+		for j := range z[i] {
+			if j-i <= k {
+				z[i][j] = x[i][j]
+			}
+		}
+	}
+	return z
+}
+
+func Triu(x [][]float64, k int) [][]float64 {
+	n, m := Shape2D(x)
+	z := make2D(n, m)
+	for i := range z {
+		for j := range z[i] {
+			if j-i >= k {
+				z[i][j] = x[i][j]
+			}
+		}
+	}
+	return z
+}
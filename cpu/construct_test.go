@@ -0,0 +1,56 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"testing"
+)
+
+func TestFullSuccess(t *testing.T) {
+	z := Full([]int{2, 1, 2, 2}, 7)
+	ExpCheck1D(Flatten(z), []float64{7, 7, 7, 7, 7, 7, 7, 7}, t)
+}
+
+func TestDiagSuccess(t *testing.T) {
+	zExp := [][]float64{
+		{1, 0, 0},
+		{0, 5, 0},
+		{0, 0, 9},
+	}
+	zReal := Diag(Diag(x))
+	ExpCheck(zReal, zExp, t)
+	ExpCheck(Eye(2, 3), [][]float64{{1, 0, 0}, {0, 1, 0}}, t)
+}
+
+func TestArangeLinspaceSuccess(t *testing.T) {
+	ExpCheck1D(Arange(1, 2, 0.25), []float64{1, 1.25, 1.5, 1.75}, t)
+	ExpCheck1D(Arange(3, 0, -1), []float64{3, 2, 1}, t)
+	ExpCheck1D(Linspace(0, 1, 5), []float64{0, 0.25, 0.5, 0.75, 1}, t)
+}
+
+func TestTrilTriuSuccess(t *testing.T) {
+	zTril := [][]float64{
+		{1, 2, 0},
+		{4, 5, 6},
+		{7, 8, 9},
+	}
+	ExpCheck(Tril(x, 1), zTril, t)
+	zTriu := [][]float64{
+		{1, 2, 3},
+		{0, 5, 6},
+		{0, 0, 9},
+	}
+	ExpCheck(Triu(x, 0), zTriu, t)
+}
@@ -0,0 +1,238 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+	"sync"
+)
+
+const (
+	PadZeros = iota
+	PadReflect
+	PadReplicate
+)
+
+// ConvParam configures convolutions. Zero values of Stride, Dilation and
+// Groups mean 1. Padding -1 pads so that the output length is
+// ceil(input / stride), splitting odd padding to the right like "same".
+type ConvParam struct {
+	Stride   int
+	Padding  int
+	PadMode  int
+	Dilation int
+	Groups   int
+}
+
+func convParam(param ConvParam) ConvParam {
+	if param.Stride == 0 {
+		param.Stride = 1
+	}
+	if param.Dilation == 0 {
+		param.Dilation = 1
+	}
+	if param.Groups == 0 {
+		param.Groups = 1
+	}
+	return param
+}
+
+// convPad returns the padding before and after the input along one axis.
+func convPad(length, k int, param ConvParam) (int, int) {
+	if param.Padding >= 0 {
+		return param.Padding, param.Padding
+	}
+	out := (length + param.Stride - 1) / param.Stride
+	total := (out-1)*param.Stride + param.Dilation*(k-1) + 1 - length
+	if total < 0 {
+		total = 0
+	}
+	return total / 2, total - total/2
+}
+
+func convOutSize(length, k, padL, padR int, param ConvParam) int {
+	out := (length+padL+padR-param.Dilation*(k-1)-1)/param.Stride + 1
+	if out <= 0 {
+		log.Fatal("conv.kernel larger than padded input")
+	}
+	return out
+}
+
+// padIndex maps a position of the padded input back to the input, or -1
+// for zero padding.
+func padIndex(pos, length, mode int) int {
+	if pos >= 0 && pos < length {
+		return pos
+	}
+	switch mode {
+	case PadReflect:
+		if length == 1 {
+			return 0
+		}
+		period := 2 * (length - 1)
+		pos = pos % period
+		if pos < 0 {
+			pos += period
+		}
+		if pos >= length {
+			pos = period - pos
+		}
+		return pos
+	case PadReplicate:
+		if pos < 0 {
+			return 0
+		}
+		return length - 1
+	}
+	return -1
+}
+
+func parallel(n int, fn func(i int)) {
+	wg := &sync.WaitGroup{}
+	ch := make(chan int, numcpu())
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		ch <- 1
+		go func(i int) {
+			defer func() {
+				<-ch
+				wg.Done()
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func conv1DCheck(cin, cout, kcin int, param ConvParam) {
+	if cin%param.Groups != 0 || cout%param.Groups != 0 {
+		log.Fatal("Conv1D.channels not divisible by groups")
+	}
+	if cin/param.Groups != kcin {
+		log.Fatal("Conv1D.mismatch input channels and kernel")
+	}
+}
+
+// Conv1D computes the cross-correlation of input [N, C_in, L] with kernel
+// [C_out, C_in/groups, K]. bias may be nil.
+func Conv1D(input, kernel [][][]float64, bias []float64, param ConvParam) [][][]float64 {
+	param = convParam(param)
+	n, cin, length := Shape3D(input)
+	cout, kcin, k := Shape3D(kernel)
+	conv1DCheck(cin, cout, kcin, param)
+	padL, padR := convPad(length, k, param)
+	out := convOutSize(length, k, padL, padR, param)
+	coutG := cout / param.Groups
+	output := make3D(n, cout, out)
+	parallel(n, func(b int) {
+		for oc := 0; oc < cout; oc++ {
+			g := oc / coutG
+			for o := 0; o < out; o++ {
+				result := 0.0
+				if bias != nil {
+					result = bias[oc]
+				}
+				for ic := 0; ic < kcin; ic++ {
+					x := input[b][g*kcin+ic]
+					w := kernel[oc][ic]
+					for j := 0; j < k; j++ {
+						idx := padIndex(o*param.Stride-padL+j*param.Dilation, length, param.PadMode)
+						if idx >= 0 {
+							result += x[idx] * w[j]
+						}
+					}
+				}
+				output[b][oc][o] = result
+			}
+		}
+	})
+	return output
+}
+
+// Conv1DBackwardInput returns the gradient with respect to the input of
+// length `length` given the gradient of the output.
+func Conv1DBackwardInput(gradOut, kernel [][][]float64, length int, param ConvParam) [][][]float64 {
+	param = convParam(param)
+	n, cout, out := Shape3D(gradOut)
+	_, kcin, k := Shape3D(kernel)
+	cin := kcin * param.Groups
+	padL, _ := convPad(length, k, param)
+	coutG := cout / param.Groups
+	gradIn := make3D(n, cin, length)
+	parallel(n, func(b int) {
+		for oc := 0; oc < cout; oc++ {
+			g := oc / coutG
+			for o := 0; o < out; o++ {
+				grad := gradOut[b][oc][o]
+				for ic := 0; ic < kcin; ic++ {
+					dx := gradIn[b][g*kcin+ic]
+					w := kernel[oc][ic]
+					for j := 0; j < k; j++ {
+						idx := padIndex(o*param.Stride-padL+j*param.Dilation, length, param.PadMode)
+						if idx >= 0 {
+							dx[idx] += grad * w[j]
+						}
+					}
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+// Conv1DBackwardKernel returns the gradient with respect to a kernel of
+// width k.
+func Conv1DBackwardKernel(input, gradOut [][][]float64, k int, param ConvParam) [][][]float64 {
+	param = convParam(param)
+	n, cin, length := Shape3D(input)
+	_, cout, out := Shape3D(gradOut)
+	kcin := cin / param.Groups
+	padL, _ := convPad(length, k, param)
+	coutG := cout / param.Groups
+	gradKernel := make3D(cout, kcin, k)
+	// split by output channel so that goroutines never share a kernel row
+	parallel(cout, func(oc int) {
+		g := oc / coutG
+		for b := 0; b < n; b++ {
+			for o := 0; o < out; o++ {
+				grad := gradOut[b][oc][o]
+				for ic := 0; ic < kcin; ic++ {
+					x := input[b][g*kcin+ic]
+					dw := gradKernel[oc][ic]
+					for j := 0; j < k; j++ {
+						idx := padIndex(o*param.Stride-padL+j*param.Dilation, length, param.PadMode)
+						if idx >= 0 {
+							dw[j] += grad * x[idx]
+						}
+					}
+				}
+			}
+		}
+	})
+	return gradKernel
+}
+
+func Conv1DBackwardBias(gradOut [][][]float64) []float64 {
+	_, cout, _ := Shape3D(gradOut)
+	gradBias := make([]float64, cout)
+	for b := range gradOut {
+		for oc := range gradOut[b] {
+			for _, v := range gradOut[b][oc] {
+				gradBias[oc] += v
+			}
+		}
+	}
+	return gradBias
+}
@@ -0,0 +1,237 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+)
+
+// Im2Col unfolds the (already padded) input [C, H, W] into a matrix
+// [C*kh*kw, outH*outW] whose columns are the receptive fields.
+func Im2Col(input [][][]float64, kh, kw int, param ConvParam) [][]float64 {
+	param = convParam(param)
+	c, h, w := Shape3D(input)
+	outH := convOutSize(h, kh, 0, 0, param)
+	outW := convOutSize(w, kw, 0, 0, param)
+	cols := make2D(c*kh*kw, outH*outW)
+	for ic := 0; ic < c; ic++ {
+		for i := 0; i < kh; i++ {
+			for j := 0; j < kw; j++ {
+				row := cols[(ic*kh+i)*kw+j]
+				for y := 0; y < outH; y++ {
+					src := input[ic][y*param.Stride+i*param.Dilation]
+					for x := 0; x < outW; x++ {
+						row[y*outW+x] = src[x*param.Stride+j*param.Dilation]
+					}
+				}
+			}
+		}
+	}
+	return cols
+}
+
+// Col2Im folds cols back into a [C, H, W] image, summing overlapping
+// receptive fields. It is the adjoint of Im2Col.
+func Col2Im(cols [][]float64, c, h, w, kh, kw int, param ConvParam) [][][]float64 {
+	param = convParam(param)
+	outH := convOutSize(h, kh, 0, 0, param)
+	outW := convOutSize(w, kw, 0, 0, param)
+	image := make3D(c, h, w)
+	for ic := 0; ic < c; ic++ {
+		for i := 0; i < kh; i++ {
+			for j := 0; j < kw; j++ {
+				row := cols[(ic*kh+i)*kw+j]
+				for y := 0; y < outH; y++ {
+					dst := image[ic][y*param.Stride+i*param.Dilation]
+					for x := 0; x < outW; x++ {
+						dst[x*param.Stride+j*param.Dilation] += row[y*outW+x]
+					}
+				}
+			}
+		}
+	}
+	return image
+}
+
+type conv2DShape struct {
+	n, cin, h, w           int
+	cout, kcin, kh, kw     int
+	padT, padB, padL, padR int
+	outH, outW             int
+	cinG, coutG            int
+}
+
+func conv2DSetup(n, cin, h, w int, kernel [][][][]float64, param ConvParam) conv2DShape {
+	if param.PadMode != PadZeros {
+		log.Fatal("Conv2D.only zero padding is supported")
+	}
+	s := conv2DShape{n: n, cin: cin, h: h, w: w}
+	s.cout, s.kcin, s.kh, s.kw = Shape4D(kernel)
+	if cin%param.Groups != 0 || s.cout%param.Groups != 0 {
+		log.Fatal("Conv2D.channels not divisible by groups")
+	}
+	if cin/param.Groups != s.kcin {
+		log.Fatal("Conv2D.mismatch input channels and kernel")
+	}
+	s.padT, s.padB = convPad(h, s.kh, param)
+	s.padL, s.padR = convPad(w, s.kw, param)
+	s.outH = convOutSize(h, s.kh, s.padT, s.padB, param)
+	s.outW = convOutSize(w, s.kw, s.padL, s.padR, param)
+	s.cinG = s.kcin
+	s.coutG = s.cout / param.Groups
+	return s
+}
+
+// kernelMatrix returns the kernel of group g as [C_out/groups, C_in/groups*kh*kw].
+func kernelMatrix(kernel [][][][]float64, s conv2DShape, g int) [][]float64 {
+	z := make2D(s.coutG, s.kcin*s.kh*s.kw)
+	for oc := 0; oc < s.coutG; oc++ {
+		col := 0
+		for ic := 0; ic < s.kcin; ic++ {
+			for i := 0; i < s.kh; i++ {
+				copy(z[oc][col:col+s.kw], kernel[g*s.coutG+oc][ic][i])
+				col += s.kw
+			}
+		}
+	}
+	return z
+}
+
+func (s conv2DShape) pad() [][]int {
+	return [][]int{{0, 0}, {0, 0}, {s.padT, s.padB}, {s.padL, s.padR}}
+}
+
+// Conv2D computes the cross-correlation of input [N, C_in, H, W] with
+// kernel [C_out, C_in/groups, kh, kw] by lowering every sample to a GEMM.
+// bias may be nil.
+func Conv2D(input, kernel [][][][]float64, bias []float64, param ConvParam) [][][][]float64 {
+	param = convParam(param)
+	n, cin, h, w := Shape4D(input)
+	s := conv2DSetup(n, cin, h, w, kernel, param)
+	padded := Pad4D(input, s.pad())
+	weights := make([][][]float64, param.Groups)
+	for g := range weights {
+		weights[g] = kernelMatrix(kernel, s, g)
+	}
+	output := make4D(n, s.cout, s.outH, s.outW)
+	parallel(n, func(b int) {
+		for g := 0; g < param.Groups; g++ {
+			cols := Im2Col(padded[b][g*s.cinG:(g+1)*s.cinG], s.kh, s.kw, param)
+			out := Dot(weights[g], cols)
+			for oc := 0; oc < s.coutG; oc++ {
+				c := g*s.coutG + oc
+				for y := 0; y < s.outH; y++ {
+					for x := 0; x < s.outW; x++ {
+						output[b][c][y][x] = out[oc][y*s.outW+x]
+						if bias != nil {
+							output[b][c][y][x] += bias[c]
+						}
+					}
+				}
+			}
+		}
+	})
+	return output
+}
+
+// gradMatrix returns the output gradient of sample b and group g as
+// [C_out/groups, outH*outW].
+func gradMatrix(gradOut [][][][]float64, s conv2DShape, b, g int) [][]float64 {
+	z := make2D(s.coutG, s.outH*s.outW)
+	for oc := 0; oc < s.coutG; oc++ {
+		for y := 0; y < s.outH; y++ {
+			copy(z[oc][y*s.outW:(y+1)*s.outW], gradOut[b][g*s.coutG+oc][y])
+		}
+	}
+	return z
+}
+
+// Conv2DBackwardInput returns the gradient with respect to an input of
+// spatial size h x w.
+func Conv2DBackwardInput(gradOut, kernel [][][][]float64, h, w int, param ConvParam) [][][][]float64 {
+	param = convParam(param)
+	n := len(gradOut)
+	_, kcin, _, _ := Shape4D(kernel)
+	s := conv2DSetup(n, kcin*param.Groups, h, w, kernel, param)
+	weightsT := make([][][]float64, param.Groups)
+	for g := range weightsT {
+		weightsT[g] = T(kernelMatrix(kernel, s, g))
+	}
+	hp := h + s.padT + s.padB
+	wp := w + s.padL + s.padR
+	gradIn := make4D(n, s.cin, h, w)
+	parallel(n, func(b int) {
+		for g := 0; g < param.Groups; g++ {
+			cols := Dot(weightsT[g], gradMatrix(gradOut, s, b, g))
+			image := Col2Im(cols, s.cinG, hp, wp, s.kh, s.kw, param)
+			for ic := 0; ic < s.cinG; ic++ {
+				for y := 0; y < h; y++ {
+					copy(gradIn[b][g*s.cinG+ic][y], image[ic][y+s.padT][s.padL:s.padL+w])
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+// Conv2DBackwardKernel returns the gradient with respect to a kernel of
+// spatial size kh x kw.
+func Conv2DBackwardKernel(input, gradOut [][][][]float64, kh, kw int, param ConvParam) [][][][]float64 {
+	param = convParam(param)
+	n, cin, h, w := Shape4D(input)
+	_, cout, _, _ := Shape4D(gradOut)
+	s := conv2DSetup(n, cin, h, w, make4D(cout, cin/param.Groups, kh, kw), param)
+	padded := Pad4D(input, s.pad())
+	// one partial gradient per sample, reduced afterwards
+	partial := make([][][][]float64, n)
+	parallel(n, func(b int) {
+		partial[b] = make([][][]float64, param.Groups)
+		for g := 0; g < param.Groups; g++ {
+			cols := Im2Col(padded[b][g*s.cinG:(g+1)*s.cinG], kh, kw, param)
+			partial[b][g] = Dot(gradMatrix(gradOut, s, b, g), T(cols))
+		}
+	})
+	gradKernel := make4D(cout, s.kcin, kh, kw)
+	for b := 0; b < n; b++ {
+		for g := 0; g < param.Groups; g++ {
+			for oc := 0; oc < s.coutG; oc++ {
+				row := partial[b][g][oc]
+				for ic := 0; ic < s.kcin; ic++ {
+					for i := 0; i < kh; i++ {
+						for j := 0; j < kw; j++ {
+							gradKernel[g*s.coutG+oc][ic][i][j] += row[(ic*kh+i)*kw+j]
+						}
+					}
+				}
+			}
+		}
+	}
+	return gradKernel
+}
+
+func Conv2DBackwardBias(gradOut [][][][]float64) []float64 {
+	_, cout, _, _ := Shape4D(gradOut)
+	gradBias := make([]float64, cout)
+	for b := range gradOut {
+		for oc := range gradOut[b] {
+			for y := range gradOut[b][oc] {
+				for _, v := range gradOut[b][oc][y] {
+					gradBias[oc] += v
+				}
+			}
+		}
+	}
+	return gradBias
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"testing"
+)
+
+func TestConv2DSuccess(t *testing.T) {
+	x4d := [][][][]float64{{{
+		{1, 2, 3},
+		{4, 5, 6},
+		{7, 8, 9},
+	}}}
+	k4d := [][][][]float64{{{
+		{1, 0},
+		{0, -1},
+	}}}
+	zExp := [][][][]float64{{{
+		{-4, -4},
+		{-4, -4},
+	}}}
+	ExpCheck4D(Conv2D(x4d, k4d, nil, ConvParam{}), zExp, t)
+	zPad := [][][][]float64{{{
+		{-1, -3},
+		{-7, -4},
+	}}}
+	ExpCheck4D(Conv2D(x4d, k4d, []float64{0}, ConvParam{Stride: 2, Padding: 1}), zPad, t)
+}
+
+func TestConv2DDepthwiseSuccess(t *testing.T) {
+	x4d := Normal(0, 1, []int{2, 3, 5, 5}).CPU4D
+	k4d := Normal(0, 1, []int{3, 1, 3, 3}).CPU4D
+	param := ConvParam{Padding: -1, Groups: 3, Dilation: 2}
+	z := Conv2D(x4d, k4d, []float64{1, 2, 3}, param)
+	for c := 0; c < 3; c++ {
+		// every channel only sees its own input channel
+		single := Conv2D([][][][]float64{{x4d[1][c]}}, [][][][]float64{k4d[c]}, []float64{float64(c + 1)}, ConvParam{Padding: -1, Dilation: 2})
+		ExpNearCheck1D(Reshape2D1D(z[1][c]), Reshape2D1D(single[0][0]), 1e-12, t)
+	}
+}
+
+func TestConv2DBackwardSuccess(t *testing.T) {
+	param := ConvParam{Stride: 2, Padding: 1, Groups: 2}
+	x := Normal(0, 1, []int{2, 4, 5, 6})
+	k := Normal(0, 1, []int{4, 2, 3, 2})
+	out := Conv2D(x.CPU4D, k.CPU4D, nil, param)
+	n, c, h, w := Shape4D(out)
+	shape := []int{n, c, h, w}
+	weight := Flatten(Normal(0, 1, shape))
+	gradOut := Unflatten(weight, shape).CPU4D
+	xData := Flatten(x)
+	kData := Flatten(k)
+	f := func() []float64 {
+		xt := Unflatten(xData, x.Shape)
+		kt := Unflatten(kData, k.Shape)
+		return Flatten(Tensor{CPU4D: Conv2D(xt.CPU4D, kt.CPU4D, nil, param), Shape: shape})
+	}
+	gradIn := Conv2DBackwardInput(gradOut, k.CPU4D, 5, 6, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradIn, Shape: x.Shape}), numGrad(xData, weight, f), 1e-6, t)
+	gradK := Conv2DBackwardKernel(x.CPU4D, gradOut, 3, 2, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradK, Shape: k.Shape}), numGrad(kData, weight, f), 1e-6, t)
+	sum := 0.0
+	for b := 0; b < n; b++ {
+		sum += float64(h*w) * mean(Reshape2D1D(gradOut[b][1]))
+	}
+	ExpNearCheck1D(Conv2DBackwardBias(gradOut)[1:2], []float64{sum}, 1e-9, t)
+}
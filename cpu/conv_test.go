@@ -0,0 +1,113 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func ExpNearCheck1D(zReal, zExp []float64, tol float64, t *testing.T) {
+	success := len(zReal) == len(zExp)
+	for i := range zExp {
+		if success && math.Abs(zExp[i]-zReal[i]) > tol {
+			success = false
+		}
+	}
+	if !success {
+		fmt.Println("Real:", zReal)
+		fmt.Println("Exp:", zExp)
+		t.Fatal("failed Test!")
+	}
+}
+
+// numGrad differentiates sum(f(x) * weight) with central differences.
+func numGrad(x []float64, weight []float64, f func() []float64) []float64 {
+	grad := make([]float64, len(x))
+	eps := 1e-6
+	for i := range x {
+		orig := x[i]
+		x[i] = orig + eps
+		plus := f()
+		x[i] = orig - eps
+		minus := f()
+		x[i] = orig
+		for j := range plus {
+			grad[i] += (plus[j] - minus[j]) * weight[j] / (2 * eps)
+		}
+	}
+	return grad
+}
+
+func TestConv1DStrideDilationSuccess(t *testing.T) {
+	x3d := [][][]float64{{{1, 2, 3, 4, 5, 6, 7}}}
+	k3d := [][][]float64{{{1, 0, -1}}}
+	// dilation 2: x[i] - x[i+4], stride 2, no padding
+	zReal := Conv1D(x3d, k3d, []float64{10}, ConvParam{Stride: 2, Dilation: 2})
+	ExpCheck1D(zReal[0][0], []float64{6, 6}, t)
+	// same padding keeps ceil(7 / 2) outputs
+	zSame := Conv1D(x3d, k3d, nil, ConvParam{Stride: 2, Padding: -1})
+	ExpCheck1D(zSame[0][0], []float64{-2, -2, -2, 6}, t)
+}
+
+func TestConv1DPadModeSuccess(t *testing.T) {
+	x3d := [][][]float64{{{1, 2, 3}}}
+	k3d := [][][]float64{{{1, 1, 1}}}
+	reflect := Conv1D(x3d, k3d, nil, ConvParam{Padding: 2, PadMode: PadReflect})
+	// padded: 3 2 1 2 3 2 1
+	ExpCheck1D(reflect[0][0], []float64{6, 5, 6, 7, 6}, t)
+	replicate := Conv1D(x3d, k3d, nil, ConvParam{Padding: 1, PadMode: PadReplicate})
+	// padded: 1 1 2 3 3
+	ExpCheck1D(replicate[0][0], []float64{4, 6, 8}, t)
+}
+
+func TestConv1DGroupsSuccess(t *testing.T) {
+	x3d := [][][]float64{{{1, 2}, {3, 4}}}
+	k3d := [][][]float64{{{1}}, {{2}}, {{-1}}, {{1}}}
+	zReal := Conv1D(x3d, k3d, nil, ConvParam{Groups: 2})
+	ExpCheck(zReal[0], [][]float64{{1, 2}, {2, 4}, {-3, -4}, {3, 4}}, t)
+}
+
+func TestConv1DBackwardSuccess(t *testing.T) {
+	param := ConvParam{Stride: 2, Padding: 2, Dilation: 2, Groups: 2, PadMode: PadReflect}
+	x := Normal(0, 1, []int{2, 4, 9})
+	k := Normal(0, 1, []int{6, 2, 3})
+	out := Conv1D(x.CPU3D, k.CPU3D, nil, param)
+	n, c, l := Shape3D(out)
+	weight := Flatten(Normal(0, 1, []int{n, c, l}))
+	gradOut := Unflatten(weight, []int{n, c, l}).CPU3D
+	xData := Flatten(x)
+	kData := Flatten(k)
+	f := func() []float64 {
+		xt := Unflatten(xData, x.Shape)
+		kt := Unflatten(kData, k.Shape)
+		return Flatten(Tensor{CPU3D: Conv1D(xt.CPU3D, kt.CPU3D, nil, param), Shape: []int{n, c, l}})
+	}
+	gradIn := Conv1DBackwardInput(gradOut, k.CPU3D, 9, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU3D: gradIn, Shape: x.Shape}), numGrad(xData, weight, f), 1e-6, t)
+	gradK := Conv1DBackwardKernel(x.CPU3D, gradOut, 3, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU3D: gradK, Shape: k.Shape}), numGrad(kData, weight, f), 1e-6, t)
+	gradB := Conv1DBackwardBias(gradOut)
+	for oc := range gradB {
+		sum := 0.0
+		for b := 0; b < n; b++ {
+			for _, v := range gradOut[b][oc] {
+				sum += v
+			}
+		}
+		ExpNearCheck1D([]float64{gradB[oc]}, []float64{sum}, 1e-12, t)
+	}
+}
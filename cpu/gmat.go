@@ -30,8 +30,11 @@ func numcpu() int {
 
 type Tensor struct {
 	CPU   [][]float64
+	CPU3D [][][]float64
 	CPU4D [][][][]float64
 	CPU6D [][][][][][]float64
+	// Data holds the row-major elements of ranks without a nested field.
+	Data  []float64
 	Shape []int
 }
 
@@ -39,8 +42,122 @@ func Make(shape []int) Tensor {
 	tensor := Tensor{Shape: shape}
 	if len(shape) == 2 {
 		tensor.CPU = make2D(shape[0], shape[1])
+	} else if len(shape) == 3 {
+		tensor.CPU3D = make3D(shape[0], shape[1], shape[2])
 	} else if len(shape) == 4 {
 		tensor.CPU4D = make4D(shape[0], shape[1], shape[2], shape[3])
+	} else if len(shape) == 6 {
+		tensor.CPU6D = Make6D(shape[0], shape[1], shape[2], shape[3], shape[4], shape[5])
+	} else if len(shape) > 0 {
+		tensor.Data = make([]float64, Size(shape))
+	}
+	return tensor
+}
+
+func Size(shape []int) int {
+	size := 1
+	for _, s := range shape {
+		size *= s
+	}
+	return size
+}
+
+// Flatten copies the elements of x into a row-major 1D slice.
+func Flatten(x Tensor) []float64 {
+	z := make([]float64, 0, Size(x.Shape))
+	switch len(x.Shape) {
+	case 2:
+		for i := range x.CPU {
+			z = append(z, x.CPU[i]...)
+		}
+	case 3:
+		for i := range x.CPU3D {
+			for j := range x.CPU3D[i] {
+				z = append(z, x.CPU3D[i][j]...)
+			}
+		}
+	case 4:
+		for i := range x.CPU4D {
+			for j := range x.CPU4D[i] {
+				for k := range x.CPU4D[i][j] {
+					z = append(z, x.CPU4D[i][j][k]...)
+				}
+			}
+		}
+	case 6:
+		for i := range x.CPU6D {
+			for j := range x.CPU6D[i] {
+				for k := range x.CPU6D[i][j] {
+					for l := range x.CPU6D[i][j][k] {
+						for m := range x.CPU6D[i][j][k][l] {
+							z = append(z, x.CPU6D[i][j][k][l][m]...)
+						}
+					}
+				}
+			}
+		}
+	case 0:
+		log.Fatal("Flatten.not support shape")
+	default:
+		z = append(z, x.Data...)
+	}
+	return z
+}
+
+// Unflatten builds a tensor of the given shape on top of data without
+// copying it, so the tensor and data share memory.
+func Unflatten(data []float64, shape []int) Tensor {
+	if len(data) != Size(shape) {
+		log.Fatal("Unflatten.mismatch data size and shape")
+	}
+	tensor := Tensor{Shape: append([]int{}, shape...)}
+	rows := func(data []float64, n, m int) [][]float64 {
+		z := make([][]float64, n)
+		for i := range z {
+			z[i] = data[i*m : (i+1)*m : (i+1)*m]
+		}
+		return z
+	}
+	switch len(shape) {
+	case 2:
+		tensor.CPU = rows(data, shape[0], shape[1])
+	case 3:
+		n, c, l := shape[0], shape[1], shape[2]
+		tensor.CPU3D = make([][][]float64, n)
+		for i := range tensor.CPU3D {
+			tensor.CPU3D[i] = rows(data[i*c*l:(i+1)*c*l], c, l)
+		}
+	case 4:
+		n, c, h, w := shape[0], shape[1], shape[2], shape[3]
+		tensor.CPU4D = make([][][][]float64, n)
+		for i := range tensor.CPU4D {
+			tensor.CPU4D[i] = make([][][]float64, c)
+			for j := range tensor.CPU4D[i] {
+				offset := (i*c + j) * h * w
+				tensor.CPU4D[i][j] = rows(data[offset:offset+h*w], h, w)
+			}
+		}
+	case 6:
+		tensor.CPU6D = make([][][][][][]float64, shape[0])
+		offset := 0
+		for i := range tensor.CPU6D {
+			tensor.CPU6D[i] = make([][][][][]float64, shape[1])
+			for j := range tensor.CPU6D[i] {
+				tensor.CPU6D[i][j] = make([][][][]float64, shape[2])
+				for k := range tensor.CPU6D[i][j] {
+					tensor.CPU6D[i][j][k] = make([][][]float64, shape[3])
+					for l := range tensor.CPU6D[i][j][k] {
+						size := shape[4] * shape[5]
+						tensor.CPU6D[i][j][k][l] = rows(data[offset:offset+size], shape[4], shape[5])
+						offset += size
+					}
+				}
+			}
+		}
+	case 0:
+		log.Fatal("Unflatten.not support shape")
+	default:
+		tensor.Data = data[:len(data):len(data)]
 	}
 	return tensor
 }
@@ -237,6 +354,123 @@ func Reshape2D1D(input [][]float64) []float64 {
 	return input1D
 }
 
+func Reshape(x Tensor, shape []int) Tensor {
+	size := Size(x.Shape)
+	shape = append([]int{}, shape...)
+	for i := range shape {
+		if shape[i] == -1 {
+			shape[i] = 1
+			shape[i] = size / Size(shape)
+		}
+	}
+	if Size(shape) != size {
+		log.Fatal("Reshape.mismatch tensor size")
+	}
+	return Unflatten(Flatten(x), shape)
+}
+
+// Concat joins tensors of equal shape except along axis.
+func Concat(xs []Tensor, axis int) Tensor {
+	if len(xs) == 0 {
+		log.Fatal("Concat.no tensors")
+	}
+	axis = axisIndex(xs[0].Shape, axis)
+	shape := append([]int{}, xs[0].Shape...)
+	shape[axis] = 0
+	for _, x := range xs {
+		if len(x.Shape) != len(shape) {
+			log.Fatal("Concat.mismatch rank")
+		}
+		for i := range shape {
+			if i != axis && x.Shape[i] != shape[i] {
+				log.Fatal("Concat.mismatch shape")
+			}
+		}
+		shape[axis] += x.Shape[axis]
+	}
+	outer, dim, inner := axisLayout(shape, axis)
+	z := make([]float64, Size(shape))
+	offset := 0
+	for _, x := range xs {
+		data := Flatten(x)
+		d := x.Shape[axis]
+		for o := 0; o < outer; o++ {
+			copy(z[(o*dim+offset)*inner:(o*dim+offset+d)*inner], data[o*d*inner:(o+1)*d*inner])
+		}
+		offset += d
+	}
+	return Unflatten(z, shape)
+}
+
+// Split cuts x along axis into pieces of the given sizes.
+func Split(x Tensor, sizes []int, axis int) []Tensor {
+	axis = axisIndex(x.Shape, axis)
+	outer, dim, inner := axisLayout(x.Shape, axis)
+	total := 0
+	for _, d := range sizes {
+		total += d
+	}
+	if total != dim {
+		log.Fatal("Split.sizes do not sum to the axis size")
+	}
+	data := Flatten(x)
+	zs := make([]Tensor, len(sizes))
+	offset := 0
+	for i, d := range sizes {
+		shape := append([]int{}, x.Shape...)
+		shape[axis] = d
+		z := make([]float64, outer*d*inner)
+		for o := 0; o < outer; o++ {
+			copy(z[o*d*inner:(o+1)*d*inner], data[(o*dim+offset)*inner:(o*dim+offset+d)*inner])
+		}
+		zs[i] = Unflatten(z, shape)
+		offset += d
+	}
+	return zs
+}
+
+// Permute reorders the axes of x so that axis i of the result is axis
+// axes[i] of x.
+func Permute(x Tensor, axes []int) Tensor {
+	rank := len(x.Shape)
+	if len(axes) != rank {
+		log.Fatal("Permute.mismatch axes and rank")
+	}
+	shape := make([]int, rank)
+	for i, a := range axes {
+		shape[i] = x.Shape[a]
+	}
+	// strides of x in the order of the result axes
+	strides := make([]int, rank)
+	stride := 1
+	for a := rank - 1; a >= 0; a-- {
+		for i := range axes {
+			if axes[i] == a {
+				strides[i] = stride
+			}
+		}
+		stride *= x.Shape[a]
+	}
+	data := Flatten(x)
+	out := make([]float64, len(data))
+	index := make([]int, rank)
+	for o := range out {
+		src := 0
+		for i := range index {
+			src += index[i] * strides[i]
+		}
+		out[o] = data[src]
+		for i := rank - 1; i >= 0; i-- {
+			index[i]++
+			if index[i] < shape[i] {
+				break
+			}
+			index[i] = 0
+		}
+	}
+	return Unflatten(out, shape)
+}
+
 func Reshape1D2D(input []float64, n, c int) [][]float64 {
 	input2D := make2D(n, c)
 	if len(input) != n*c {
@@ -667,19 +901,91 @@ func Cast(x [][]float64, castSize int) [][]float64 {
 	if (m != 1) && (n != 1) {
 		log.Fatal("Cast.not support format")
 	}
+	var z [][]float64
 	if m == 1 {
-		for i := 0; i < castSize; i++ {
-			x = append(x, x[0])
+		z = make2D(castSize, n)
+		for i := range z {
+			copy(z[i], x[0])
 		}
-	}
-	if n == 1 {
-		for i := 0; i < castSize-1; i++ {
-			for i := 0; i < m; i++ {
-				x[i] = append(x[i], x[i][0])
+	} else {
+		z = make2D(m, castSize)
+		for i := range z {
+			for j := range z[i] {
+				z[i][j] = x[i][0]
 			}
 		}
 	}
-	return x
+	return z
+}
+
+// Gather returns the rows of x selected by index.
+func Gather(x [][]float64, index []int) [][]float64 {
+	_, n := Shape2D(x)
+	z := make2D(len(index), n)
+	for i, row := range index {
+		copy(z[i], x[row])
+	}
+	return z
+}
+
+// ScatterAdd returns a copy of x with row i of src added to row index[i].
+// Repeated indices accumulate.
+func ScatterAdd(x [][]float64, index []int, src [][]float64) [][]float64 {
+	m, n := Shape2D(x)
+	if len(index) != len(src) {
+		log.Fatal("ScatterAdd.mismatch index and src")
+	}
+	z := make2D(m, n)
+	for i := range x {
+		copy(z[i], x[i])
+	}
+	IndexAdd(z, index, src)
+	return z
+}
+
+// IndexAdd adds row i of src to row index[i] of x in place.
+func IndexAdd(x [][]float64, index []int, src [][]float64) {
+	if len(index) != len(src) {
+		log.Fatal("IndexAdd.mismatch index and src")
+	}
+	for i, row := range index {
+		for j, v := range src[i] {
+			x[row][j] += v
+		}
+	}
+}
+
+// BatchDot multiplies x[b] by y[b] for every batch b of [B, M, K] and
+// [B, K, N]. transX and transY transpose the matrices of x and y first.
+func BatchDot(x, y [][][]float64, transX, transY bool) [][][]float64 {
+	if len(x) != len(y) {
+		log.Fatal("BatchDot.mismatch batch size")
+	}
+	z := make([][][]float64, len(x))
+	parallel(len(x), func(b int) {
+		xb, yb := x[b], y[b]
+		if transX {
+			xb = T(xb)
+		}
+		if transY {
+			yb = T(yb)
+		}
+		m, kx := Shape2D(xb)
+		ky, n := Shape2D(yb)
+		if kx != ky {
+			log.Fatal("BatchDot.mismatch matrix number")
+		}
+		z[b] = make2D(m, n)
+		for i := 0; i < m; i++ {
+			for l := 0; l < kx; l++ {
+				v := xb[i][l]
+				for j := 0; j < n; j++ {
+					z[b][i][j] += v * yb[l][j]
+				}
+			}
+		}
+	})
+	return z
 }
 
 func MaxCol(x [][]float64) [][]float64 {
@@ -714,10 +1020,10 @@ func ArgMaxCol(x [][]float64) [][]int {
 	for i := 0; i < n; i++ {
 		maxArray[i] = make([]int, m)
 	}
-	index := 0
 	for j := 0; j < n; j++ {
-		max := float64(0.0)
-		for i := 0; i < m; i++ {
+		index := 0
+		max := x[j][0]
+		for i := 1; i < m; i++ {
 			if x[j][i] > max {
 				max = x[j][i]
 				index = i
@@ -750,29 +1056,8 @@ func HeNorm2D(r int, c int) [][]float64 {
 	}
 	for i, zArray := range z {
 		for j, _ := range zArray {
-			z[i][j] = rand.NormFloat64() * (1 / math.Sqrt(float64(r)))
+			z[i][j] = rand.NormFloat64() * math.Sqrt(2/float64(r))
 		}
 	}
 	return z
 }
-
-func Conv1D(input, kernel [][]float64, stride int) [][]float64 {
-	bsize_i, n := Shape2D(input)
-	bsize_k, k := Shape2D(kernel)
-	if bsize_i != bsize_k {
-		panic("not match batchsize conv1d!")
-	}
-	output := make2D(bsize_i, n)
-	for b := 0; b < bsize_i; b++ {
-		for i := 0; i < n; i++ {
-			result := 0.0
-			for j := 0; j < k; j++ {
-				if i+j-1 >= 0 && i+j-1 < n {
-					result += input[b][i+j-1] * kernel[b][j]
-				}
-			}
-			output[b][i] = result
-		}
-	}
-	return output
-}
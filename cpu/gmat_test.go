@@ -192,6 +192,43 @@ func TestSumRowSuccess(t *testing.T) {
 	ExpCheck(zReal, zExp, t)
 }
 
+func TestGatherSuccess(t *testing.T) {
+	x := [][]float64{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	}
+	zExp := [][]float64{
+		{5, 6},
+		{1, 2},
+		{5, 6},
+	}
+	ExpCheck(Gather(x, []int{2, 0, 2}), zExp, t)
+	sExp := [][]float64{
+		{2, 4},
+		{3, 4},
+		{15, 18},
+	}
+	ExpCheck(ScatterAdd(x, []int{2, 0, 2}, zExp), sExp, t)
+	IndexAdd(x, []int{2, 0, 2}, zExp)
+	ExpCheck(x, sExp, t)
+}
+
+func TestArgMaxColSuccess(t *testing.T) {
+	x := [][]float64{
+		{-3, -1, -2},
+		{0, 5, 1},
+		{-1, -2, -3},
+	}
+	zExp := []int{1, 1, 0}
+	zReal := ArgMaxCol(x)
+	for i := range zExp {
+		if zReal[i][0] != zExp[i] || zReal[i][2] != zExp[i] {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
 func TestCastSuccess(t *testing.T) {
 	z1 := [][]float64{
 		{12, 15, 18},
@@ -214,6 +251,9 @@ func TestCastSuccess(t *testing.T) {
 	}
 	z2Real := Cast(z2, 3)
 	ExpCheck(z2Real, z2Exp, t)
+	if len(z1Real) != 2 || len(z2Real[0]) != 3 || len(z2[0]) != 1 {
+		t.Fatal("failed Test!")
+	}
 }
 
 func TestSumColSuccess(t *testing.T) {
@@ -261,14 +301,84 @@ func TestPad4DSuccess(t *testing.T) {
 	ExpCheck4D(zReal, zExp, t)
 }
 func TestConv1DSuccess(t *testing.T) {
-	var x2d = [][]float64{
-		{10, 50, 60, 10, 20, 40, 30},
-		{10, 50, 60, 10, 20, 40, 30}}
-	var y2d = [][]float64{{2, 3, 4}, {2, 3, 4}}
+	var x3d = [][][]float64{
+		{{10, 50, 60, 10, 20, 40, 30}},
+		{{10, 50, 60, 10, 20, 40, 30}}}
+	var y3d = [][][]float64{{{2, 3, 4}}}
 	var zExp = [][]float64{
 		{230, 410, 320, 230, 240, 280, 170},
 		{230, 410, 320, 230, 240, 280, 170},
 	}
-	zReal := Conv1D(x2d, y2d, 1)
-	ExpCheck(zReal, zExp, t)
+	zReal := Conv1D(x3d, y3d, nil, ConvParam{Stride: 1, Padding: 1})
+	ExpCheck([][]float64{zReal[0][0], zReal[1][0]}, zExp, t)
+}
+
+func TestReshapeSuccess(t *testing.T) {
+	x4d := Reshape(Tensor{CPU: x, Shape: []int{3, 3}}, []int{1, 3, -1, 1})
+	zExp := [][][][]float64{
+		{
+			{{1}, {2}, {3}},
+			{{4}, {5}, {6}},
+			{{7}, {8}, {9}},
+		},
+	}
+	ExpCheck4D(x4d.CPU4D, zExp, t)
+	zReal := Reshape(x4d, []int{1, 9})
+	ExpCheck(zReal.CPU, [][]float64{{1, 2, 3, 4, 5, 6, 7, 8, 9}}, t)
+}
+
+func TestFlatReshapeSuccess(t *testing.T) {
+	x1d := Reshape(Tensor{CPU: x, Shape: []int{3, 3}}, []int{9})
+	x5d := Reshape(x1d, []int{1, 3, 1, 3, 1})
+	if len(x5d.Data) != 9 || Make([]int{2, 1, 1, 1, 2, 1, 1}).Data == nil {
+		t.Fatal("failed Test!")
+	}
+	for i, v := range Flatten(x5d) {
+		if v != float64(i+1) || x1d.Data[i] != v {
+			t.Fatal("failed Test!")
+		}
+	}
+	zReal := Reshape(x5d, []int{3, 3})
+	ExpCheck(zReal.CPU, x, t)
+}
+
+func TestBatchDotSuccess(t *testing.T) {
+	x := Normal(0, 1, []int{3, 2, 4})
+	y := Normal(0, 1, []int{3, 4, 5})
+	z := BatchDot(x.CPU3D, y.CPU3D, false, false)
+	zt := BatchDot(Permute(x, []int{0, 2, 1}).CPU3D, Permute(y, []int{0, 2, 1}).CPU3D, true, true)
+	for b := range z {
+		ExpCheck(z[b], Dot(x.CPU3D[b], y.CPU3D[b]), t)
+		ExpCheck(zt[b], z[b], t)
+	}
+}
+
+func TestConcatSplitSuccess(t *testing.T) {
+	x := Unflatten([]float64{1, 2, 3, 4}, []int{2, 2})
+	y := Unflatten([]float64{5, 6}, []int{2, 1})
+	z := Concat([]Tensor{x, y}, 1)
+	ExpCheck(z.CPU, [][]float64{{1, 2, 5}, {3, 4, 6}}, t)
+	parts := Split(z, []int{2, 1}, -1)
+	ExpCheck(parts[0].CPU, x.CPU, t)
+	ExpCheck(parts[1].CPU, y.CPU, t)
+	a := Normal(0, 1, []int{2, 3, 4})
+	b := Normal(0, 1, []int{1, 3, 4})
+	c := Concat([]Tensor{a, b}, 0)
+	if c.Shape[0] != 3 {
+		t.Fatal("failed Test!")
+	}
+	ExpCheck(c.CPU3D[2], b.CPU3D[0], t)
+	ExpCheck(Split(c, []int{2, 1}, 0)[0].CPU3D[1], a.CPU3D[1], t)
+}
+
+func TestPermuteSuccess(t *testing.T) {
+	zReal := Permute(Tensor{CPU: x, Shape: []int{3, 3}}, []int{1, 0})
+	ExpCheck(zReal.CPU, T(x), t)
+	x3d := Unflatten([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, []int{2, 3, 2})
+	z3d := Permute(x3d, []int{2, 0, 1})
+	if z3d.Shape[0] != 2 || z3d.Shape[1] != 2 || z3d.Shape[2] != 3 {
+		t.Fatal("failed Test!")
+	}
+	ExpCheck1D(Flatten(z3d), []float64{1, 3, 5, 7, 9, 11, 2, 4, 6, 8, 10, 12}, t)
+	ExpCheck1D(Flatten(Permute(z3d, []int{1, 2, 0})), Flatten(x3d), t)
 }
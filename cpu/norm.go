@@ -0,0 +1,232 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+	"math"
+)
+
+// Normalisations view x [N, C, ...] as [N, C, S] with S the product of the
+// trailing dims. Statistics are computed with Welford's algorithm and the
+// variance is biased. nil gamma and beta mean no affine transform.
+
+type normLayout struct {
+	n, c, s int
+	groups  int
+	// batch shares statistics of a channel across the batch; otherwise
+	// every sample has groups sets of c/groups channels.
+	batch bool
+}
+
+func newNormLayout(shape []int, groups int, batch bool) normLayout {
+	if len(shape) < 2 {
+		log.Fatal("Norm.need at least 2 dims")
+	}
+	l := normLayout{n: shape[0], c: shape[1], s: Size(shape[2:]), groups: groups, batch: batch}
+	if !batch && (groups <= 0 || l.c%groups != 0) {
+		log.Fatal("Norm.channels not divisible by groups")
+	}
+	return l
+}
+
+func (l normLayout) sets() int {
+	if l.batch {
+		return l.c
+	}
+	return l.n * l.groups
+}
+
+// each calls fn with the flat index of every element of set.
+func (l normLayout) each(set int, fn func(i int)) {
+	if l.batch {
+		for b := 0; b < l.n; b++ {
+			start := (b*l.c + set) * l.s
+			for i := start; i < start+l.s; i++ {
+				fn(i)
+			}
+		}
+		return
+	}
+	cg := l.c / l.groups
+	start := (set/l.groups*l.c + set%l.groups*cg) * l.s
+	for i := start; i < start+cg*l.s; i++ {
+		fn(i)
+	}
+}
+
+func (l normLayout) channel(i int) int {
+	return i / l.s % l.c
+}
+
+// moments returns the mean and biased variance of every set.
+func (l normLayout) moments(data []float64) ([]float64, []float64) {
+	mean := make([]float64, l.sets())
+	variance := make([]float64, l.sets())
+	parallel(l.sets(), func(set int) {
+		m, m2, count := 0.0, 0.0, 0.0
+		l.each(set, func(i int) {
+			count++
+			delta := data[i] - m
+			m += delta / count
+			m2 += delta * (data[i] - m)
+		})
+		mean[set] = m
+		variance[set] = m2 / count
+	})
+	return mean, variance
+}
+
+// normalize returns (x - mean) / sqrt(var + eps) followed by the affine
+// transform, where affine maps an element to its gamma index.
+func (l normLayout) normalize(data, mean, variance, gamma, beta []float64, eps float64, affine func(int) int) []float64 {
+	z := make([]float64, len(data))
+	parallel(l.sets(), func(set int) {
+		invStd := 1 / math.Sqrt(variance[set]+eps)
+		l.each(set, func(i int) {
+			z[i] = (data[i] - mean[set]) * invStd
+			if gamma != nil {
+				z[i] *= gamma[affine(i)]
+			}
+			if beta != nil {
+				z[i] += beta[affine(i)]
+			}
+		})
+	})
+	return z
+}
+
+// backward recomputes the statistics of x and returns the gradients of x,
+// gamma and beta.
+func (l normLayout) backward(data, grad, gamma []float64, eps float64, affine func(int) int, params int) ([]float64, []float64, []float64) {
+	mean, variance := l.moments(data)
+	gx := make([]float64, len(data))
+	dgamma := make([]float64, params)
+	dbeta := make([]float64, params)
+	xhat := make([]float64, len(data))
+	for set := 0; set < l.sets(); set++ {
+		invStd := 1 / math.Sqrt(variance[set]+eps)
+		l.each(set, func(i int) {
+			xhat[i] = (data[i] - mean[set]) * invStd
+			dgamma[affine(i)] += grad[i] * xhat[i]
+			dbeta[affine(i)] += grad[i]
+		})
+	}
+	parallel(l.sets(), func(set int) {
+		invStd := 1 / math.Sqrt(variance[set]+eps)
+		sum, dot, count := 0.0, 0.0, 0.0
+		dxhat := func(i int) float64 {
+			if gamma == nil {
+				return grad[i]
+			}
+			return grad[i] * gamma[affine(i)]
+		}
+		l.each(set, func(i int) {
+			d := dxhat(i)
+			sum += d
+			dot += d * xhat[i]
+			count++
+		})
+		l.each(set, func(i int) {
+			gx[i] = invStd * (dxhat(i) - sum/count - xhat[i]*dot/count)
+		})
+	})
+	return gx, dgamma, dbeta
+}
+
+// BatchNorm normalises every channel over the batch and spatial dims and
+// returns the output with the batch mean and biased variance.
+func BatchNorm(x Tensor, gamma, beta []float64, eps float64) (Tensor, []float64, []float64) {
+	l := newNormLayout(x.Shape, 0, true)
+	data := Flatten(x)
+	mean, variance := l.moments(data)
+	return Unflatten(l.normalize(data, mean, variance, gamma, beta, eps, l.channel), x.Shape), mean, variance
+}
+
+// BatchNormInference normalises with given statistics, e.g. running ones.
+func BatchNormInference(x Tensor, mean, variance, gamma, beta []float64, eps float64) Tensor {
+	l := newNormLayout(x.Shape, 0, true)
+	return Unflatten(l.normalize(Flatten(x), mean, variance, gamma, beta, eps, l.channel), x.Shape)
+}
+
+func BatchNormBackward(x, grad Tensor, gamma []float64, eps float64) (Tensor, []float64, []float64) {
+	l := newNormLayout(x.Shape, 0, true)
+	gx, dgamma, dbeta := l.backward(Flatten(x), Flatten(grad), gamma, eps, l.channel, l.c)
+	return Unflatten(gx, x.Shape), dgamma, dbeta
+}
+
+// BatchNormInferenceBackward is the backward pass of BatchNormInference,
+// where the statistics are constants.
+func BatchNormInferenceBackward(x, grad Tensor, mean, variance, gamma []float64, eps float64) (Tensor, []float64, []float64) {
+	l := newNormLayout(x.Shape, 0, true)
+	data, g := Flatten(x), Flatten(grad)
+	gx := make([]float64, len(data))
+	dgamma := make([]float64, l.c)
+	dbeta := make([]float64, l.c)
+	for i := range data {
+		ch := l.channel(i)
+		invStd := 1 / math.Sqrt(variance[ch]+eps)
+		dgamma[ch] += g[i] * (data[i] - mean[ch]) * invStd
+		dbeta[ch] += g[i]
+		gx[i] = g[i] * invStd
+		if gamma != nil {
+			gx[i] *= gamma[ch]
+		}
+	}
+	return Unflatten(gx, x.Shape), dgamma, dbeta
+}
+
+// LayerNorm normalises every sample over all dims but the first. gamma and
+// beta hold one value per normalised element.
+func LayerNorm(x Tensor, gamma, beta []float64, eps float64) Tensor {
+	l := newNormLayout(x.Shape, 1, false)
+	data := Flatten(x)
+	mean, variance := l.moments(data)
+	return Unflatten(l.normalize(data, mean, variance, gamma, beta, eps, l.feature), x.Shape)
+}
+
+func LayerNormBackward(x, grad Tensor, gamma []float64, eps float64) (Tensor, []float64, []float64) {
+	l := newNormLayout(x.Shape, 1, false)
+	gx, dgamma, dbeta := l.backward(Flatten(x), Flatten(grad), gamma, eps, l.feature, l.c*l.s)
+	return Unflatten(gx, x.Shape), dgamma, dbeta
+}
+
+func (l normLayout) feature(i int) int {
+	return i % (l.c * l.s)
+}
+
+// GroupNorm normalises every sample over groups of channels with per
+// channel gamma and beta.
+func GroupNorm(x Tensor, groups int, gamma, beta []float64, eps float64) Tensor {
+	l := newNormLayout(x.Shape, groups, false)
+	data := Flatten(x)
+	mean, variance := l.moments(data)
+	return Unflatten(l.normalize(data, mean, variance, gamma, beta, eps, l.channel), x.Shape)
+}
+
+func GroupNormBackward(x, grad Tensor, groups int, gamma []float64, eps float64) (Tensor, []float64, []float64) {
+	l := newNormLayout(x.Shape, groups, false)
+	gx, dgamma, dbeta := l.backward(Flatten(x), Flatten(grad), gamma, eps, l.channel, l.c)
+	return Unflatten(gx, x.Shape), dgamma, dbeta
+}
+
+// InstanceNorm is GroupNorm with one channel per group.
+func InstanceNorm(x Tensor, gamma, beta []float64, eps float64) Tensor {
+	return GroupNorm(x, x.Shape[1], gamma, beta, eps)
+}
+
+func InstanceNormBackward(x, grad Tensor, gamma []float64, eps float64) (Tensor, []float64, []float64) {
+	return GroupNormBackward(x, grad, x.Shape[1], gamma, eps)
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"testing"
+)
+
+func TestBatchNormSuccess(t *testing.T) {
+	x := Tensor{CPU: [][]float64{
+		{1, 10},
+		{3, 20},
+	}, Shape: []int{2, 2}}
+	z, mean, variance := BatchNorm(x, []float64{2, 1}, []float64{0, 5}, 0)
+	ExpCheck(z.CPU, [][]float64{{-2, 4}, {2, 6}}, t)
+	ExpNearCheck1D(mean, []float64{2, 15}, 1e-12, t)
+	ExpNearCheck1D(variance, []float64{1, 25}, 1e-12, t)
+	z = BatchNormInference(x, []float64{0, 10}, []float64{4, 100}, nil, nil, 0)
+	ExpCheck(z.CPU, [][]float64{{0.5, 0}, {1.5, 1}}, t)
+}
+
+func TestNormStableSuccess(t *testing.T) {
+	// a large offset must not swamp the variance
+	x := Unflatten([]float64{1e9 + 1, 1e9 + 2, 1e9 + 3, 1e9 + 4}, []int{1, 1, 4})
+	z := LayerNorm(x, nil, nil, 0)
+	s := 1 / 1.118033988749895
+	ExpNearCheck1D(Flatten(z), []float64{-1.5 * s, -0.5 * s, 0.5 * s, 1.5 * s}, 1e-6, t)
+}
+
+func TestGroupNormSuccess(t *testing.T) {
+	x := Normal(3, 2, []int{2, 4, 3, 2})
+	z := Flatten(GroupNorm(x, 2, nil, nil, 0))
+	// every group of 2 channels has 12 elements with mean 0 and variance 1
+	for g := 0; g < 4; g++ {
+		group := z[g*12 : (g+1)*12]
+		m := mean(group)
+		v := 0.0
+		for _, e := range group {
+			v += (e - m) * (e - m) / 12
+		}
+		ExpNearCheck(m, 0, 1e-9, t)
+		ExpNearCheck(v, 1, 1e-9, t)
+	}
+	ExpNearCheck1D(Flatten(InstanceNorm(x, nil, nil, 0)), Flatten(GroupNorm(x, 4, nil, nil, 0)), 0, t)
+}
+
+func TestNormBackwardSuccess(t *testing.T) {
+	x := Normal(1, 2, []int{3, 4, 2, 2})
+	xData := Flatten(x)
+	gamma := Flatten(Normal(1, 1, []int{1, 4}))
+	beta := Flatten(Normal(0, 1, []int{1, 4}))
+	layer := Flatten(Normal(1, 1, []int{1, 16}))
+	layerBeta := make([]float64, 16)
+	weight := Flatten(Normal(0, 1, x.Shape))
+	grad := Unflatten(weight, x.Shape)
+	eps := 1e-5
+	cases := []struct {
+		forward  func(x Tensor, gamma, beta []float64) Tensor
+		backward func(x, grad Tensor, gamma []float64) (Tensor, []float64, []float64)
+		gamma    []float64
+		beta     []float64
+	}{
+		{func(x Tensor, gamma, beta []float64) Tensor {
+			z, _, _ := BatchNorm(x, gamma, beta, eps)
+			return z
+		}, func(x, grad Tensor, gamma []float64) (Tensor, []float64, []float64) {
+			return BatchNormBackward(x, grad, gamma, eps)
+		}, gamma, beta},
+		{func(x Tensor, gamma, beta []float64) Tensor {
+			return LayerNorm(x, gamma, beta, eps)
+		}, func(x, grad Tensor, gamma []float64) (Tensor, []float64, []float64) {
+			return LayerNormBackward(x, grad, gamma, eps)
+		}, layer, layerBeta},
+		{func(x Tensor, gamma, beta []float64) Tensor {
+			return GroupNorm(x, 2, gamma, beta, eps)
+		}, func(x, grad Tensor, gamma []float64) (Tensor, []float64, []float64) {
+			return GroupNormBackward(x, grad, 2, gamma, eps)
+		}, gamma, beta},
+		{func(x Tensor, gamma, beta []float64) Tensor {
+			return InstanceNorm(x, gamma, beta, eps)
+		}, func(x, grad Tensor, gamma []float64) (Tensor, []float64, []float64) {
+			return InstanceNormBackward(x, grad, gamma, eps)
+		}, gamma, beta},
+	}
+	for _, c := range cases {
+		f := func() []float64 {
+			return Flatten(c.forward(Unflatten(xData, x.Shape), c.gamma, c.beta))
+		}
+		gx, dgamma, dbeta := c.backward(x, grad, c.gamma)
+		ExpNearCheck1D(Flatten(gx), numGrad(xData, weight, f), 1e-5, t)
+		ExpNearCheck1D(dgamma, numGrad(c.gamma, weight, f), 1e-5, t)
+		ExpNearCheck1D(dbeta, numGrad(c.beta, weight, f), 1e-5, t)
+	}
+	mean, variance := []float64{0, 1, 2, 3}, []float64{1, 2, 3, 4}
+	f := func() []float64 {
+		return Flatten(BatchNormInference(Unflatten(xData, x.Shape), mean, variance, gamma, beta, eps))
+	}
+	gx, dgamma, dbeta := BatchNormInferenceBackward(x, grad, mean, variance, gamma, eps)
+	ExpNearCheck1D(Flatten(gx), numGrad(xData, weight, f), 1e-5, t)
+	ExpNearCheck1D(dgamma, numGrad(gamma, weight, f), 1e-5, t)
+	ExpNearCheck1D(dbeta, numGrad(beta, weight, f), 1e-5, t)
+}
@@ -0,0 +1,367 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"math"
+)
+
+// Pooling windows use Stride, Padding and Dilation of ConvParam. A zero
+// Stride defaults to the kernel size. Padded positions never win a max and
+// count as zeros for averages.
+
+func poolParam(k int, param ConvParam) ConvParam {
+	if param.Stride == 0 {
+		param.Stride = k
+	}
+	return convParam(param)
+}
+
+func make3DInt(n, c, l int) [][][]int {
+	z := make([][][]int, n)
+	for i := range z {
+		z[i] = make([][]int, c)
+		for j := range z[i] {
+			z[i][j] = make([]int, l)
+		}
+	}
+	return z
+}
+
+func make4DInt(n, c, h, w int) [][][][]int {
+	z := make([][][][]int, n)
+	for i := range z {
+		z[i] = make3DInt(c, h, w)
+	}
+	return z
+}
+
+// MaxPool1D pools input [N, C, L] and returns the output together with the
+// position of every maximum in the input row.
+func MaxPool1D(input [][][]float64, k int, param ConvParam) ([][][]float64, [][][]int) {
+	param = poolParam(k, param)
+	n, c, length := Shape3D(input)
+	padL, padR := convPad(length, k, param)
+	out := convOutSize(length, k, padL, padR, param)
+	output := make3D(n, c, out)
+	index := make3DInt(n, c, out)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for o := 0; o < out; o++ {
+				max := math.Inf(-1)
+				arg := -1
+				for j := 0; j < k; j++ {
+					pos := o*param.Stride - padL + j*param.Dilation
+					if pos >= 0 && pos < length && input[b][ch][pos] > max {
+						max = input[b][ch][pos]
+						arg = pos
+					}
+				}
+				output[b][ch][o] = max
+				index[b][ch][o] = arg
+			}
+		}
+	})
+	return output, index
+}
+
+func MaxPool1DBackward(gradOut [][][]float64, index [][][]int, length int) [][][]float64 {
+	n, c, _ := Shape3D(gradOut)
+	gradIn := make3D(n, c, length)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for o, g := range gradOut[b][ch] {
+				if index[b][ch][o] >= 0 {
+					gradIn[b][ch][index[b][ch][o]] += g
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+func AvgPool1D(input [][][]float64, k int, param ConvParam) [][][]float64 {
+	param = poolParam(k, param)
+	n, c, length := Shape3D(input)
+	padL, padR := convPad(length, k, param)
+	out := convOutSize(length, k, padL, padR, param)
+	output := make3D(n, c, out)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for o := 0; o < out; o++ {
+				sum := 0.0
+				for j := 0; j < k; j++ {
+					pos := o*param.Stride - padL + j*param.Dilation
+					if pos >= 0 && pos < length {
+						sum += input[b][ch][pos]
+					}
+				}
+				output[b][ch][o] = sum / float64(k)
+			}
+		}
+	})
+	return output
+}
+
+func AvgPool1DBackward(gradOut [][][]float64, k, length int, param ConvParam) [][][]float64 {
+	param = poolParam(k, param)
+	n, c, out := Shape3D(gradOut)
+	padL, _ := convPad(length, k, param)
+	gradIn := make3D(n, c, length)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for o := 0; o < out; o++ {
+				g := gradOut[b][ch][o] / float64(k)
+				for j := 0; j < k; j++ {
+					pos := o*param.Stride - padL + j*param.Dilation
+					if pos >= 0 && pos < length {
+						gradIn[b][ch][pos] += g
+					}
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+// MaxPool2D pools input [N, C, H, W] with a kh x kw window. The returned
+// indices are y*W+x positions in the input plane.
+func MaxPool2D(input [][][][]float64, kh, kw int, param ConvParam) ([][][][]float64, [][][][]int) {
+	paramH := poolParam(kh, param)
+	paramW := poolParam(kw, param)
+	n, c, h, w := Shape4D(input)
+	padT, padB := convPad(h, kh, paramH)
+	padL, padR := convPad(w, kw, paramW)
+	outH := convOutSize(h, kh, padT, padB, paramH)
+	outW := convOutSize(w, kw, padL, padR, paramW)
+	output := make4D(n, c, outH, outW)
+	index := make4DInt(n, c, outH, outW)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			plane := input[b][ch]
+			for oy := 0; oy < outH; oy++ {
+				for ox := 0; ox < outW; ox++ {
+					max := math.Inf(-1)
+					arg := -1
+					for i := 0; i < kh; i++ {
+						y := oy*paramH.Stride - padT + i*paramH.Dilation
+						if y < 0 || y >= h {
+							continue
+						}
+						for j := 0; j < kw; j++ {
+							x := ox*paramW.Stride - padL + j*paramW.Dilation
+							if x >= 0 && x < w && plane[y][x] > max {
+								max = plane[y][x]
+								arg = y*w + x
+							}
+						}
+					}
+					output[b][ch][oy][ox] = max
+					index[b][ch][oy][ox] = arg
+				}
+			}
+		}
+	})
+	return output, index
+}
+
+func MaxPool2DBackward(gradOut [][][][]float64, index [][][][]int, h, w int) [][][][]float64 {
+	n, c, _, _ := Shape4D(gradOut)
+	gradIn := make4D(n, c, h, w)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for oy := range gradOut[b][ch] {
+				for ox, g := range gradOut[b][ch][oy] {
+					if arg := index[b][ch][oy][ox]; arg >= 0 {
+						gradIn[b][ch][arg/w][arg%w] += g
+					}
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+func AvgPool2D(input [][][][]float64, kh, kw int, param ConvParam) [][][][]float64 {
+	paramH := poolParam(kh, param)
+	paramW := poolParam(kw, param)
+	n, c, h, w := Shape4D(input)
+	padT, padB := convPad(h, kh, paramH)
+	padL, padR := convPad(w, kw, paramW)
+	outH := convOutSize(h, kh, padT, padB, paramH)
+	outW := convOutSize(w, kw, padL, padR, paramW)
+	output := make4D(n, c, outH, outW)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			plane := input[b][ch]
+			for oy := 0; oy < outH; oy++ {
+				for ox := 0; ox < outW; ox++ {
+					sum := 0.0
+					for i := 0; i < kh; i++ {
+						y := oy*paramH.Stride - padT + i*paramH.Dilation
+						if y < 0 || y >= h {
+							continue
+						}
+						for j := 0; j < kw; j++ {
+							x := ox*paramW.Stride - padL + j*paramW.Dilation
+							if x >= 0 && x < w {
+								sum += plane[y][x]
+							}
+						}
+					}
+					output[b][ch][oy][ox] = sum / float64(kh*kw)
+				}
+			}
+		}
+	})
+	return output
+}
+
+func AvgPool2DBackward(gradOut [][][][]float64, kh, kw, h, w int, param ConvParam) [][][][]float64 {
+	paramH := poolParam(kh, param)
+	paramW := poolParam(kw, param)
+	n, c, outH, outW := Shape4D(gradOut)
+	padT, _ := convPad(h, kh, paramH)
+	padL, _ := convPad(w, kw, paramW)
+	gradIn := make4D(n, c, h, w)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			plane := gradIn[b][ch]
+			for oy := 0; oy < outH; oy++ {
+				for ox := 0; ox < outW; ox++ {
+					g := gradOut[b][ch][oy][ox] / float64(kh*kw)
+					for i := 0; i < kh; i++ {
+						y := oy*paramH.Stride - padT + i*paramH.Dilation
+						if y < 0 || y >= h {
+							continue
+						}
+						for j := 0; j < kw; j++ {
+							x := ox*paramW.Stride - padL + j*paramW.Dilation
+							if x >= 0 && x < w {
+								plane[y][x] += g
+							}
+						}
+					}
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+// adaptiveBin returns the input range [start, end) pooled into output i.
+func adaptiveBin(i, in, out int) (int, int) {
+	start := i * in / out
+	end := ((i+1)*in + out - 1) / out
+	return start, end
+}
+
+func AdaptiveAvgPool1D(input [][][]float64, out int) [][][]float64 {
+	n, c, length := Shape3D(input)
+	output := make3D(n, c, out)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for o := 0; o < out; o++ {
+				start, end := adaptiveBin(o, length, out)
+				sum := 0.0
+				for pos := start; pos < end; pos++ {
+					sum += input[b][ch][pos]
+				}
+				output[b][ch][o] = sum / float64(end-start)
+			}
+		}
+	})
+	return output
+}
+
+func AdaptiveAvgPool1DBackward(gradOut [][][]float64, length int) [][][]float64 {
+	n, c, out := Shape3D(gradOut)
+	gradIn := make3D(n, c, length)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for o := 0; o < out; o++ {
+				start, end := adaptiveBin(o, length, out)
+				g := gradOut[b][ch][o] / float64(end-start)
+				for pos := start; pos < end; pos++ {
+					gradIn[b][ch][pos] += g
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+func AdaptiveAvgPool2D(input [][][][]float64, outH, outW int) [][][][]float64 {
+	n, c, h, w := Shape4D(input)
+	output := make4D(n, c, outH, outW)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for oy := 0; oy < outH; oy++ {
+				y0, y1 := adaptiveBin(oy, h, outH)
+				for ox := 0; ox < outW; ox++ {
+					x0, x1 := adaptiveBin(ox, w, outW)
+					sum := 0.0
+					for y := y0; y < y1; y++ {
+						for x := x0; x < x1; x++ {
+							sum += input[b][ch][y][x]
+						}
+					}
+					output[b][ch][oy][ox] = sum / float64((y1-y0)*(x1-x0))
+				}
+			}
+		}
+	})
+	return output
+}
+
+func AdaptiveAvgPool2DBackward(gradOut [][][][]float64, h, w int) [][][][]float64 {
+	n, c, outH, outW := Shape4D(gradOut)
+	gradIn := make4D(n, c, h, w)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for oy := 0; oy < outH; oy++ {
+				y0, y1 := adaptiveBin(oy, h, outH)
+				for ox := 0; ox < outW; ox++ {
+					x0, x1 := adaptiveBin(ox, w, outW)
+					g := gradOut[b][ch][oy][ox] / float64((y1-y0)*(x1-x0))
+					for y := y0; y < y1; y++ {
+						for x := x0; x < x1; x++ {
+							gradIn[b][ch][y][x] += g
+						}
+					}
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+func GlobalAvgPool1D(input [][][]float64) [][][]float64 {
+	return AdaptiveAvgPool1D(input, 1)
+}
+
+func GlobalAvgPool2D(input [][][][]float64) [][][][]float64 {
+	return AdaptiveAvgPool2D(input, 1, 1)
+}
+
+func GlobalMaxPool1D(input [][][]float64) ([][][]float64, [][][]int) {
+	_, _, length := Shape3D(input)
+	return MaxPool1D(input, length, ConvParam{})
+}
+
+func GlobalMaxPool2D(input [][][][]float64) ([][][][]float64, [][][][]int) {
+	_, _, h, w := Shape4D(input)
+	return MaxPool2D(input, h, w, ConvParam{})
+}
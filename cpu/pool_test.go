@@ -0,0 +1,97 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"testing"
+)
+
+func TestMaxPool1DSuccess(t *testing.T) {
+	x3d := [][][]float64{{{1, 5, 2, 4, 3}}}
+	z, index := MaxPool1D(x3d, 2, ConvParam{Padding: 1})
+	ExpCheck1D(z[0][0], []float64{1, 5, 4}, t)
+	if index[0][0][0] != 0 || index[0][0][1] != 1 || index[0][0][2] != 3 {
+		t.Fatal("failed Test!")
+	}
+	gradIn := MaxPool1DBackward([][][]float64{{{1, 2, 3}}}, index, 5)
+	ExpCheck1D(gradIn[0][0], []float64{1, 2, 0, 3, 0}, t)
+	ExpCheck1D(AvgPool1D(x3d, 2, ConvParam{Padding: 1})[0][0], []float64{0.5, 3.5, 3.5}, t)
+	ExpCheck1D(AvgPool1DBackward([][][]float64{{{2, 2, 2}}}, 2, 5, ConvParam{Padding: 1})[0][0], []float64{1, 1, 1, 1, 1}, t)
+}
+
+func TestMaxPool2DSuccess(t *testing.T) {
+	x4d := [][][][]float64{{{
+		{1, 2, 5, 0},
+		{3, 4, 1, 1},
+		{0, 0, 2, 2},
+		{7, 0, 2, 9},
+	}}}
+	z, index := MaxPool2D(x4d, 2, 2, ConvParam{})
+	ExpCheck4D(z, [][][][]float64{{{{4, 5}, {7, 9}}}}, t)
+	gradIn := MaxPool2DBackward([][][][]float64{{{{1, 2}, {3, 4}}}}, index, 4, 4)
+	ExpCheck4D(gradIn, [][][][]float64{{{
+		{0, 0, 2, 0},
+		{0, 1, 0, 0},
+		{0, 0, 0, 0},
+		{3, 0, 0, 4},
+	}}}, t)
+	ExpCheck4D(AvgPool2D(x4d, 2, 2, ConvParam{}), [][][][]float64{{{{2.5, 1.75}, {1.75, 3.75}}}}, t)
+	zGlobal, _ := GlobalMaxPool2D(x4d)
+	ExpCheck4D(zGlobal, [][][][]float64{{{{9}}}}, t)
+}
+
+func TestPool2DBackwardSuccess(t *testing.T) {
+	param := ConvParam{Stride: 2, Padding: 1}
+	x := Normal(0, 1, []int{2, 3, 5, 6})
+	out := AvgPool2D(x.CPU4D, 3, 3, param)
+	n, c, h, w := Shape4D(out)
+	shape := []int{n, c, h, w}
+	weight := Flatten(Normal(0, 1, shape))
+	xData := Flatten(x)
+	f := func() []float64 {
+		xt := Unflatten(xData, x.Shape)
+		return Flatten(Tensor{CPU4D: AvgPool2D(xt.CPU4D, 3, 3, param), Shape: shape})
+	}
+	gradIn := AvgPool2DBackward(Unflatten(weight, shape).CPU4D, 3, 3, 5, 6, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradIn, Shape: x.Shape}), numGrad(xData, weight, f), 1e-6, t)
+
+	f = func() []float64 {
+		xt := Unflatten(xData, x.Shape)
+		z, _ := MaxPool2D(xt.CPU4D, 3, 3, param)
+		return Flatten(Tensor{CPU4D: z, Shape: shape})
+	}
+	_, index := MaxPool2D(x.CPU4D, 3, 3, param)
+	gradIn = MaxPool2DBackward(Unflatten(weight, shape).CPU4D, index, 5, 6)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradIn, Shape: x.Shape}), numGrad(xData, weight, f), 1e-6, t)
+}
+
+func TestAdaptiveAvgPoolSuccess(t *testing.T) {
+	x3d := [][][]float64{{{1, 2, 3, 4, 5}}}
+	ExpCheck1D(AdaptiveAvgPool1D(x3d, 3)[0][0], []float64{1.5, 3, 4.5}, t)
+	ExpCheck1D(AdaptiveAvgPool1DBackward([][][]float64{{{2, 3, 2}}}, 5)[0][0], []float64{1, 2, 1, 2, 1}, t)
+	ExpCheck1D(GlobalAvgPool1D(x3d)[0][0], []float64{3}, t)
+
+	x := Normal(0, 1, []int{2, 2, 5, 7})
+	weight := Flatten(Normal(0, 1, []int{2, 2, 3, 4}))
+	xData := Flatten(x)
+	f := func() []float64 {
+		xt := Unflatten(xData, x.Shape)
+		return Flatten(Tensor{CPU4D: AdaptiveAvgPool2D(xt.CPU4D, 3, 4), Shape: []int{2, 2, 3, 4}})
+	}
+	gradIn := AdaptiveAvgPool2DBackward(Unflatten(weight, []int{2, 2, 3, 4}).CPU4D, 5, 7)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradIn, Shape: x.Shape}), numGrad(xData, weight, f), 1e-6, t)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: GlobalAvgPool2D(x.CPU4D), Shape: []int{2, 2, 1, 1}}),
+		Flatten(Tensor{CPU4D: AdaptiveAvgPool2D(x.CPU4D, 1, 1), Shape: []int{2, 2, 1, 1}}), 1e-12, t)
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+	"math"
+	"math/rand"
+)
+
+func random(shape []int, fn func() float64) Tensor {
+	z := make([]float64, Size(shape))
+	for i := range z {
+		z[i] = fn()
+	}
+	return Unflatten(z, shape)
+}
+
+func Uniform(low, high float64, shape []int) Tensor {
+	if high < low {
+		log.Fatal("Uniform.high must not be below low")
+	}
+	return random(shape, func() float64 {
+		return low + rand.Float64()*(high-low)
+	})
+}
+
+func Normal(mean, std float64, shape []int) Tensor {
+	if std < 0 {
+		log.Fatal("Normal.std must not be negative")
+	}
+	return random(shape, func() float64 {
+		return mean + rand.NormFloat64()*std
+	})
+}
+
+// TruncatedNormal redraws values that fall more than two standard
+// deviations away from the mean.
+func TruncatedNormal(mean, std float64, shape []int) Tensor {
+	if std < 0 {
+		log.Fatal("TruncatedNormal.std must not be negative")
+	}
+	return random(shape, func() float64 {
+		for {
+			v := rand.NormFloat64()
+			if v >= -2 && v <= 2 {
+				return mean + v*std
+			}
+		}
+	})
+}
+
+func Bernoulli(p float64, shape []int) Tensor {
+	if p < 0 || p > 1 {
+		log.Fatal("Bernoulli.p must be in [0, 1]")
+	}
+	return random(shape, func() float64 {
+		if rand.Float64() < p {
+			return 1
+		}
+		return 0
+	})
+}
+
+func Poisson(lambda float64, shape []int) Tensor {
+	if lambda < 0 {
+		log.Fatal("Poisson.lambda must not be negative")
+	}
+	return random(shape, func() float64 {
+		return poisson(lambda)
+	})
+}
+
+func poisson(lambda float64) float64 {
+	if lambda < 30 {
+		// Knuth
+		l := math.Exp(-lambda)
+		k := 0.0
+		p := rand.Float64()
+		for p > l {
+			k++
+			p *= rand.Float64()
+		}
+		return k
+	}
+	// transformed rejection with squeeze (Hormann, PTRS)
+	slam := math.Sqrt(lambda)
+	loglam := math.Log(lambda)
+	b := 0.931 + 2.53*slam
+	a := -0.059 + 0.02483*b
+	invalpha := 1.1239 + 1.1328/(b-3.4)
+	vr := 0.9277 - 3.6224/(b-2)
+	for {
+		u := rand.Float64() - 0.5
+		v := rand.Float64()
+		us := 0.5 - math.Abs(u)
+		k := math.Floor((2*a/us+b)*u + lambda + 0.43)
+		if us >= 0.07 && v <= vr {
+			return k
+		}
+		if k < 0 || (us < 0.013 && v > us) {
+			continue
+		}
+		lg, _ := math.Lgamma(k + 1)
+		if math.Log(v)+math.Log(invalpha)-math.Log(a/(us*us)+b) <= -lambda+k*loglam-lg {
+			return k
+		}
+	}
+}
+
+// Gamma draws from the gamma distribution with shape k and scale theta.
+func Gamma(k, theta float64, shape []int) Tensor {
+	if k <= 0 || theta <= 0 {
+		log.Fatal("Gamma.k and theta must be positive")
+	}
+	return random(shape, func() float64 {
+		return gamma(k) * theta
+	})
+}
+
+func gamma(k float64) float64 {
+	// Marsaglia and Tsang
+	if k < 1 {
+		return gamma(k+1) * math.Pow(rand.Float64(), 1/k)
+	}
+	d := k - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rand.Float64()
+		if u < 1-0.0331*x*x*x*x {
+			return d * v
+		}
+		if math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
+
+func Beta(a, b float64, shape []int) Tensor {
+	if a <= 0 || b <= 0 {
+		log.Fatal("Beta.a and b must be positive")
+	}
+	return random(shape, func() float64 {
+		x := gamma(a)
+		y := gamma(b)
+		return x / (x + y)
+	})
+}
+
+func Multinomial(probs [][]float64, samples int) [][]float64 {
+	n, m := Shape2D(probs)
+	z := make2D(n, samples)
+	for i := 0; i < n; i++ {
+		cdf := make([]float64, m)
+		sum := 0.0
+		for j := 0; j < m; j++ {
+			if probs[i][j] < 0 {
+				log.Fatal("Multinomial.negative probability")
+			}
+			sum += probs[i][j]
+			cdf[j] = sum
+		}
+		for s := 0; s < samples; s++ {
+			u := rand.Float64() * sum
+			index := m - 1
+			for j := 0; j < m; j++ {
+				if u < cdf[j] {
+					index = j
+					break
+				}
+			}
+			z[i][s] = float64(index)
+		}
+	}
+	return z
+}
+
+func Permutation(n int) [][]float64 {
+	z := make2D(1, n)
+	for i, p := range rand.Perm(n) {
+		z[0][i] = float64(p)
+	}
+	return z
+}
+
+// Shuffle returns a copy of x whose slices along axis are randomly permuted.
+func Shuffle(x Tensor, axis int) Tensor {
+	if axis < 0 || axis >= len(x.Shape) {
+		log.Fatal("Shuffle.axis out of range")
+	}
+	data := Flatten(x)
+	z := make([]float64, len(data))
+	outer := Size(x.Shape[:axis])
+	inner := Size(x.Shape[axis+1:])
+	n := x.Shape[axis]
+	perm := rand.Perm(n)
+	for o := 0; o < outer; o++ {
+		for i, p := range perm {
+			dst := (o*n + i) * inner
+			src := (o*n + p) * inner
+			copy(z[dst:dst+inner], data[src:src+inner])
+		}
+	}
+	return Unflatten(z, x.Shape)
+}
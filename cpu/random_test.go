@@ -0,0 +1,140 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func mean(x []float64) float64 {
+	sum := 0.0
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+func ExpNearCheck(zReal, zExp, tol float64, t *testing.T) {
+	if math.Abs(zReal-zExp) > tol {
+		fmt.Println("Real:", zReal)
+		fmt.Println("Exp:", zExp)
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestUniformSuccess(t *testing.T) {
+	z := Uniform(-1, 3, []int{2, 3, 4, 5})
+	if len(z.CPU4D) != 2 || len(z.CPU4D[1][2][3]) != 5 {
+		t.Fatal("failed Test!")
+	}
+	for _, v := range Flatten(z) {
+		if v < -1 || v >= 3 {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestNormalSuccess(t *testing.T) {
+	z := Flatten(Normal(3, 2, []int{200, 100}))
+	ExpNearCheck(mean(z), 3, 0.1, t)
+}
+
+func TestTruncatedNormalSuccess(t *testing.T) {
+	for _, v := range Flatten(TruncatedNormal(1, 0.5, []int{50, 50})) {
+		if v < 0 || v > 2 {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestPoissonSuccess(t *testing.T) {
+	ExpNearCheck(mean(Flatten(Poisson(4, []int{100, 100}))), 4, 0.1, t)
+	ExpNearCheck(mean(Flatten(Poisson(100, []int{100, 100}))), 100, 0.5, t)
+}
+
+func TestGammaBetaSuccess(t *testing.T) {
+	ExpNearCheck(mean(Flatten(Gamma(0.5, 2, []int{100, 100}))), 1, 0.1, t)
+	ExpNearCheck(mean(Flatten(Gamma(3, 2, []int{100, 100}))), 6, 0.2, t)
+	z := Flatten(Beta(2, 6, []int{100, 100}))
+	for _, v := range z {
+		if v < 0 || v > 1 {
+			t.Fatal("failed Test!")
+		}
+	}
+	ExpNearCheck(mean(z), 0.25, 0.01, t)
+}
+
+func TestMultinomialSuccess(t *testing.T) {
+	probs := [][]float64{
+		{0, 1, 0},
+		{0, 0, 2},
+	}
+	zExp := [][]float64{
+		{1, 1, 1, 1},
+		{2, 2, 2, 2},
+	}
+	zReal := Multinomial(probs, 4)
+	ExpCheck(zReal, zExp, t)
+}
+
+func TestShuffleSuccess(t *testing.T) {
+	x := Unflatten([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}, []int{2, 6})
+	z := Shuffle(x, 1)
+	for i := range z.CPU {
+		for j := range z.CPU[i] {
+			// columns move together
+			if z.CPU[i][j] != z.CPU[0][j]+float64(6*i) {
+				t.Fatal("failed Test!")
+			}
+		}
+	}
+	sorted := append([]float64{}, z.CPU[0]...)
+	sort.Float64s(sorted)
+	ExpCheck1D(sorted, x.CPU[0], t)
+	perm := Permutation(5)[0]
+	sort.Float64s(perm)
+	ExpCheck1D(perm, []float64{0, 1, 2, 3, 4}, t)
+}
+
+func TestRandomCheckSuccess(t *testing.T) {
+	// every call has an invalid parameter; log.Fatal exits, so each runs in
+	// a child process
+	calls := []func(){
+		func() { Uniform(1, 0, []int{2, 2}) },
+		func() { Normal(0, -1, []int{2, 2}) },
+		func() { TruncatedNormal(0, -1, []int{2, 2}) },
+		func() { Bernoulli(1.5, []int{2, 2}) },
+		func() { Poisson(-1, []int{2, 2}) },
+		func() { Gamma(0, 1, []int{2, 2}) },
+		func() { Beta(1, -1, []int{2, 2}) },
+	}
+	if i, err := strconv.Atoi(os.Getenv("GMAT_FATAL")); err == nil {
+		calls[i]()
+		return
+	}
+	for i := range calls {
+		cmd := exec.Command(os.Args[0], "-test.run=TestRandomCheckSuccess")
+		cmd.Env = append(os.Environ(), "GMAT_FATAL="+strconv.Itoa(i))
+		if err := cmd.Run(); err == nil {
+			t.Fatal("failed Test!", i)
+		}
+	}
+}
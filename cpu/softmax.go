@@ -0,0 +1,190 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+	"math"
+)
+
+func axisIndex(shape []int, axis int) int {
+	if axis < 0 {
+		axis += len(shape)
+	}
+	if axis < 0 || axis >= len(shape) {
+		log.Fatal("axis out of range")
+	}
+	return axis
+}
+
+// axisLayout splits shape around axis into outer, axis and inner sizes of
+// the row-major layout. Negative axes count from the end.
+func axisLayout(shape []int, axis int) (int, int, int) {
+	axis = axisIndex(shape, axis)
+	return Size(shape[:axis]), shape[axis], Size(shape[axis+1:])
+}
+
+// reduceAxis calls fn for every slice along axis with the slice values
+// and writes the result of fn back into out with the same stride.
+func reduceAxis(data, out []float64, outer, dim, inner int, fn func(x, z []float64)) {
+	x := make([]float64, dim)
+	z := make([]float64, len(out)/(outer*inner))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			for d := 0; d < dim; d++ {
+				x[d] = data[(o*dim+d)*inner+i]
+			}
+			fn(x, z)
+			for d := range z {
+				out[(o*len(z)+d)*inner+i] = z[d]
+			}
+		}
+	}
+}
+
+func logSumExp(x []float64) float64 {
+	max := math.Inf(-1)
+	for _, v := range x {
+		if v > max {
+			max = v
+		}
+	}
+	if math.IsInf(max, 0) {
+		return max
+	}
+	sum := 0.0
+	for _, v := range x {
+		sum += math.Exp(v - max)
+	}
+	return max + math.Log(sum)
+}
+
+func Softmax(x Tensor, axis int) Tensor {
+	outer, dim, inner := axisLayout(x.Shape, axis)
+	out := make([]float64, Size(x.Shape))
+	reduceAxis(Flatten(x), out, outer, dim, inner, func(x, z []float64) {
+		lse := logSumExp(x)
+		for d, v := range x {
+			z[d] = math.Exp(v - lse)
+		}
+	})
+	return Unflatten(out, x.Shape)
+}
+
+func LogSoftmax(x Tensor, axis int) Tensor {
+	outer, dim, inner := axisLayout(x.Shape, axis)
+	out := make([]float64, Size(x.Shape))
+	reduceAxis(Flatten(x), out, outer, dim, inner, func(x, z []float64) {
+		lse := logSumExp(x)
+		for d, v := range x {
+			z[d] = v - lse
+		}
+	})
+	return Unflatten(out, x.Shape)
+}
+
+// LogSumExp reduces axis to size 1.
+func LogSumExp(x Tensor, axis int) Tensor {
+	outer, dim, inner := axisLayout(x.Shape, axis)
+	shape := append([]int{}, x.Shape...)
+	shape[axisIndex(x.Shape, axis)] = 1
+	out := make([]float64, outer*inner)
+	reduceAxis(Flatten(x), out, outer, dim, inner, func(x, z []float64) {
+		z[0] = logSumExp(x)
+	})
+	return Unflatten(out, shape)
+}
+
+// SoftmaxBackward returns the input gradient given the softmax output y.
+func SoftmaxBackward(y, grad Tensor, axis int) Tensor {
+	outer, dim, inner := axisLayout(y.Shape, axis)
+	yData := Flatten(y)
+	gData := Flatten(grad)
+	out := make([]float64, len(yData))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			dot := 0.0
+			for d := 0; d < dim; d++ {
+				idx := (o*dim+d)*inner + i
+				dot += yData[idx] * gData[idx]
+			}
+			for d := 0; d < dim; d++ {
+				idx := (o*dim+d)*inner + i
+				out[idx] = yData[idx] * (gData[idx] - dot)
+			}
+		}
+	}
+	return Unflatten(out, y.Shape)
+}
+
+// LogSoftmaxBackward returns the input gradient given the log-softmax
+// output y.
+func LogSoftmaxBackward(y, grad Tensor, axis int) Tensor {
+	outer, dim, inner := axisLayout(y.Shape, axis)
+	yData := Flatten(y)
+	gData := Flatten(grad)
+	out := make([]float64, len(yData))
+	for o := 0; o < outer; o++ {
+		for i := 0; i < inner; i++ {
+			sum := 0.0
+			for d := 0; d < dim; d++ {
+				sum += gData[(o*dim+d)*inner+i]
+			}
+			for d := 0; d < dim; d++ {
+				idx := (o*dim+d)*inner + i
+				out[idx] = gData[idx] - math.Exp(yData[idx])*sum
+			}
+		}
+	}
+	return Unflatten(out, y.Shape)
+}
+
+// SoftmaxCrossEntropy returns the mean cross-entropy between the softmax
+// of logits [N, C] and the target distribution (e.g. one-hot rows), and
+// the gradient with respect to logits.
+func SoftmaxCrossEntropy(logits, target [][]float64) (float64, [][]float64) {
+	n, c := Shape2D(logits)
+	if tn, tc := Shape2D(target); tn != n || tc != c {
+		log.Fatal("SoftmaxCrossEntropy.mismatch logits and target shape")
+	}
+	loss := 0.0
+	grad := make2D(n, c)
+	for i := 0; i < n; i++ {
+		lse := logSumExp(logits[i])
+		for j := 0; j < c; j++ {
+			if target[i][j] != 0 {
+				loss -= target[i][j] * (logits[i][j] - lse)
+			}
+			grad[i][j] = (math.Exp(logits[i][j]-lse) - target[i][j]) / float64(n)
+		}
+	}
+	return loss / float64(n), grad
+}
+
+// SparseSoftmaxCrossEntropy is SoftmaxCrossEntropy with class indices.
+func SparseSoftmaxCrossEntropy(logits [][]float64, labels []int) (float64, [][]float64) {
+	n, c := Shape2D(logits)
+	if len(labels) != n {
+		log.Fatal("SparseSoftmaxCrossEntropy.mismatch logits and labels size")
+	}
+	target := make2D(n, c)
+	for i, label := range labels {
+		if label < 0 || label >= c {
+			log.Fatal("SparseSoftmaxCrossEntropy.label out of range")
+		}
+		target[i][label] = 1
+	}
+	return SoftmaxCrossEntropy(logits, target)
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSoftmaxSuccess(t *testing.T) {
+	x := Tensor{CPU: [][]float64{{1000, 1000}, {0, math.Log(3)}}, Shape: []int{2, 2}}
+	z := Softmax(x, 1)
+	ExpNearCheck1D(Flatten(z), []float64{0.5, 0.5, 0.25, 0.75}, 1e-12, t)
+	z = Softmax(x, 0)
+	ExpNearCheck1D(Flatten(z), []float64{1, 1, 0, 0}, 1e-12, t)
+	z = LogSumExp(x, -1)
+	if z.Shape[0] != 2 || z.Shape[1] != 1 {
+		t.Fatal("failed Test!")
+	}
+	ExpNearCheck1D(Flatten(z), []float64{1000 + math.Log(2), math.Log(4)}, 1e-9, t)
+	z = LogSoftmax(x, 1)
+	ExpNearCheck1D(Flatten(z), []float64{-math.Log(2), -math.Log(2), -math.Log(4), math.Log(0.75)}, 1e-12, t)
+}
+
+func TestSoftmaxBackwardSuccess(t *testing.T) {
+	x := Normal(0, 1, []int{2, 3, 4})
+	weight := Flatten(Normal(0, 1, x.Shape))
+	grad := Unflatten(weight, x.Shape)
+	xData := Flatten(x)
+	for _, axis := range []int{0, 1, 2} {
+		f := func() []float64 {
+			return Flatten(Softmax(Unflatten(xData, x.Shape), axis))
+		}
+		gradIn := SoftmaxBackward(Softmax(x, axis), grad, axis)
+		ExpNearCheck1D(Flatten(gradIn), numGrad(xData, weight, f), 1e-6, t)
+		f = func() []float64 {
+			return Flatten(LogSoftmax(Unflatten(xData, x.Shape), axis))
+		}
+		gradIn = LogSoftmaxBackward(LogSoftmax(x, axis), grad, axis)
+		ExpNearCheck1D(Flatten(gradIn), numGrad(xData, weight, f), 1e-6, t)
+	}
+}
+
+func TestSoftmaxCrossEntropySuccess(t *testing.T) {
+	logits := [][]float64{{1, 2, 3}, {1000, 0, -1000}}
+	loss, grad := SparseSoftmaxCrossEntropy(logits, []int{2, 0})
+	p := Softmax(Tensor{CPU: logits, Shape: []int{2, 3}}, 1).CPU
+	ExpNearCheck(loss, -math.Log(p[0][2])/2, 1e-12, t)
+	ExpNearCheck1D(grad[0], []float64{p[0][0] / 2, p[0][1] / 2, (p[0][2] - 1) / 2}, 1e-12, t)
+	lossOneHot, gradOneHot := SoftmaxCrossEntropy(logits, [][]float64{{0, 0, 1}, {1, 0, 0}})
+	ExpNearCheck(lossOneHot, loss, 0, t)
+	ExpNearCheck1D(gradOneHot[1], grad[1], 0, t)
+	if math.IsNaN(loss) || math.IsInf(loss, 0) {
+		t.Fatal("failed Test!")
+	}
+}
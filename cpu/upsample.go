@@ -0,0 +1,225 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"log"
+	"math"
+)
+
+func convTransposeOutSize(length, k, outputPadding int, param ConvParam) int {
+	return (length-1)*param.Stride - 2*param.Padding + param.Dilation*(k-1) + 1 + outputPadding
+}
+
+func convTransposeCheck(param ConvParam, outputPadding int) {
+	if param.Padding < 0 {
+		log.Fatal("ConvTranspose2D.same padding is not supported")
+	}
+	if outputPadding < 0 || outputPadding >= param.Stride {
+		log.Fatal("ConvTranspose2D.output padding must be smaller than stride")
+	}
+}
+
+// ConvTranspose2D is the adjoint of Conv2D. input is [N, C_in, H, W] and
+// kernel is [C_in, C_out/groups, kh, kw]; outputPadding adds rows and
+// columns at the bottom and right of the output.
+func ConvTranspose2D(input, kernel [][][][]float64, bias []float64, param ConvParam, outputPadding int) [][][][]float64 {
+	param = convParam(param)
+	convTransposeCheck(param, outputPadding)
+	_, _, h, w := Shape4D(input)
+	_, _, kh, kw := Shape4D(kernel)
+	outH := convTransposeOutSize(h, kh, outputPadding, param)
+	outW := convTransposeOutSize(w, kw, outputPadding, param)
+	output := Conv2DBackwardInput(input, kernel, outH, outW, param)
+	if bias != nil {
+		for b := range output {
+			for c := range output[b] {
+				for y := range output[b][c] {
+					for x := range output[b][c][y] {
+						output[b][c][y][x] += bias[c]
+					}
+				}
+			}
+		}
+	}
+	return output
+}
+
+func ConvTranspose2DBackwardInput(gradOut, kernel [][][][]float64, param ConvParam) [][][][]float64 {
+	return Conv2D(gradOut, kernel, nil, param)
+}
+
+func ConvTranspose2DBackwardKernel(input, gradOut [][][][]float64, kh, kw int, param ConvParam) [][][][]float64 {
+	return Conv2DBackwardKernel(gradOut, input, kh, kw, param)
+}
+
+func ConvTranspose2DBackwardBias(gradOut [][][][]float64) []float64 {
+	return Conv2DBackwardBias(gradOut)
+}
+
+func UpsampleNearest(input [][][][]float64, scaleH, scaleW int) [][][][]float64 {
+	n, c, h, w := Shape4D(input)
+	output := make4D(n, c, h*scaleH, w*scaleW)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for y := range output[b][ch] {
+				src := input[b][ch][y/scaleH]
+				dst := output[b][ch][y]
+				for x := range dst {
+					dst[x] = src[x/scaleW]
+				}
+			}
+		}
+	})
+	return output
+}
+
+func UpsampleNearestBackward(gradOut [][][][]float64, scaleH, scaleW int) [][][][]float64 {
+	n, c, h, w := Shape4D(gradOut)
+	gradIn := make4D(n, c, h/scaleH, w/scaleW)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			for y := range gradOut[b][ch] {
+				dst := gradIn[b][ch][y/scaleH]
+				for x, v := range gradOut[b][ch][y] {
+					dst[x/scaleW] += v
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+// bilinearIndex returns for every output position the two source indices
+// and the weight of the second one.
+func bilinearIndex(in, out int, alignCorners bool) ([]int, []int, []float64) {
+	i0 := make([]int, out)
+	i1 := make([]int, out)
+	lambda := make([]float64, out)
+	for i := 0; i < out; i++ {
+		var src float64
+		if alignCorners {
+			if out > 1 {
+				src = float64(i) * float64(in-1) / float64(out-1)
+			}
+		} else {
+			src = (float64(i)+0.5)*float64(in)/float64(out) - 0.5
+			if src < 0 {
+				src = 0
+			}
+		}
+		i0[i] = int(math.Floor(src))
+		if i0[i] > in-1 {
+			i0[i] = in - 1
+		}
+		i1[i] = i0[i] + 1
+		if i1[i] > in-1 {
+			i1[i] = in - 1
+		}
+		lambda[i] = src - float64(i0[i])
+	}
+	return i0, i1, lambda
+}
+
+func UpsampleBilinear(input [][][][]float64, outH, outW int, alignCorners bool) [][][][]float64 {
+	n, c, h, w := Shape4D(input)
+	y0, y1, ly := bilinearIndex(h, outH, alignCorners)
+	x0, x1, lx := bilinearIndex(w, outW, alignCorners)
+	output := make4D(n, c, outH, outW)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			src := input[b][ch]
+			for y := 0; y < outH; y++ {
+				for x := 0; x < outW; x++ {
+					top := src[y0[y]][x0[x]]*(1-lx[x]) + src[y0[y]][x1[x]]*lx[x]
+					bottom := src[y1[y]][x0[x]]*(1-lx[x]) + src[y1[y]][x1[x]]*lx[x]
+					output[b][ch][y][x] = top*(1-ly[y]) + bottom*ly[y]
+				}
+			}
+		}
+	})
+	return output
+}
+
+func UpsampleBilinearBackward(gradOut [][][][]float64, h, w int, alignCorners bool) [][][][]float64 {
+	n, c, outH, outW := Shape4D(gradOut)
+	y0, y1, ly := bilinearIndex(h, outH, alignCorners)
+	x0, x1, lx := bilinearIndex(w, outW, alignCorners)
+	gradIn := make4D(n, c, h, w)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			dst := gradIn[b][ch]
+			for y := 0; y < outH; y++ {
+				for x := 0; x < outW; x++ {
+					g := gradOut[b][ch][y][x]
+					dst[y0[y]][x0[x]] += g * (1 - ly[y]) * (1 - lx[x])
+					dst[y0[y]][x1[x]] += g * (1 - ly[y]) * lx[x]
+					dst[y1[y]][x0[x]] += g * ly[y] * (1 - lx[x])
+					dst[y1[y]][x1[x]] += g * ly[y] * lx[x]
+				}
+			}
+		}
+	})
+	return gradIn
+}
+
+// PixelShuffle rearranges [N, C*r*r, H, W] into [N, C, H*r, W*r].
+func PixelShuffle(input [][][][]float64, r int) [][][][]float64 {
+	n, c, h, w := Shape4D(input)
+	if c%(r*r) != 0 {
+		log.Fatal("PixelShuffle.channels not divisible by r*r")
+	}
+	output := make4D(n, c/(r*r), h*r, w*r)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c; ch++ {
+			oc, i, j := ch/(r*r), ch%(r*r)/r, ch%r
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					output[b][oc][y*r+i][x*r+j] = input[b][ch][y][x]
+				}
+			}
+		}
+	})
+	return output
+}
+
+// PixelUnshuffle rearranges [N, C, H*r, W*r] into [N, C*r*r, H, W].
+func PixelUnshuffle(input [][][][]float64, r int) [][][][]float64 {
+	n, c, h, w := Shape4D(input)
+	if h%r != 0 || w%r != 0 {
+		log.Fatal("PixelUnshuffle.size not divisible by r")
+	}
+	output := make4D(n, c*r*r, h/r, w/r)
+	parallel(n, func(b int) {
+		for ch := 0; ch < c*r*r; ch++ {
+			ic, i, j := ch/(r*r), ch%(r*r)/r, ch%r
+			for y := 0; y < h/r; y++ {
+				for x := 0; x < w/r; x++ {
+					output[b][ch][y][x] = input[b][ic][y*r+i][x*r+j]
+				}
+			}
+		}
+	})
+	return output
+}
+
+// the two shuffles are permutations and inverse to each other
+func PixelShuffleBackward(gradOut [][][][]float64, r int) [][][][]float64 {
+	return PixelUnshuffle(gradOut, r)
+}
+
+func PixelUnshuffleBackward(gradOut [][][][]float64, r int) [][][][]float64 {
+	return PixelShuffle(gradOut, r)
+}
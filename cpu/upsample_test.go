@@ -0,0 +1,116 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+package cpu
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func dot1D(x, y []float64) float64 {
+	sum := 0.0
+	for i := range x {
+		sum += x[i] * y[i]
+	}
+	return sum
+}
+
+func TestConvTranspose2DSuccess(t *testing.T) {
+	x4d := [][][][]float64{{{
+		{1, 2},
+		{3, 4},
+	}}}
+	k4d := [][][][]float64{{{
+		{1, 1},
+		{1, 1},
+	}}}
+	zExp := [][][][]float64{{{
+		{1, 1, 2, 2},
+		{1, 1, 2, 2},
+		{3, 3, 4, 4},
+		{3, 3, 4, 4},
+	}}}
+	ExpCheck4D(ConvTranspose2D(x4d, k4d, nil, ConvParam{Stride: 2}, 0), zExp, t)
+	zPad := ConvTranspose2D(x4d, k4d, []float64{1}, ConvParam{Stride: 2, Padding: 1}, 1)
+	ExpCheck4D(zPad, [][][][]float64{{{{2, 3, 3}, {4, 5, 5}, {4, 5, 5}}}}, t)
+}
+
+func TestConvTranspose2DOutputPaddingSuccess(t *testing.T) {
+	// output padding of at least the stride is rejected even below the
+	// dilation; log.Fatal exits, so the call runs in a child process
+	if os.Getenv("GMAT_FATAL") == "1" {
+		x := [][][][]float64{{{{1, 2}, {3, 4}}}}
+		ConvTranspose2D(x, x, nil, ConvParam{Stride: 1, Dilation: 2}, 1)
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=TestConvTranspose2DOutputPaddingSuccess")
+	cmd.Env = append(os.Environ(), "GMAT_FATAL=1")
+	if err := cmd.Run(); err == nil {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestConvTranspose2DBackwardSuccess(t *testing.T) {
+	param := ConvParam{Stride: 2, Padding: 1, Groups: 2}
+	x := Normal(0, 1, []int{2, 4, 3, 3})
+	k := Normal(0, 1, []int{4, 3, 3, 3})
+	out := ConvTranspose2D(x.CPU4D, k.CPU4D, nil, param, 1)
+	n, c, h, w := Shape4D(out)
+	shape := []int{n, c, h, w}
+	weight := Flatten(Normal(0, 1, shape))
+	gradOut := Unflatten(weight, shape).CPU4D
+	xData := Flatten(x)
+	kData := Flatten(k)
+	f := func() []float64 {
+		xt := Unflatten(xData, x.Shape)
+		kt := Unflatten(kData, k.Shape)
+		return Flatten(Tensor{CPU4D: ConvTranspose2D(xt.CPU4D, kt.CPU4D, nil, param, 1), Shape: shape})
+	}
+	gradIn := ConvTranspose2DBackwardInput(gradOut, k.CPU4D, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradIn, Shape: x.Shape}), numGrad(xData, weight, f), 1e-6, t)
+	gradK := ConvTranspose2DBackwardKernel(x.CPU4D, gradOut, 3, 3, param)
+	ExpNearCheck1D(Flatten(Tensor{CPU4D: gradK, Shape: k.Shape}), numGrad(kData, weight, f), 1e-6, t)
+}
+
+func TestUpsampleNearestSuccess(t *testing.T) {
+	x4d := [][][][]float64{{{{1, 2}}}}
+	zExp := [][][][]float64{{{{1, 1, 1, 2, 2, 2}, {1, 1, 1, 2, 2, 2}}}}
+	ExpCheck4D(UpsampleNearest(x4d, 2, 3), zExp, t)
+	ExpCheck4D(UpsampleNearestBackward(zExp, 2, 3), [][][][]float64{{{{6, 12}}}}, t)
+}
+
+func TestUpsampleBilinearSuccess(t *testing.T) {
+	x4d := [][][][]float64{{{{0, 3}}}}
+	ExpCheck4D(UpsampleBilinear(x4d, 1, 4, true), [][][][]float64{{{{0, 1, 2, 3}}}}, t)
+	ExpCheck4D(UpsampleBilinear(x4d, 1, 4, false), [][][][]float64{{{{0, 0.75, 2.25, 3}}}}, t)
+	for _, align := range []bool{true, false} {
+		x := Normal(0, 1, []int{1, 2, 3, 4})
+		gradOut := Normal(0, 1, []int{1, 2, 5, 7})
+		// adjoint: <up(x), g> == <x, up^T(g)>
+		lhs := dot1D(Flatten(Tensor{CPU4D: UpsampleBilinear(x.CPU4D, 5, 7, align), Shape: gradOut.Shape}), Flatten(gradOut))
+		rhs := dot1D(Flatten(x), Flatten(Tensor{CPU4D: UpsampleBilinearBackward(gradOut.CPU4D, 3, 4, align), Shape: x.Shape}))
+		ExpNearCheck1D([]float64{lhs}, []float64{rhs}, 1e-9, t)
+	}
+}
+
+func TestPixelShuffleSuccess(t *testing.T) {
+	x4d := [][][][]float64{{{{1}}, {{2}}, {{3}}, {{4}}}}
+	zExp := [][][][]float64{{{{1, 2}, {3, 4}}}}
+	ExpCheck4D(PixelShuffle(x4d, 2), zExp, t)
+	ExpCheck4D(PixelUnshuffle(zExp, 2), x4d, t)
+	x := Normal(0, 1, []int{2, 8, 3, 2})
+	ExpCheck4D(PixelShuffleBackward(PixelShuffle(x.CPU4D, 2), 2), x.CPU4D, t)
+}
@@ -0,0 +1,84 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package data
+
+import (
+	"errors"
+	"github.com/kuroko1t/gmat"
+	"io"
+)
+
+const cifarImage = 3 * 32 * 32
+
+// ReadCIFAR reads CIFAR binary records until EOF. CIFAR-10 records have one
+// label byte and CIFAR-100 records a coarse and a fine one; label selects
+// which of the labelBytes to keep. Pixels are scaled to [0, 1] and stay in
+// the channel-major order of the file.
+func ReadCIFAR(r io.Reader, labelBytes, label int) (pixels, labels []float64, err error) {
+	if label < 0 || label >= labelBytes {
+		return nil, nil, errors.New("data.ReadCIFAR: label out of range")
+	}
+	record := make([]byte, labelBytes+cifarImage)
+	for {
+		if _, err := io.ReadFull(r, record); err == io.EOF {
+			return pixels, labels, nil
+		} else if err != nil {
+			return nil, nil, err
+		}
+		labels = append(labels, float64(record[label]))
+		for _, b := range record[labelBytes:] {
+			pixels = append(pixels, float64(b)/255)
+		}
+	}
+}
+
+func loadCIFAR(labelBytes, label int, flatten bool, paths []string) (x, y gmat.Tensor, err error) {
+	var pixels, labels []float64
+	for _, path := range paths {
+		r, f, err := open(path)
+		if err != nil {
+			return x, y, err
+		}
+		p, l, err := ReadCIFAR(r, labelBytes, label)
+		f.Close()
+		if err != nil {
+			return x, y, err
+		}
+		pixels, labels = append(pixels, p...), append(labels, l...)
+	}
+	n := len(labels)
+	if flatten {
+		x = gmat.FromSliceNoCopy(pixels, n, cifarImage)
+	} else {
+		x = gmat.FromSliceNoCopy(pixels, n, 3, 32, 32)
+	}
+	return x, gmat.FromSliceNoCopy(labels, n, 1), nil
+}
+
+// LoadCIFAR10 reads the batch files of CIFAR-10 into images [N, 3, 32, 32],
+// or [N, 3072] with flatten, and labels [N, 1].
+func LoadCIFAR10(flatten bool, paths ...string) (x, y gmat.Tensor, err error) {
+	return loadCIFAR(1, 0, flatten, paths)
+}
+
+// LoadCIFAR100 is LoadCIFAR10 for CIFAR-100, keeping the fine labels or
+// the coarse ones.
+func LoadCIFAR100(fine, flatten bool, paths ...string) (x, y gmat.Tensor, err error) {
+	if fine {
+		return loadCIFAR(2, 1, flatten, paths)
+	}
+	return loadCIFAR(2, 0, flatten, paths)
+}
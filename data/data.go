@@ -0,0 +1,136 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package data feeds datasets to the trainer in minibatches.
+package data
+
+import (
+	"github.com/kuroko1t/gmat"
+	"log"
+	"sort"
+)
+
+// Dataset is a list of samples, each a flat feature and target slice of
+// fixed shapes.
+type Dataset interface {
+	Len() int
+	Sample(i int) (x, y []float64)
+	// Shapes returns the shapes of one sample; y is nil without targets.
+	Shapes() (x, y []int)
+}
+
+// TensorDataset slices tensors along their first axis.
+type TensorDataset struct {
+	x, y           []float64
+	xShape, yShape []int
+	n              int
+}
+
+// NewTensorDataset copies x [N, ...] and y [N, ...] to the host. y may be
+// an empty Tensor for unlabelled data.
+func NewTensorDataset(x, y gmat.Tensor) *TensorDataset {
+	if len(x.Shape) < 2 {
+		log.Fatal("data.NewTensorDataset: x must have a batch axis")
+	}
+	d := &TensorDataset{x: gmat.ToSlice(x), xShape: x.Shape[1:], n: x.Shape[0]}
+	if len(y.Shape) > 0 {
+		if y.Shape[0] != d.n {
+			log.Fatal("data.NewTensorDataset: x and y sizes mismatch")
+		}
+		d.y, d.yShape = gmat.ToSlice(y), y.Shape[1:]
+	}
+	return d
+}
+
+func (d *TensorDataset) Len() int {
+	return d.n
+}
+
+func (d *TensorDataset) Sample(i int) (x, y []float64) {
+	xs := len(d.x) / d.n
+	x = d.x[i*xs : (i+1)*xs]
+	if d.y != nil {
+		ys := len(d.y) / d.n
+		y = d.y[i*ys : (i+1)*ys]
+	}
+	return x, y
+}
+
+func (d *TensorDataset) Shapes() (x, y []int) {
+	return d.xShape, d.yShape
+}
+
+// OneHot encodes labels as rows of a [N, classes] tensor.
+func OneHot(labels []int, classes int) gmat.Tensor {
+	data := make([]float64, len(labels)*classes)
+	for i, l := range labels {
+		if l < 0 || l >= classes {
+			log.Fatal("data.OneHot: label out of range")
+		}
+		data[i*classes+l] = 1
+	}
+	return gmat.FromSliceNoCopy(data, len(labels), classes)
+}
+
+// Labels returns labels as an integer label tensor [N, 1].
+func Labels(labels []int) gmat.Tensor {
+	data := make([]float64, len(labels))
+	for i, l := range labels {
+		data[i] = float64(l)
+	}
+	return gmat.FromSliceNoCopy(data, len(labels), 1)
+}
+
+// LabelEncoder maps class names to the labels 0..len(Classes)-1 in sorted
+// order of the names.
+type LabelEncoder struct {
+	Classes []string
+	index   map[string]int
+}
+
+func NewLabelEncoder(values []string) *LabelEncoder {
+	e := &LabelEncoder{index: map[string]int{}}
+	for _, v := range values {
+		if _, ok := e.index[v]; !ok {
+			e.index[v] = 0
+			e.Classes = append(e.Classes, v)
+		}
+	}
+	sort.Strings(e.Classes)
+	for i, c := range e.Classes {
+		e.index[c] = i
+	}
+	return e
+}
+
+func (e *LabelEncoder) Encode(values []string) []int {
+	labels := make([]int, len(values))
+	for i, v := range values {
+		l, ok := e.index[v]
+		if !ok {
+			log.Fatal("data.LabelEncoder.Encode: unknown class " + v)
+		}
+		labels[i] = l
+	}
+	return labels
+}
+
+func (e *LabelEncoder) Decode(labels []int) []string {
+	values := make([]string, len(labels))
+	for i, l := range labels {
+		values[i] = e.Classes[l]
+	}
+	return values
+}
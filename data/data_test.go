@@ -0,0 +1,156 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package data
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/trainer"
+	"testing"
+)
+
+// dataset returns n samples [1, 2, 2] whose values and labels are the index.
+func dataset(n int) *TensorDataset {
+	x := make([]float64, n*4)
+	y := make([]float64, n)
+	for i := range y {
+		for j := 0; j < 4; j++ {
+			x[i*4+j] = float64(i)
+		}
+		y[i] = float64(i)
+	}
+	return NewTensorDataset(gmat.FromSlice(x, n, 1, 2, 2), gmat.FromSlice(y, n, 1))
+}
+
+// epoch returns the labels of every batch of one epoch.
+func epoch(it trainer.Iterator, t *testing.T) [][]float64 {
+	it.Reset()
+	var labels [][]float64
+	for {
+		b, ok := it.Next()
+		if !ok {
+			return labels
+		}
+		x, y := gmat.ToSlice(b.X), gmat.ToSlice(b.Y)
+		if b.X.Shape[0] != len(y) || b.X.Shape[3] != 2 || x[len(x)-1] != y[len(y)-1] {
+			t.Fatal("failed Test!")
+		}
+		labels = append(labels, y)
+	}
+}
+
+func TestDataLoaderSuccess(t *testing.T) {
+	loader := NewDataLoader(dataset(10), 4, false, 0)
+	labels := epoch(loader, t)
+	if len(labels) != 3 || loader.Len() != 3 || len(labels[2]) != 2 || labels[1][0] != 4 || labels[2][1] != 9 {
+		t.Fatal("failed Test!")
+	}
+	loader.DropLast = true
+	if labels = epoch(loader, t); len(labels) != 2 || loader.Len() != 2 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestDataLoaderShuffleSuccess(t *testing.T) {
+	loader := NewDataLoader(dataset(10), 3, true, 1)
+	prefetch := NewDataLoader(dataset(10), 3, true, 1)
+	prefetch.Prefetch = 2
+	first := epoch(loader, t)
+	seen := map[float64]bool{}
+	for _, b := range first {
+		for _, l := range b {
+			seen[l] = true
+		}
+	}
+	if len(seen) != 10 {
+		t.Fatal("failed Test!")
+	}
+	// the same seed gives the same orders with and without prefetching
+	if !equal(first, epoch(prefetch, t)) {
+		t.Fatal("failed Test!")
+	}
+	second := epoch(loader, t)
+	if equal(first, second) || !equal(second, epoch(prefetch, t)) {
+		t.Fatal("failed Test!")
+	}
+	// an epoch left unfinished does not block the next one
+	prefetch.Reset()
+	prefetch.Next()
+	if len(epoch(prefetch, t)) != 4 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestDataLoaderCloseSuccess(t *testing.T) {
+	loader := NewDataLoader(dataset(10), 3, false, 0)
+	loader.Prefetch = 2
+	loader.Next()
+	loader.Close()
+	if _, ok := loader.Next(); ok || loader.done != nil {
+		t.Fatal("failed Test!")
+	}
+	loader.Reset()
+	if len(epoch(loader, t)) != 4 {
+		t.Fatal("failed Test!")
+	}
+	// a literal loader shuffles like one built with seed 0
+	literal := &DataLoader{Dataset: dataset(10), BatchSize: 3, Shuffle: true}
+	if !equal(epoch(literal, t), epoch(NewDataLoader(dataset(10), 3, true, 0), t)) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func equal(x, y [][]float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if len(x[i]) != len(y[i]) {
+			return false
+		}
+		for j := range x[i] {
+			if x[i][j] != y[i][j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestUnlabelledSuccess(t *testing.T) {
+	loader := NewDataLoader(NewTensorDataset(gmat.Ones(5, 3), gmat.Tensor{}), 2, false, 0)
+	b, _ := loader.Next()
+	if b.X.Shape[0] != 2 || b.X.Shape[1] != 3 || len(b.Y.Shape) != 0 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestOneHotSuccess(t *testing.T) {
+	z := gmat.ToSlice(OneHot([]int{2, 0}, 3))
+	if !equal([][]float64{z}, [][]float64{{0, 0, 1, 1, 0, 0}}) {
+		t.Fatal("failed Test!")
+	}
+	e := NewLabelEncoder([]string{"dog", "cat", "dog", "bird"})
+	labels := e.Encode([]string{"cat", "bird", "dog"})
+	if len(e.Classes) != 3 || labels[0] != 1 || labels[1] != 0 || labels[2] != 2 {
+		t.Fatal("failed Test!")
+	}
+	if e.Decode(labels)[2] != "dog" || gmat.ToSlice(Labels(labels))[0] != 1 {
+		t.Fatal("failed Test!")
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package data
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/trainer"
+	"log"
+	"math/rand"
+)
+
+// DataLoader batches a dataset into trainer.Batch tensors [N, ...]. It is a
+// trainer.Iterator: every Reset starts a new epoch with a new order drawn
+// from the seeded generator when Shuffle is set. A DataLoader built as a
+// literal shuffles with seed 0.
+type DataLoader struct {
+	Dataset   Dataset
+	BatchSize int
+	Shuffle   bool
+	// DropLast skips the final batch when it is smaller than BatchSize.
+	DropLast bool
+	// Prefetch is the number of batches loaded ahead by a goroutine; 0
+	// loads every batch in Next.
+	Prefetch int
+
+	rng     *rand.Rand
+	order   []int
+	pos     int
+	started bool
+	batches chan trainer.Batch
+	done    chan struct{}
+}
+
+var _ trainer.Iterator = (*DataLoader)(nil)
+
+func NewDataLoader(dataset Dataset, batchSize int, shuffle bool, seed int64) *DataLoader {
+	if batchSize <= 0 {
+		log.Fatal("data.NewDataLoader: batch size must be positive")
+	}
+	return &DataLoader{Dataset: dataset, BatchSize: batchSize, Shuffle: shuffle, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Len returns the number of batches of an epoch.
+func (l *DataLoader) Len() int {
+	n := l.Dataset.Len()
+	if l.DropLast {
+		return n / l.BatchSize
+	}
+	return (n + l.BatchSize - 1) / l.BatchSize
+}
+
+func (l *DataLoader) Next() (trainer.Batch, bool) {
+	if !l.started {
+		l.start()
+	}
+	if l.batches != nil {
+		b, ok := <-l.batches
+		return b, ok
+	}
+	if l.pos >= l.Len() {
+		return trainer.Batch{}, false
+	}
+	l.pos++
+	return l.load(l.pos - 1), true
+}
+
+func (l *DataLoader) Reset() {
+	l.stop()
+	l.started = false
+}
+
+// Close stops the prefetching of an unfinished epoch, which then ends; Reset
+// starts a new one.
+func (l *DataLoader) Close() {
+	l.stop()
+	l.started, l.pos = true, l.Len()
+}
+
+func (l *DataLoader) start() {
+	l.started, l.pos = true, 0
+	if l.Shuffle && l.rng == nil {
+		l.rng = rand.New(rand.NewSource(0))
+	}
+	if l.Shuffle {
+		l.order = l.rng.Perm(l.Dataset.Len())
+	} else {
+		l.order = make([]int, l.Dataset.Len())
+		for i := range l.order {
+			l.order[i] = i
+		}
+	}
+	if l.Prefetch > 0 {
+		l.batches, l.done = make(chan trainer.Batch, l.Prefetch), make(chan struct{})
+		go l.produce(l.Len(), l.batches, l.done)
+	}
+}
+
+func (l *DataLoader) produce(n int, batches chan<- trainer.Batch, done <-chan struct{}) {
+	defer close(batches)
+	for i := 0; i < n; i++ {
+		select {
+		case batches <- l.load(i):
+		case <-done:
+			return
+		}
+	}
+}
+
+// stop ends the producer of an unfinished epoch and waits for it.
+func (l *DataLoader) stop() {
+	if l.done == nil {
+		return
+	}
+	close(l.done)
+	for range l.batches {
+	}
+	l.batches, l.done = nil, nil
+}
+
+// load stacks the samples of batch i.
+func (l *DataLoader) load(i int) trainer.Batch {
+	end := (i + 1) * l.BatchSize
+	if end > len(l.order) {
+		end = len(l.order)
+	}
+	indices := l.order[i*l.BatchSize : end]
+	xShape, yShape := l.Dataset.Shapes()
+	var xs, ys []float64
+	for _, j := range indices {
+		x, y := l.Dataset.Sample(j)
+		xs, ys = append(xs, x...), append(ys, y...)
+	}
+	b := trainer.Batch{X: gmat.FromSliceNoCopy(xs, append([]int{len(indices)}, xShape...)...)}
+	if yShape != nil {
+		b.Y = gmat.FromSliceNoCopy(ys, append([]int{len(indices)}, yShape...)...)
+	}
+	return b
+}
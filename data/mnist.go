@@ -0,0 +1,161 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package data
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/kuroko1t/gmat"
+	"io"
+	"math"
+	"os"
+)
+
+// open returns a reader of the file at path, decompressed when it starts
+// with the gzip magic number.
+func open(path string) (io.Reader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := bufio.NewReader(f)
+	magic, err := r.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return z, f, nil
+	}
+	return r, f, nil
+}
+
+// maxElements bounds the element count a file header may declare.
+const maxElements = math.MaxInt32
+
+// idxChunk is the number of elements ReadIDX decodes at a time, so that a
+// truncated file fails before its declared size is allocated.
+const idxChunk = 1 << 16
+
+// ReadIDX reads an IDX file of any element type and rank, returning its
+// values and dimensions.
+func ReadIDX(r io.Reader) ([]float64, []int, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, nil, err
+	}
+	if header[0] != 0 || header[1] != 0 {
+		return nil, nil, errors.New("data.ReadIDX: bad magic number")
+	}
+	width := map[byte]int{0x08: 1, 0x09: 1, 0x0B: 2, 0x0C: 4, 0x0D: 4, 0x0E: 8}[header[2]]
+	if width == 0 {
+		return nil, nil, fmt.Errorf("data.ReadIDX: unknown type 0x%02x", header[2])
+	}
+	shape := make([]int, header[3])
+	var size uint64 = 1
+	for i := range shape {
+		var d uint32
+		if err := binary.Read(r, binary.BigEndian, &d); err != nil {
+			return nil, nil, err
+		}
+		// d < 2^32 and size <= maxElements, so the product cannot overflow
+		if size *= uint64(d); size > maxElements {
+			return nil, nil, errors.New("data.ReadIDX: too many elements")
+		}
+		shape[i] = int(d)
+	}
+	n := idxChunk
+	if int(size) < n {
+		n = int(size)
+	}
+	data := make([]float64, 0, n)
+	raw := make([]byte, n*width)
+	for len(data) < int(size) {
+		if left := int(size) - len(data); left < n {
+			n = left
+		}
+		if _, err := io.ReadFull(r, raw[:n*width]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, nil, err
+		}
+		for i := 0; i < n; i++ {
+			data = append(data, idxValue(header[2], raw[i*width:(i+1)*width]))
+		}
+	}
+	return data, shape, nil
+}
+
+// idxValue decodes the big endian element b of IDX type kind.
+func idxValue(kind byte, b []byte) float64 {
+	switch kind {
+	case 0x08:
+		return float64(b[0])
+	case 0x09:
+		return float64(int8(b[0]))
+	case 0x0B:
+		return float64(int16(binary.BigEndian.Uint16(b)))
+	case 0x0C:
+		return float64(int32(binary.BigEndian.Uint32(b)))
+	case 0x0D:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b))
+}
+
+// LoadIDX reads the IDX file at path, which may be gzip compressed.
+func LoadIDX(path string) ([]float64, []int, error) {
+	r, f, err := open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return ReadIDX(r)
+}
+
+// LoadMNIST reads MNIST images and labels in IDX format. Pixels are scaled
+// to [0, 1] and the images are [N, 1, H, W], or [N, H*W] with flatten; the
+// labels are [N, 1].
+func LoadMNIST(images, labels string, flatten bool) (x, y gmat.Tensor, err error) {
+	pixels, shape, err := LoadIDX(images)
+	if err != nil {
+		return x, y, err
+	}
+	if len(shape) != 3 {
+		return x, y, errors.New("data.LoadMNIST: images must be [N, H, W]")
+	}
+	classes, lshape, err := LoadIDX(labels)
+	if err != nil {
+		return x, y, err
+	}
+	if len(lshape) != 1 || lshape[0] != shape[0] {
+		return x, y, errors.New("data.LoadMNIST: labels do not match images")
+	}
+	for i := range pixels {
+		pixels[i] /= 255
+	}
+	if flatten {
+		x = gmat.FromSliceNoCopy(pixels, shape[0], shape[1]*shape[2])
+	} else {
+		x = gmat.FromSliceNoCopy(pixels, shape[0], 1, shape[1], shape[2])
+	}
+	return x, gmat.FromSliceNoCopy(classes, shape[0], 1), nil
+}
@@ -0,0 +1,114 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package data
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kuroko1t/gmat"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestLoadMNISTSuccess(t *testing.T) {
+	x, y, err := LoadMNIST("testdata/images-idx3-ubyte.gz", "testdata/labels-idx1-ubyte", false)
+	if err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	if !equalShape(x.Shape, []int{3, 1, 2, 3}) || !equalShape(y.Shape, []int{3, 1}) {
+		t.Fatal("failed Test!", x.Shape)
+	}
+	if math.Abs(gmat.ToSlice(x)[10]-14.0/255) > 1e-12 || !equal([][]float64{gmat.ToSlice(y)}, [][]float64{{7, 2, 1}}) {
+		t.Fatal("failed Test!")
+	}
+	x, _, err = LoadMNIST("testdata/images-idx3-ubyte.gz", "testdata/labels-idx1-ubyte", true)
+	if err != nil || !equalShape(x.Shape, []int{3, 6}) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestReadIDXSuccess(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{0, 0, 0x0D, 2})
+	binary.Write(&b, binary.BigEndian, []uint32{1, 2})
+	binary.Write(&b, binary.BigEndian, []float32{-1.5, 2})
+	data, shape, err := ReadIDX(&b)
+	if err != nil || !equalShape(shape, []int{1, 2}) || data[0] != -1.5 || data[1] != 2 {
+		t.Fatal("failed Test!")
+	}
+	b.Reset()
+	b.Write([]byte{0, 0, 0x0B, 1})
+	binary.Write(&b, binary.BigEndian, []uint32{2})
+	binary.Write(&b, binary.BigEndian, []int16{-300, 7})
+	if data, _, err = ReadIDX(&b); err != nil || data[0] != -300 || data[1] != 7 {
+		t.Fatal("failed Test!")
+	}
+	if _, _, err = ReadIDX(bytes.NewReader([]byte{1, 0, 8, 1})); err == nil {
+		t.Fatal("failed Test!")
+	}
+	// truncated data
+	if _, _, err = ReadIDX(bytes.NewReader([]byte{0, 0, 8, 1, 0, 0, 0, 2, 5})); err != io.ErrUnexpectedEOF {
+		t.Fatal("failed Test!", err)
+	}
+	if _, _, err = ReadIDX(bytes.NewReader([]byte{0, 0, 8, 1, 0x7f, 0xff, 0xff, 0xff})); err != io.ErrUnexpectedEOF {
+		t.Fatal("failed Test!", err)
+	}
+	// dimensions whose product overflows
+	b.Reset()
+	b.Write([]byte{0, 0, 8, 3})
+	binary.Write(&b, binary.BigEndian, []uint32{1 << 16, 1 << 16, 1 << 16})
+	if _, _, err = ReadIDX(&b); err == nil || err == io.ErrUnexpectedEOF {
+		t.Fatal("failed Test!", err)
+	}
+}
+
+func TestLoadCIFARSuccess(t *testing.T) {
+	x, y, err := LoadCIFAR10(false, "testdata/cifar10.bin")
+	if err != nil || !equalShape(x.Shape, []int{2, 3, 32, 32}) {
+		t.Fatal("failed Test!", err)
+	}
+	pixels := gmat.ToSlice(x)
+	if math.Abs(pixels[cifarImage+5]-105.0/255) > 1e-12 || pixels[1024] != 0 || !equal([][]float64{gmat.ToSlice(y)}, [][]float64{{3, 9}}) {
+		t.Fatal("failed Test!")
+	}
+	x, y, err = LoadCIFAR100(true, true, "testdata/cifar100.bin", "testdata/cifar100.bin")
+	if err != nil || !equalShape(x.Shape, []int{4, cifarImage}) || !equal([][]float64{gmat.ToSlice(y)}, [][]float64{{42, 99, 42, 99}}) {
+		t.Fatal("failed Test!", err)
+	}
+	if _, y, _ = LoadCIFAR100(false, true, "testdata/cifar100.bin"); !equal([][]float64{gmat.ToSlice(y)}, [][]float64{{4, 19}}) {
+		t.Fatal("failed Test!")
+	}
+	// a CIFAR-100 file read as CIFAR-10 does not split into whole records
+	if _, _, err = LoadCIFAR10(false, "testdata/cifar100.bin"); err == nil {
+		t.Fatal("failed Test!")
+	}
+}
+
+func equalShape(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
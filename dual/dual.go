@@ -0,0 +1,201 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package dual implements forward-mode differentiation over cpu tensors.
+// Every Dual carries a tangent next to its value, so a single pass yields
+// a Jacobian-vector product without recording a tape.
+package dual
+
+import (
+	"github.com/kuroko1t/gmat/cpu"
+	"log"
+)
+
+type Dual struct {
+	Value   cpu.Tensor
+	Tangent cpu.Tensor
+}
+
+func New(value, tangent cpu.Tensor) Dual {
+	if cpu.Size(value.Shape) != cpu.Size(tangent.Shape) {
+		log.Fatal("dual.mismatch value and tangent shape")
+	}
+	return Dual{Value: value, Tangent: tangent}
+}
+
+// Constant has a zero tangent.
+func Constant(value cpu.Tensor) Dual {
+	return Dual{Value: value, Tangent: cpu.Full(value.Shape, 0)}
+}
+
+// zip combines x and y elementwise; they must hold the same number of
+// elements.
+func zip(x, y cpu.Tensor, fn func(a, b float64) float64) cpu.Tensor {
+	a := cpu.Flatten(x)
+	b := cpu.Flatten(y)
+	if len(a) != len(b) {
+		log.Fatal("dual.mismatch input shape")
+	}
+	for i := range a {
+		a[i] = fn(a[i], b[i])
+	}
+	return cpu.Unflatten(a, x.Shape)
+}
+
+func mapTensor(x cpu.Tensor, fn func(float64) float64) cpu.Tensor {
+	a := cpu.Flatten(x)
+	for i := range a {
+		a[i] = fn(a[i])
+	}
+	return cpu.Unflatten(a, x.Shape)
+}
+
+func add(a, b float64) float64 {
+	return a + b
+}
+
+func sub(a, b float64) float64 {
+	return a - b
+}
+
+func mul(a, b float64) float64 {
+	return a * b
+}
+
+func Add(x, y Dual) Dual {
+	return Dual{zip(x.Value, y.Value, add), zip(x.Tangent, y.Tangent, add)}
+}
+
+func Sub(x, y Dual) Dual {
+	return Dual{zip(x.Value, y.Value, sub), zip(x.Tangent, y.Tangent, sub)}
+}
+
+// Mul follows the product rule d(xy) = dx y + x dy.
+func Mul(x, y Dual) Dual {
+	tangent := zip(zip(x.Tangent, y.Value, mul), zip(x.Value, y.Tangent, mul), add)
+	return Dual{zip(x.Value, y.Value, mul), tangent}
+}
+
+// Div follows d(x/y) = (dx - z dy) / y with z = x/y.
+func Div(x, y Dual) Dual {
+	z := zip(x.Value, y.Value, func(a, b float64) float64 {
+		return a / b
+	})
+	tangent := zip(zip(x.Tangent, zip(z, y.Tangent, mul), sub), y.Value, func(a, b float64) float64 {
+		return a / b
+	})
+	return Dual{z, tangent}
+}
+
+func MulE(x Dual, c float64) Dual {
+	scale := func(a float64) float64 {
+		return a * c
+	}
+	return Dual{mapTensor(x.Value, scale), mapTensor(x.Tangent, scale)}
+}
+
+func AddE(x Dual, c float64) Dual {
+	return Dual{mapTensor(x.Value, func(a float64) float64 {
+		return a + c
+	}), x.Tangent}
+}
+
+func wrap2D(x [][]float64) cpu.Tensor {
+	n, m := cpu.Shape2D(x)
+	return cpu.Tensor{CPU: x, Shape: []int{n, m}}
+}
+
+func Dot(x, y Dual) Dual {
+	value := cpu.Dot(x.Value.CPU, y.Value.CPU)
+	tangent := cpu.Add(cpu.Dot(x.Tangent.CPU, y.Value.CPU), cpu.Dot(x.Value.CPU, y.Tangent.CPU))
+	return Dual{wrap2D(value), wrap2D(tangent)}
+}
+
+func T(x Dual) Dual {
+	return Dual{wrap2D(cpu.T(x.Value.CPU)), wrap2D(cpu.T(x.Tangent.CPU))}
+}
+
+func Reshape(x Dual, shape []int) Dual {
+	return Dual{cpu.Reshape(x.Value, shape), cpu.Reshape(x.Tangent, shape)}
+}
+
+// Apply maps fn elementwise; dfn is its derivative.
+func Apply(x Dual, fn, dfn func(float64) float64) Dual {
+	tangent := zip(x.Tangent, x.Value, func(t, v float64) float64 {
+		return t * dfn(v)
+	})
+	return Dual{mapTensor(x.Value, fn), tangent}
+}
+
+// Activation applies a cpu activation f whose derivative at the input is
+// df, e.g. cpu.Tanh and cpu.TanhDerivative.
+func Activation(x Dual, f, df func(cpu.Tensor) cpu.Tensor) Dual {
+	return Dual{f(x.Value), zip(x.Tangent, df(x.Value), mul)}
+}
+
+func sum(x cpu.Tensor) float64 {
+	s := 0.0
+	for _, v := range cpu.Flatten(x) {
+		s += v
+	}
+	return s
+}
+
+// Sum reduces all elements to a [1, 1] tensor.
+func Sum(x Dual) Dual {
+	return Dual{cpu.Full([]int{1, 1}, sum(x.Value)), cpu.Full([]int{1, 1}, sum(x.Tangent))}
+}
+
+func Mean(x Dual) Dual {
+	return MulE(Sum(x), 1/float64(cpu.Size(x.Value.Shape)))
+}
+
+func SumRow(x Dual) Dual {
+	return Dual{wrap2D(cpu.SumRow(x.Value.CPU)), wrap2D(cpu.SumRow(x.Tangent.CPU))}
+}
+
+func SumCol(x Dual) Dual {
+	return Dual{wrap2D(cpu.SumCol(x.Value.CPU)), wrap2D(cpu.SumCol(x.Tangent.CPU))}
+}
+
+// JVP evaluates f at x and returns its value and the product of its
+// Jacobian with v.
+func JVP(f func(Dual) Dual, x, v cpu.Tensor) (cpu.Tensor, cpu.Tensor) {
+	z := f(New(x, v))
+	return z.Value, z.Tangent
+}
+
+// Jacobian returns the [outputs, inputs] Jacobian of f at x using one JVP
+// per input element, which is meant for small problems.
+func Jacobian(f func(Dual) Dual, x cpu.Tensor) [][]float64 {
+	size := cpu.Size(x.Shape)
+	var jacobian [][]float64
+	for j := 0; j < size; j++ {
+		basis := make([]float64, size)
+		basis[j] = 1
+		_, column := JVP(f, x, cpu.Unflatten(basis, x.Shape))
+		data := cpu.Flatten(column)
+		if jacobian == nil {
+			jacobian = make([][]float64, len(data))
+			for i := range jacobian {
+				jacobian[i] = make([]float64, size)
+			}
+		}
+		for i, v := range data {
+			jacobian[i][j] = v
+		}
+	}
+	return jacobian
+}
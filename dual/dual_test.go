@@ -0,0 +1,82 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package dual
+
+import (
+	"fmt"
+	"github.com/kuroko1t/gmat/cpu"
+	"math"
+	"testing"
+)
+
+func ExpNearCheck(zReal, zExp []float64, tol float64, t *testing.T) {
+	success := len(zReal) == len(zExp)
+	for i := range zExp {
+		if success && math.Abs(zExp[i]-zReal[i]) > tol {
+			success = false
+		}
+	}
+	if !success {
+		fmt.Println("Real:", zReal)
+		fmt.Println("Exp:", zExp)
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestJVPSuccess(t *testing.T) {
+	x := cpu.Unflatten([]float64{1, 2, 3}, []int{1, 3})
+	v := cpu.Unflatten([]float64{1, 0, -1}, []int{1, 3})
+	// f(x) = x*x / (x+1), f'(x) = (x^2 + 2x) / (x+1)^2
+	f := func(x Dual) Dual {
+		return Div(Mul(x, x), AddE(x, 1))
+	}
+	value, jvp := JVP(f, x, v)
+	ExpNearCheck(cpu.Flatten(value), []float64{0.5, 4.0 / 3, 9.0 / 4}, 1e-12, t)
+	ExpNearCheck(cpu.Flatten(jvp), []float64{3.0 / 4, 0, -15.0 / 16}, 1e-12, t)
+}
+
+func TestJacobianSuccess(t *testing.T) {
+	w := cpu.Normal(0, 1, []int{3, 4})
+	b := cpu.Normal(0, 1, []int{2, 4})
+	ones := cpu.Full([]int{2, 1}, 1)
+	f := func(x Dual) Dual {
+		h := Activation(Dot(x, Constant(w)), cpu.Tanh, cpu.TanhDerivative)
+		r := Reshape(SumRow(Mul(h, Constant(b))), []int{2, 2})
+		s := Apply(SumCol(Dot(x, T(x))), math.Sin, math.Cos)
+		m := Dot(Constant(ones), Dot(Mean(x), T(Constant(ones))))
+		return Add(Sub(r, Dot(s, T(s))), m)
+	}
+	x := cpu.Normal(0, 1, []int{2, 3})
+	jacobian := Jacobian(f, x)
+	// central differences on the primal values
+	data := cpu.Flatten(x)
+	eps := 1e-6
+	for j := range data {
+		orig := data[j]
+		data[j] = orig + eps
+		plus := cpu.Flatten(f(Constant(cpu.Unflatten(data, x.Shape))).Value)
+		data[j] = orig - eps
+		minus := cpu.Flatten(f(Constant(cpu.Unflatten(data, x.Shape))).Value)
+		data[j] = orig
+		column := make([]float64, len(plus))
+		got := make([]float64, len(plus))
+		for i := range plus {
+			column[i] = (plus[i] - minus[i]) / (2 * eps)
+			got[i] = jacobian[i][j]
+		}
+		ExpNearCheck(got, column, 1e-6, t)
+	}
+}
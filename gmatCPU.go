@@ -1,4 +1,6 @@
+//go:build !gpu
 // +build !gpu
+
 // Copyright 2018 kurosawa. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
@@ -19,6 +21,7 @@ package gmat
 import (
 	"github.com/kuroko1t/gmat/cpu"
 	"log"
+	"math"
 )
 
 type Tensor cpu.Tensor
@@ -27,6 +30,8 @@ func Make(shape []int) Tensor {
 	tensor := Tensor{Shape: shape}
 	if len(shape) == 2 {
 		tensor = Tensor(cpu.Make([]int{shape[0], shape[1]}))
+	} else if len(shape) == 3 {
+		tensor = Tensor(cpu.Make([]int{shape[0], shape[1], shape[2]}))
 	} else if len(shape) == 4 {
 		tensor = Tensor(cpu.Make([]int{shape[0], shape[1], shape[2], shape[3]}))
 	} else if len(shape) == 6 {
@@ -36,12 +41,85 @@ func Make(shape []int) Tensor {
 }
 
 func Make2DInitArray(x [][]float64) Tensor {
-	z := Tensor{CPU: x}
-	z.CPU = x
+	z := Tensor{}
+	z.CPU = cpu.MakeInit(len(x), len(x[0]), 0)
+	for i := range x {
+		copy(z.CPU[i], x[i])
+	}
 	z.Shape = []int{len(x), len(x[0])}
 	return z
 }
 
+func FromSlice(data []float64, shape ...int) Tensor {
+	return FromSliceNoCopy(append([]float64{}, data...), shape...)
+}
+
+// FromSliceNoCopy shares data with the returned tensor.
+func FromSliceNoCopy(data []float64, shape ...int) Tensor {
+	return Tensor(cpu.Unflatten(data, shape))
+}
+
+func ToSlice(x Tensor) []float64 {
+	return cpu.Flatten(cpu.Tensor(x))
+}
+
+func Zeros(shape ...int) Tensor {
+	return Tensor(cpu.Full(shape, 0))
+}
+
+func Ones(shape ...int) Tensor {
+	return Tensor(cpu.Full(shape, 1))
+}
+
+func Full(value float64, shape ...int) Tensor {
+	return Tensor(cpu.Full(shape, value))
+}
+
+func ZerosLike(x Tensor) Tensor {
+	return Zeros(x.Shape...)
+}
+
+func OnesLike(x Tensor) Tensor {
+	return Ones(x.Shape...)
+}
+
+func Eye(n int) Tensor {
+	z := Tensor{}
+	z.CPU = cpu.Eye(n, n)
+	z.Shape = []int{n, n}
+	return z
+}
+
+func Diag(x Tensor) Tensor {
+	z := Tensor{}
+	z.CPU = cpu.Diag(x.CPU)
+	z.Shape = []int{len(z.CPU), len(z.CPU[0])}
+	return z
+}
+
+func Arange(start, stop, step float64) Tensor {
+	z := cpu.Arange(start, stop, step)
+	return FromSliceNoCopy(z, 1, len(z))
+}
+
+func Linspace(start, stop float64, num int) Tensor {
+	return FromSliceNoCopy(cpu.Linspace(start, stop, num), 1, num)
+}
+
+func Tril(x Tensor, k int) Tensor {
+	z := Tensor{}
+	z.CPU = cpu.Tril(x.CPU, k)
+	z.Shape = x.Shape
+	return z
+}
+
+func Triu(x Tensor, k int) Tensor {
+	z := Tensor{}
+	z.CPU = cpu.Triu(x.CPU, k)
+	z.Shape = x.Shape
+	return z
+}
+
 func Trans2D(input Tensor, n int, c int) Tensor {
 	z := Tensor{}
 	z.CPU = cpu.Trans2D(input.CPU, n, c)
@@ -78,9 +156,7 @@ func Reshape2D6D(input [][]float64, reN int, reC int, reH int, reW int, reX int,
 }
 
 func Reshape4D(input Tensor, reX int, reY int) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Reshape4D(input.CPU4D, reX, reY)
-	return z
+	return wrap2D(cpu.Reshape4D(input.CPU4D, reX, reY))
 }
 
 func Reshape4D6D(input [][][][]float64, reN int, reC int, reH int, reW int, reX int, reY int) [][][][][][]float64 {
@@ -117,6 +193,66 @@ func Reshape4D6D(input [][][][]float64, reN int, reC int, reH int, reW int, reX
 	return result
 }
 
+func Reshape(x Tensor, shape []int) Tensor {
+	return Tensor(cpu.Reshape(cpu.Tensor(x), shape))
+}
+
+func Concat(xs []Tensor, axis int) Tensor {
+	ts := make([]cpu.Tensor, len(xs))
+	for i, x := range xs {
+		ts[i] = cpu.Tensor(x)
+	}
+	return Tensor(cpu.Concat(ts, axis))
+}
+
+func Split(x Tensor, sizes []int, axis int) []Tensor {
+	ts := cpu.Split(cpu.Tensor(x), sizes, axis)
+	zs := make([]Tensor, len(ts))
+	for i, t := range ts {
+		zs[i] = Tensor(t)
+	}
+	return zs
+}
+
+// Stack joins tensors of equal shape along a new first axis.
+func Stack(xs []Tensor) Tensor {
+	ys := make([]Tensor, len(xs))
+	for i, x := range xs {
+		ys[i] = Reshape(x, append([]int{1}, x.Shape...))
+	}
+	return Concat(ys, 0)
+}
+
+// Unstack is the inverse of Stack.
+func Unstack(x Tensor) []Tensor {
+	sizes := make([]int, x.Shape[0])
+	for i := range sizes {
+		sizes[i] = 1
+	}
+	zs := Split(x, sizes, 0)
+	for i := range zs {
+		zs[i] = Reshape(zs[i], x.Shape[1:])
+	}
+	return zs
+}
+
+func Gather(x Tensor, index []int) Tensor {
+	return wrap2D(cpu.Gather(x.CPU, index))
+}
+
+func ScatterAdd(x Tensor, index []int, src Tensor) Tensor {
+	return wrap2D(cpu.ScatterAdd(x.CPU, index, src.CPU))
+}
+
+// IndexAdd adds row i of src to row index[i] of x in place.
+func IndexAdd(x Tensor, index []int, src Tensor) {
+	cpu.IndexAdd(x.CPU, index, src.CPU)
+}
+
+func Permute(x Tensor, axes []int) Tensor {
+	return Tensor(cpu.Permute(cpu.Tensor(x), axes))
+}
+
 func Reshape2D1D(x Tensor) []float64 {
 	y := cpu.Reshape2D1D(x.CPU)
 	return y
@@ -171,6 +307,11 @@ func Shape2D(x Tensor) (n int, c int) {
 	return n, c
 }
 
+func Shape3D(input Tensor) (n int, c int, l int) {
+	n, c, l = cpu.Shape3D(input.CPU3D)
+	return n, c, l
+}
+
 func Shape4D(input Tensor) (n int, c int, h int, w int) {
 	n, c, h, w = cpu.Shape4D(input.CPU4D)
 	return n, c, h, w
@@ -218,102 +359,170 @@ func Pad4D(input [][][][]float64, pad [][]int) [][][][]float64 {
 	return z
 }
 
+func wrap2D(x [][]float64) Tensor {
+	z := Tensor{}
+	z.CPU = x
+	n, m := len(x), 0
+	if n > 0 {
+		m = len(x[0])
+	}
+	z.Shape = []int{n, m}
+	return z
+}
+
 func MakeInit(n int, m int, value float64) Tensor {
 	z := Tensor{}
 	z.CPU = cpu.MakeInit(n, m, value)
+	z.Shape = []int{n, m}
 	return z
 }
 
+// elementwise applies a 2D kernel to tensors of any rank by viewing
+// higher ranks as a single row.
+func elementwise(fn func(x, y [][]float64) [][]float64, x, y Tensor) Tensor {
+	if len(x.Shape) <= 2 {
+		return wrap2D(fn(x.CPU, y.CPU))
+	}
+	z := fn([][]float64{ToSlice(x)}, [][]float64{ToSlice(y)})
+	return FromSliceNoCopy(z[0], x.Shape...)
+}
+
+func elementwiseE(fn func(x [][]float64, y float64) [][]float64, x Tensor, y float64) Tensor {
+	if len(x.Shape) <= 2 {
+		return wrap2D(fn(x.CPU, y))
+	}
+	z := fn([][]float64{ToSlice(x)}, y)
+	return FromSliceNoCopy(z[0], x.Shape...)
+}
+
 func Add(x, y Tensor) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Add(x.CPU, y.CPU)
-	z.Shape = x.Shape
-	return z
+	return elementwise(cpu.Add, x, y)
 }
 
 func AddE(x Tensor, y float64) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.AddE(x.CPU, y)
-	return z
+	return elementwiseE(cpu.AddE, x, y)
 }
 
 func Sub(x, y Tensor) Tensor {
-	z := x
-	z.CPU = cpu.Sub(x.CPU, y.CPU)
-	return z
+	return elementwise(cpu.Sub, x, y)
 }
 
 func SubE(x Tensor, y float64) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.SubE(x.CPU, y)
-	return z
+	return elementwiseE(cpu.SubE, x, y)
 }
 
 func MulE(x Tensor, y float64) Tensor {
-	z := Tensor{}
-	mule := cpu.MulE(x.CPU, y)
-	z.CPU = mule
-	return z
+	return elementwiseE(cpu.MulE, x, y)
 }
 
 func Mul(x, y Tensor) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Mul(x.CPU, y.CPU)
-	return z
+	return elementwise(cpu.Mul, x, y)
 }
 
 func Div(x, y Tensor) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Div(x.CPU, y.CPU)
-	return z
+	return elementwise(cpu.Div, x, y)
 }
 
 func T(x Tensor) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.T(x.CPU)
-	return z
+	return wrap2D(cpu.T(x.CPU))
 }
 
 func Apply(x Tensor, fn func(float64) float64) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Apply(x.CPU, fn)
-	return z
+	if len(x.Shape) <= 2 {
+		return wrap2D(cpu.Apply(x.CPU, fn))
+	}
+	z := cpu.Apply([][]float64{ToSlice(x)}, fn)
+	return FromSliceNoCopy(z[0], x.Shape...)
+}
+
+func AxpyE(x Tensor, b, c float64) Tensor {
+	// d[i] = a[i] *b + c
+	return Apply(x, func(v float64) float64 {
+		return v*b + c
+	})
+}
+
+func Exp(x Tensor, b, c float64) Tensor {
+	// d[i] = exp(a[i] * b) + c
+	return Apply(x, func(v float64) float64 {
+		return math.Exp(v*b) + c
+	})
+}
+
+func ExpT(x Tensor, b, c float64) Tensor {
+	// d[i] = 1 / (exp(a[i] * b) + c)
+	return Apply(x, func(v float64) float64 {
+		return 1 / (math.Exp(v*b) + c)
+	})
+}
+
+func Log(x Tensor, b float64) Tensor {
+	// c[i] = log(a[i] + b)
+	return Apply(x, func(v float64) float64 {
+		return math.Log(v + b)
+	})
+}
+
+func SqrtT(x Tensor, b, c float64) Tensor {
+	// c[i] = 1 / (sqrt(a[i] + b) + c)
+	return Apply(x, func(v float64) float64 {
+		return 1 / (math.Sqrt(v+b) + c)
+	})
+}
+
+func Clip(x Tensor, min, max float64) Tensor {
+	return Apply(x, func(v float64) float64 {
+		return math.Min(math.Max(v, min), max)
+	})
 }
 
 func Dot(x, y Tensor) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Dot(x.CPU, y.CPU)
-	return z
+	return wrap2D(cpu.Dot(x.CPU, y.CPU))
+}
+
+func BatchDot(x, y Tensor, transX, transY bool) Tensor {
+	return wrap3D(cpu.BatchDot(x.CPU3D, y.CPU3D, transX, transY))
 }
 
 func SumRow(x Tensor) Tensor {
 	//sum | direction [a,b]
 	//    ^           [a,b]
-	z := Tensor{}
-	z.CPU = cpu.SumRow(x.CPU)
-	return z
+	return wrap2D(cpu.SumRow(x.CPU))
 }
 
 func SumCol(x Tensor) Tensor {
 	//sum -> direction [a,a]
 	//				   [b,b]
-	z := Tensor{}
-	z.CPU = cpu.SumCol(x.CPU)
-	return z
+	return wrap2D(cpu.SumCol(x.CPU))
 }
 
 func Cast(x Tensor, castSize int) Tensor {
-	z := Tensor{}
-	z.CPU = cpu.Cast(x.CPU, castSize)
-	return z
+	return wrap2D(cpu.Cast(x.CPU, castSize))
+}
+
+func Sum(x Tensor) float64 {
+	sum := 0.0
+	for _, v := range ToSlice(x) {
+		sum += v
+	}
+	return sum
+}
+
+func Max(x Tensor) float64 {
+	data := ToSlice(x)
+	max := data[0]
+	for _, v := range data {
+		if v > max {
+			max = v
+		}
+	}
+	return max
 }
 
 func MaxCol(x Tensor) Tensor {
 	//sum -> direction [a,a]
 	//				   [b,b]
-	z := Tensor{}
-	z.CPU = cpu.MaxCol(x.CPU)
-	return z
+	return wrap2D(cpu.MaxCol(x.CPU))
 }
 
 func ArgMaxCol(x Tensor) [][]int {
@@ -325,19 +534,473 @@ func ArgMaxCol(x Tensor) [][]int {
 }
 
 func RandomNorm2D(r int, c int, init float64) Tensor {
+	return wrap2D(cpu.RandomNorm2D(r, c, init))
+}
+
+func HeNorm2D(r int, c int) Tensor {
+	return wrap2D(cpu.HeNorm2D(r, c))
+}
+
+func Uniform(low, high float64, shape ...int) Tensor {
+	return Tensor(cpu.Uniform(low, high, shape))
+}
+
+func Normal(mean, std float64, shape ...int) Tensor {
+	return Tensor(cpu.Normal(mean, std, shape))
+}
+
+func TruncatedNormal(mean, std float64, shape ...int) Tensor {
+	return Tensor(cpu.TruncatedNormal(mean, std, shape))
+}
+
+func Bernoulli(p float64, shape ...int) Tensor {
+	return Tensor(cpu.Bernoulli(p, shape))
+}
+
+func Poisson(lambda float64, shape ...int) Tensor {
+	return Tensor(cpu.Poisson(lambda, shape))
+}
+
+func Gamma(k, theta float64, shape ...int) Tensor {
+	return Tensor(cpu.Gamma(k, theta, shape))
+}
+
+func Beta(a, b float64, shape ...int) Tensor {
+	return Tensor(cpu.Beta(a, b, shape))
+}
+
+func Multinomial(probs Tensor, samples int) Tensor {
 	z := Tensor{}
-	z.CPU = cpu.RandomNorm2D(r, c, init)
+	z.CPU = cpu.Multinomial(probs.CPU, samples)
+	z.Shape = []int{probs.Shape[0], samples}
 	return z
 }
 
-func HeNorm2D(r int, c int) Tensor {
+func Categorical(probs Tensor) Tensor {
+	return Multinomial(probs, 1)
+}
+
+func Permutation(n int) Tensor {
 	z := Tensor{}
-	z.CPU = cpu.HeNorm2D(r, c)
+	z.CPU = cpu.Permutation(n)
+	z.Shape = []int{1, n}
 	return z
 }
 
-func Conv1D(x, filter Tensor, stride int) Tensor {
+func Shuffle(x Tensor, axis int) Tensor {
+	return Tensor(cpu.Shuffle(cpu.Tensor(x), axis))
+}
+
+type ConvParam = cpu.ConvParam
+
+const (
+	PadZeros     = cpu.PadZeros
+	PadReflect   = cpu.PadReflect
+	PadReplicate = cpu.PadReplicate
+)
+
+func bias1D(b Tensor) []float64 {
+	if len(b.Shape) == 0 {
+		return nil
+	}
+	return cpu.Flatten(cpu.Tensor(b))
+}
+
+func Conv1D(x, filter, bias Tensor, param ConvParam) Tensor {
 	z := Tensor{}
-	z.CPU = cpu.Conv1D(x.CPU, filter.CPU, stride)
+	z.CPU3D = cpu.Conv1D(x.CPU3D, filter.CPU3D, bias1D(bias), param)
+	n, c, l := cpu.Shape3D(z.CPU3D)
+	z.Shape = []int{n, c, l}
 	return z
 }
+
+func Conv1DBackwardInput(grad, filter Tensor, length int, param ConvParam) Tensor {
+	z := Tensor{}
+	z.CPU3D = cpu.Conv1DBackwardInput(grad.CPU3D, filter.CPU3D, length, param)
+	n, c, l := cpu.Shape3D(z.CPU3D)
+	z.Shape = []int{n, c, l}
+	return z
+}
+
+func Conv1DBackwardKernel(x, grad Tensor, k int, param ConvParam) Tensor {
+	z := Tensor{}
+	z.CPU3D = cpu.Conv1DBackwardKernel(x.CPU3D, grad.CPU3D, k, param)
+	n, c, l := cpu.Shape3D(z.CPU3D)
+	z.Shape = []int{n, c, l}
+	return z
+}
+
+func Conv1DBackwardBias(grad Tensor) Tensor {
+	z := cpu.Conv1DBackwardBias(grad.CPU3D)
+	return FromSliceNoCopy(z, 1, len(z))
+}
+
+func Conv2D(x, filter, bias Tensor, param ConvParam) Tensor {
+	return wrap4D(cpu.Conv2D(x.CPU4D, filter.CPU4D, bias1D(bias), param))
+}
+
+func Conv2DBackwardInput(grad, filter Tensor, h, w int, param ConvParam) Tensor {
+	return wrap4D(cpu.Conv2DBackwardInput(grad.CPU4D, filter.CPU4D, h, w, param))
+}
+
+func Conv2DBackwardKernel(x, grad Tensor, kh, kw int, param ConvParam) Tensor {
+	return wrap4D(cpu.Conv2DBackwardKernel(x.CPU4D, grad.CPU4D, kh, kw, param))
+}
+
+func Conv2DBackwardBias(grad Tensor) Tensor {
+	z := cpu.Conv2DBackwardBias(grad.CPU4D)
+	return FromSliceNoCopy(z, 1, len(z))
+}
+
+func wrap4D(x [][][][]float64) Tensor {
+	z := Tensor{}
+	z.CPU4D = x
+	n, c, h, w := cpu.Shape4D(x)
+	z.Shape = []int{n, c, h, w}
+	return z
+}
+
+func ConvTranspose2D(x, filter, bias Tensor, param ConvParam, outputPadding int) Tensor {
+	return wrap4D(cpu.ConvTranspose2D(x.CPU4D, filter.CPU4D, bias1D(bias), param, outputPadding))
+}
+
+func ConvTranspose2DBackwardInput(grad, filter Tensor, param ConvParam) Tensor {
+	return wrap4D(cpu.ConvTranspose2DBackwardInput(grad.CPU4D, filter.CPU4D, param))
+}
+
+func ConvTranspose2DBackwardKernel(x, grad Tensor, kh, kw int, param ConvParam) Tensor {
+	return wrap4D(cpu.ConvTranspose2DBackwardKernel(x.CPU4D, grad.CPU4D, kh, kw, param))
+}
+
+func ConvTranspose2DBackwardBias(grad Tensor) Tensor {
+	return Conv2DBackwardBias(grad)
+}
+
+func UpsampleNearest(x Tensor, scaleH, scaleW int) Tensor {
+	return wrap4D(cpu.UpsampleNearest(x.CPU4D, scaleH, scaleW))
+}
+
+func UpsampleNearestBackward(grad Tensor, scaleH, scaleW int) Tensor {
+	return wrap4D(cpu.UpsampleNearestBackward(grad.CPU4D, scaleH, scaleW))
+}
+
+func UpsampleBilinear(x Tensor, h, w int, alignCorners bool) Tensor {
+	return wrap4D(cpu.UpsampleBilinear(x.CPU4D, h, w, alignCorners))
+}
+
+func UpsampleBilinearBackward(grad Tensor, h, w int, alignCorners bool) Tensor {
+	return wrap4D(cpu.UpsampleBilinearBackward(grad.CPU4D, h, w, alignCorners))
+}
+
+func PixelShuffle(x Tensor, r int) Tensor {
+	return wrap4D(cpu.PixelShuffle(x.CPU4D, r))
+}
+
+func PixelShuffleBackward(grad Tensor, r int) Tensor {
+	return wrap4D(cpu.PixelShuffleBackward(grad.CPU4D, r))
+}
+
+func PixelUnshuffle(x Tensor, r int) Tensor {
+	return wrap4D(cpu.PixelUnshuffle(x.CPU4D, r))
+}
+
+func PixelUnshuffleBackward(grad Tensor, r int) Tensor {
+	return wrap4D(cpu.PixelUnshuffleBackward(grad.CPU4D, r))
+}
+
+// Pooling indices are stored as float positions so that they travel as
+// ordinary tensors.
+func index3D(x [][][]int) Tensor {
+	z := Tensor{}
+	z.CPU3D = make([][][]float64, len(x))
+	for i := range x {
+		z.CPU3D[i] = make([][]float64, len(x[i]))
+		for j := range x[i] {
+			z.CPU3D[i][j] = make([]float64, len(x[i][j]))
+			for k, v := range x[i][j] {
+				z.CPU3D[i][j][k] = float64(v)
+			}
+		}
+	}
+	n, c, l := cpu.Shape3D(z.CPU3D)
+	z.Shape = []int{n, c, l}
+	return z
+}
+
+func indexInt3D(x [][][]float64) [][][]int {
+	z := make([][][]int, len(x))
+	for i := range x {
+		z[i] = make([][]int, len(x[i]))
+		for j := range x[i] {
+			z[i][j] = make([]int, len(x[i][j]))
+			for k, v := range x[i][j] {
+				z[i][j][k] = int(v)
+			}
+		}
+	}
+	return z
+}
+
+func index4D(x [][][][]int) Tensor {
+	z := Tensor{}
+	z.CPU4D = make([][][][]float64, len(x))
+	for i := range x {
+		z.CPU4D[i] = index3D(x[i]).CPU3D
+	}
+	n, c, h, w := cpu.Shape4D(z.CPU4D)
+	z.Shape = []int{n, c, h, w}
+	return z
+}
+
+func indexInt4D(x [][][][]float64) [][][][]int {
+	z := make([][][][]int, len(x))
+	for i := range x {
+		z[i] = indexInt3D(x[i])
+	}
+	return z
+}
+
+func wrap3D(x [][][]float64) Tensor {
+	z := Tensor{}
+	z.CPU3D = x
+	n, c, l := cpu.Shape3D(x)
+	z.Shape = []int{n, c, l}
+	return z
+}
+
+func MaxPool1D(x Tensor, k int, param ConvParam) (Tensor, Tensor) {
+	z, index := cpu.MaxPool1D(x.CPU3D, k, param)
+	return wrap3D(z), index3D(index)
+}
+
+func MaxPool1DBackward(grad, index Tensor, length int) Tensor {
+	return wrap3D(cpu.MaxPool1DBackward(grad.CPU3D, indexInt3D(index.CPU3D), length))
+}
+
+func AvgPool1D(x Tensor, k int, param ConvParam) Tensor {
+	return wrap3D(cpu.AvgPool1D(x.CPU3D, k, param))
+}
+
+func AvgPool1DBackward(grad Tensor, k, length int, param ConvParam) Tensor {
+	return wrap3D(cpu.AvgPool1DBackward(grad.CPU3D, k, length, param))
+}
+
+func MaxPool2D(x Tensor, kh, kw int, param ConvParam) (Tensor, Tensor) {
+	z, index := cpu.MaxPool2D(x.CPU4D, kh, kw, param)
+	return wrap4D(z), index4D(index)
+}
+
+func MaxPool2DBackward(grad, index Tensor, h, w int) Tensor {
+	return wrap4D(cpu.MaxPool2DBackward(grad.CPU4D, indexInt4D(index.CPU4D), h, w))
+}
+
+func AvgPool2D(x Tensor, kh, kw int, param ConvParam) Tensor {
+	return wrap4D(cpu.AvgPool2D(x.CPU4D, kh, kw, param))
+}
+
+func AvgPool2DBackward(grad Tensor, kh, kw, h, w int, param ConvParam) Tensor {
+	return wrap4D(cpu.AvgPool2DBackward(grad.CPU4D, kh, kw, h, w, param))
+}
+
+func AdaptiveAvgPool1D(x Tensor, out int) Tensor {
+	return wrap3D(cpu.AdaptiveAvgPool1D(x.CPU3D, out))
+}
+
+func AdaptiveAvgPool1DBackward(grad Tensor, length int) Tensor {
+	return wrap3D(cpu.AdaptiveAvgPool1DBackward(grad.CPU3D, length))
+}
+
+func AdaptiveAvgPool2D(x Tensor, h, w int) Tensor {
+	return wrap4D(cpu.AdaptiveAvgPool2D(x.CPU4D, h, w))
+}
+
+func AdaptiveAvgPool2DBackward(grad Tensor, h, w int) Tensor {
+	return wrap4D(cpu.AdaptiveAvgPool2DBackward(grad.CPU4D, h, w))
+}
+
+func GlobalAvgPool1D(x Tensor) Tensor {
+	return wrap3D(cpu.GlobalAvgPool1D(x.CPU3D))
+}
+
+func GlobalAvgPool2D(x Tensor) Tensor {
+	return wrap4D(cpu.GlobalAvgPool2D(x.CPU4D))
+}
+
+func GlobalMaxPool1D(x Tensor) (Tensor, Tensor) {
+	z, index := cpu.GlobalMaxPool1D(x.CPU3D)
+	return wrap3D(z), index3D(index)
+}
+
+func GlobalMaxPool2D(x Tensor) (Tensor, Tensor) {
+	z, index := cpu.GlobalMaxPool2D(x.CPU4D)
+	return wrap4D(z), index4D(index)
+}
+
+// rowTensor wraps per channel values as [1, C].
+func rowTensor(x []float64) Tensor {
+	return FromSliceNoCopy(x, 1, len(x))
+}
+
+func BatchNorm(x, gamma, beta Tensor, eps float64) (Tensor, Tensor, Tensor) {
+	z, mean, variance := cpu.BatchNorm(cpu.Tensor(x), bias1D(gamma), bias1D(beta), eps)
+	return Tensor(z), rowTensor(mean), rowTensor(variance)
+}
+
+func BatchNormInference(x, mean, variance, gamma, beta Tensor, eps float64) Tensor {
+	return Tensor(cpu.BatchNormInference(cpu.Tensor(x), bias1D(mean), bias1D(variance), bias1D(gamma), bias1D(beta), eps))
+}
+
+func BatchNormBackward(x, grad, gamma Tensor, eps float64) (Tensor, Tensor, Tensor) {
+	gx, dgamma, dbeta := cpu.BatchNormBackward(cpu.Tensor(x), cpu.Tensor(grad), bias1D(gamma), eps)
+	return Tensor(gx), rowTensor(dgamma), rowTensor(dbeta)
+}
+
+func BatchNormInferenceBackward(x, grad, mean, variance, gamma Tensor, eps float64) (Tensor, Tensor, Tensor) {
+	gx, dgamma, dbeta := cpu.BatchNormInferenceBackward(cpu.Tensor(x), cpu.Tensor(grad), bias1D(mean), bias1D(variance), bias1D(gamma), eps)
+	return Tensor(gx), rowTensor(dgamma), rowTensor(dbeta)
+}
+
+func LayerNorm(x, gamma, beta Tensor, eps float64) Tensor {
+	return Tensor(cpu.LayerNorm(cpu.Tensor(x), bias1D(gamma), bias1D(beta), eps))
+}
+
+func LayerNormBackward(x, grad, gamma Tensor, eps float64) (Tensor, Tensor, Tensor) {
+	gx, dgamma, dbeta := cpu.LayerNormBackward(cpu.Tensor(x), cpu.Tensor(grad), bias1D(gamma), eps)
+	return Tensor(gx), rowTensor(dgamma), rowTensor(dbeta)
+}
+
+func GroupNorm(x Tensor, groups int, gamma, beta Tensor, eps float64) Tensor {
+	return Tensor(cpu.GroupNorm(cpu.Tensor(x), groups, bias1D(gamma), bias1D(beta), eps))
+}
+
+func GroupNormBackward(x, grad Tensor, groups int, gamma Tensor, eps float64) (Tensor, Tensor, Tensor) {
+	gx, dgamma, dbeta := cpu.GroupNormBackward(cpu.Tensor(x), cpu.Tensor(grad), groups, bias1D(gamma), eps)
+	return Tensor(gx), rowTensor(dgamma), rowTensor(dbeta)
+}
+
+func InstanceNorm(x, gamma, beta Tensor, eps float64) Tensor {
+	return Tensor(cpu.InstanceNorm(cpu.Tensor(x), bias1D(gamma), bias1D(beta), eps))
+}
+
+func InstanceNormBackward(x, grad, gamma Tensor, eps float64) (Tensor, Tensor, Tensor) {
+	gx, dgamma, dbeta := cpu.InstanceNormBackward(cpu.Tensor(x), cpu.Tensor(grad), bias1D(gamma), eps)
+	return Tensor(gx), rowTensor(dgamma), rowTensor(dbeta)
+}
+
+func Softmax(x Tensor, axis int) Tensor {
+	return Tensor(cpu.Softmax(cpu.Tensor(x), axis))
+}
+
+func LogSoftmax(x Tensor, axis int) Tensor {
+	return Tensor(cpu.LogSoftmax(cpu.Tensor(x), axis))
+}
+
+func LogSumExp(x Tensor, axis int) Tensor {
+	return Tensor(cpu.LogSumExp(cpu.Tensor(x), axis))
+}
+
+func SoftmaxBackward(y, grad Tensor, axis int) Tensor {
+	return Tensor(cpu.SoftmaxBackward(cpu.Tensor(y), cpu.Tensor(grad), axis))
+}
+
+func LogSoftmaxBackward(y, grad Tensor, axis int) Tensor {
+	return Tensor(cpu.LogSoftmaxBackward(cpu.Tensor(y), cpu.Tensor(grad), axis))
+}
+
+func SoftmaxCrossEntropy(logits, target Tensor) (float64, Tensor) {
+	loss, grad := cpu.SoftmaxCrossEntropy(logits.CPU, target.CPU)
+	return loss, wrap2D(grad)
+}
+
+func SparseSoftmaxCrossEntropy(logits Tensor, labels []int) (float64, Tensor) {
+	loss, grad := cpu.SparseSoftmaxCrossEntropy(logits.CPU, labels)
+	return loss, wrap2D(grad)
+}
+
+func ReLU(x Tensor) Tensor {
+	return Tensor(cpu.ReLU(cpu.Tensor(x)))
+}
+
+func ReLUDerivative(x Tensor) Tensor {
+	return Tensor(cpu.ReLUDerivative(cpu.Tensor(x)))
+}
+
+func LeakyReLU(x Tensor, alpha float64) Tensor {
+	return Tensor(cpu.LeakyReLU(cpu.Tensor(x), alpha))
+}
+
+func LeakyReLUDerivative(x Tensor, alpha float64) Tensor {
+	return Tensor(cpu.LeakyReLUDerivative(cpu.Tensor(x), alpha))
+}
+
+func ELU(x Tensor, alpha float64) Tensor {
+	return Tensor(cpu.ELU(cpu.Tensor(x), alpha))
+}
+
+func ELUDerivative(x Tensor, alpha float64) Tensor {
+	return Tensor(cpu.ELUDerivative(cpu.Tensor(x), alpha))
+}
+
+func GELU(x Tensor) Tensor {
+	return Tensor(cpu.GELU(cpu.Tensor(x)))
+}
+
+func GELUDerivative(x Tensor) Tensor {
+	return Tensor(cpu.GELUDerivative(cpu.Tensor(x)))
+}
+
+func SiLU(x Tensor) Tensor {
+	return Tensor(cpu.SiLU(cpu.Tensor(x)))
+}
+
+func SiLUDerivative(x Tensor) Tensor {
+	return Tensor(cpu.SiLUDerivative(cpu.Tensor(x)))
+}
+
+func Swish(x Tensor) Tensor {
+	return Tensor(cpu.Swish(cpu.Tensor(x)))
+}
+
+func SwishDerivative(x Tensor) Tensor {
+	return Tensor(cpu.SwishDerivative(cpu.Tensor(x)))
+}
+
+func Sigmoid(x Tensor) Tensor {
+	return Tensor(cpu.Sigmoid(cpu.Tensor(x)))
+}
+
+func SigmoidDerivative(x Tensor) Tensor {
+	return Tensor(cpu.SigmoidDerivative(cpu.Tensor(x)))
+}
+
+func Tanh(x Tensor) Tensor {
+	return Tensor(cpu.Tanh(cpu.Tensor(x)))
+}
+
+func TanhDerivative(x Tensor) Tensor {
+	return Tensor(cpu.TanhDerivative(cpu.Tensor(x)))
+}
+
+func Softplus(x Tensor) Tensor {
+	return Tensor(cpu.Softplus(cpu.Tensor(x)))
+}
+
+func SoftplusDerivative(x Tensor) Tensor {
+	return Tensor(cpu.SoftplusDerivative(cpu.Tensor(x)))
+}
+
+func HardSigmoid(x Tensor) Tensor {
+	return Tensor(cpu.HardSigmoid(cpu.Tensor(x)))
+}
+
+func HardSigmoidDerivative(x Tensor) Tensor {
+	return Tensor(cpu.HardSigmoidDerivative(cpu.Tensor(x)))
+}
+
+func Mish(x Tensor) Tensor {
+	return Tensor(cpu.Mish(cpu.Tensor(x)))
+}
+
+func MishDerivative(x Tensor) Tensor {
+	return Tensor(cpu.MishDerivative(cpu.Tensor(x)))
+}
@@ -1,4 +1,6 @@
+//go:build gpu
 // +build gpu
+
 // Copyright 2018 kurosawa. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
@@ -18,6 +20,7 @@ package gmat
 
 import (
 	"C"
+	"github.com/kuroko1t/gmat/cpu"
 	"github.com/kuroko1t/gmat/gpu"
 	"log"
 )
@@ -46,6 +49,71 @@ func MakeInit(n, m int, value float64) (z Tensor) {
 	return z
 }
 
+func FromSlice(data []float64, shape ...int) Tensor {
+	return fromHost(cpu.Unflatten(append([]float64{}, data...), shape))
+}
+
+// FromSliceNoCopy has to copy data to the device, so it behaves like
+// FromSlice on this build.
+func FromSliceNoCopy(data []float64, shape ...int) Tensor {
+	return fromHost(cpu.Unflatten(data, shape))
+}
+
+func ToSlice(x Tensor) []float64 {
+	return cpu.Flatten(toHost(x))
+}
+
+func Zeros(shape ...int) Tensor {
+	return Full(0, shape...)
+}
+
+func Ones(shape ...int) Tensor {
+	return Full(1, shape...)
+}
+
+func Full(value float64, shape ...int) (z Tensor) {
+	z.GPU = handle.Fill(shape, float32(value))
+	z.Shape = shape
+	return z
+}
+
+func ZerosLike(x Tensor) Tensor {
+	return Zeros(x.Shape...)
+}
+
+func OnesLike(x Tensor) Tensor {
+	return Ones(x.Shape...)
+}
+
+func Eye(n int) Tensor {
+	return CopyH2D(cpu.Eye(n, n))
+}
+
+func Diag(x Tensor) Tensor {
+	return CopyH2D(cpu.Diag(toHost(x).CPU))
+}
+
+func Arange(start, stop, step float64) Tensor {
+	z := cpu.Arange(start, stop, step)
+	return FromSliceNoCopy(z, 1, len(z))
+}
+
+func Linspace(start, stop float64, num int) Tensor {
+	return FromSliceNoCopy(cpu.Linspace(start, stop, num), 1, num)
+}
+
+func Tril(x Tensor, k int) (z Tensor) {
+	z.GPU = handle.Tri(x.GPU, x.Shape, k, false)
+	z.Shape = x.Shape
+	return z
+}
+
+func Triu(x Tensor, k int) (z Tensor) {
+	z.GPU = handle.Tri(x.GPU, x.Shape, k, true)
+	z.Shape = x.Shape
+	return z
+}
+
 func Shape2D(x Tensor) (int, int) {
 	return x.Shape[0], x.Shape[1]
 }
@@ -83,6 +151,25 @@ func TDot(x, y Tensor) (z Tensor) {
 	return z
 }
 
+// BatchDot multiplies op(x[b]) by op(y[b]) for [B, M, K] and [B, K, N]
+// tensors; transX and transY transpose the matrices first.
+func BatchDot(x, y Tensor, transX, transY bool) (z Tensor) {
+	batch, m, kx := x.Shape[0], x.Shape[1], x.Shape[2]
+	ky, n := y.Shape[1], y.Shape[2]
+	if transX {
+		m, kx = kx, m
+	}
+	if transY {
+		ky, n = n, ky
+	}
+	if y.Shape[0] != batch || kx != ky {
+		log.Fatal("BatchDot mismatch input shape")
+	}
+	z.GPU = handle.BatchDot(x.GPU, y.GPU, batch, m, n, kx, transX, transY)
+	z.Shape = []int{batch, m, n}
+	return z
+}
+
 func DotT(x, y Tensor) (z Tensor) {
 	m, kx := x.Shape[0], x.Shape[1]
 	n, ky := y.Shape[0], y.Shape[1]
@@ -94,14 +181,24 @@ func DotT(x, y Tensor) (z Tensor) {
 	return z
 }
 
+func sameShape(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func Add(x, y Tensor) (z Tensor) {
-	mx, nx := x.Shape[0], x.Shape[1]
-	my, ny := y.Shape[0], y.Shape[1]
-	if (mx != my) || (nx != ny) {
+	if !sameShape(x.Shape, y.Shape) {
 		log.Fatal("Add mismatch input shape")
 	}
-	z.GPU = handle.Add(x.GPU, y.GPU, mx*nx)
-	z.Shape = []int{mx, nx}
+	z.GPU = handle.Add(x.GPU, y.GPU, cpu.Size(x.Shape))
+	z.Shape = x.Shape
 	return z
 }
 
@@ -184,11 +281,161 @@ func Log(x Tensor, b float64) (z Tensor) {
 }
 
 func RandomNorm(size []int) (z Tensor) {
-	z.GPU = handle.RandomNorm(size)
-	z.Shape = size
+	return Normal(0, 1, size...)
+}
+
+func fromHost(x cpu.Tensor) (z Tensor) {
+	// 2D tensors keep the column major layout of CopyH2D
+	if len(x.Shape) == 2 {
+		_, _, z.GPU = handle.CopyH2D(x.CPU)
+	} else {
+		x64 := cpu.Flatten(x)
+		x32 := make([]float32, len(x64))
+		for i := range x64 {
+			x32[i] = float32(x64[i])
+		}
+		z.GPU = handle.CopyH2D1D(x32)
+	}
+	z.Shape = x.Shape
+	return z
+}
+
+func toHost(x Tensor) cpu.Tensor {
+	if len(x.Shape) == 2 {
+		return cpu.Tensor{CPU: handle.Download(x.Shape, x.GPU), Shape: x.Shape}
+	}
+	x32 := handle.CopyD2H1D(x.Shape, x.GPU)
+	x64 := make([]float64, len(x32))
+	for i := range x32 {
+		x64[i] = float64(x32[i])
+	}
+	return cpu.Unflatten(x64, x.Shape)
+}
+
+func Uniform(low, high float64, shape ...int) (z Tensor) {
+	if high < low {
+		log.Fatal("Uniform.high must not be below low")
+	}
+	u := handle.RandomUniform(shape)
+	z.GPU = handle.AxpyE(u, shape, float32(high-low), float32(low))
+	handle.Free(u)
+	z.Shape = shape
+	return z
+}
+
+func Normal(mean, std float64, shape ...int) (z Tensor) {
+	if std < 0 {
+		log.Fatal("Normal.std must not be negative")
+	}
+	z.GPU = handle.RandomNorm(shape, float32(mean), float32(std))
+	z.Shape = shape
 	return z
 }
 
+func TruncatedNormal(mean, std float64, shape ...int) Tensor {
+	return fromHost(cpu.TruncatedNormal(mean, std, shape))
+}
+
+func Bernoulli(p float64, shape ...int) Tensor {
+	return fromHost(cpu.Bernoulli(p, shape))
+}
+
+func Poisson(lambda float64, shape ...int) Tensor {
+	return fromHost(cpu.Poisson(lambda, shape))
+}
+
+func Gamma(k, theta float64, shape ...int) Tensor {
+	return fromHost(cpu.Gamma(k, theta, shape))
+}
+
+func Beta(a, b float64, shape ...int) Tensor {
+	return fromHost(cpu.Beta(a, b, shape))
+}
+
+func Multinomial(probs Tensor, samples int) Tensor {
+	z := cpu.Multinomial(toHost(probs).CPU, samples)
+	return fromHost(cpu.Tensor{CPU: z, Shape: []int{probs.Shape[0], samples}})
+}
+
+func Categorical(probs Tensor) Tensor {
+	return Multinomial(probs, 1)
+}
+
+func Permutation(n int) Tensor {
+	return fromHost(cpu.Tensor{CPU: cpu.Permutation(n), Shape: []int{1, n}})
+}
+
+func Shuffle(x Tensor, axis int) Tensor {
+	return fromHost(cpu.Shuffle(toHost(x), axis))
+}
+
+func Reshape(x Tensor, shape []int) Tensor {
+	return fromHost(cpu.Reshape(toHost(x), shape))
+}
+
+// Concat and Split run on the host.
+func Concat(xs []Tensor, axis int) Tensor {
+	ts := make([]cpu.Tensor, len(xs))
+	for i, x := range xs {
+		ts[i] = toHost(x)
+	}
+	return fromHost(cpu.Concat(ts, axis))
+}
+
+func Split(x Tensor, sizes []int, axis int) []Tensor {
+	ts := cpu.Split(toHost(x), sizes, axis)
+	zs := make([]Tensor, len(ts))
+	for i, t := range ts {
+		zs[i] = fromHost(t)
+	}
+	return zs
+}
+
+// Stack joins tensors of equal shape along a new first axis.
+func Stack(xs []Tensor) Tensor {
+	ys := make([]Tensor, len(xs))
+	for i, x := range xs {
+		ys[i] = Reshape(x, append([]int{1}, x.Shape...))
+	}
+	return Concat(ys, 0)
+}
+
+// Unstack is the inverse of Stack.
+func Unstack(x Tensor) []Tensor {
+	sizes := make([]int, x.Shape[0])
+	for i := range sizes {
+		sizes[i] = 1
+	}
+	zs := Split(x, sizes, 0)
+	for i := range zs {
+		zs[i] = Reshape(zs[i], x.Shape[1:])
+	}
+	return zs
+}
+
+// Gather and ScatterAdd run on the host.
+func Gather(x Tensor, index []int) Tensor {
+	z := cpu.Gather(toHost(x).CPU, index)
+	return fromHost(cpu.Tensor{CPU: z, Shape: []int{len(index), x.Shape[1]}})
+}
+
+func ScatterAdd(x Tensor, index []int, src Tensor) Tensor {
+	z := cpu.ScatterAdd(toHost(x).CPU, index, toHost(src).CPU)
+	return fromHost(cpu.Tensor{CPU: z, Shape: x.Shape})
+}
+
+// IndexAdd adds row i of src to row index[i] of x in place.
+func IndexAdd(x Tensor, index []int, src Tensor) {
+	if len(index) != src.Shape[0] || x.Shape[1] != src.Shape[1] {
+		log.Fatal("IndexAdd.mismatch index and src")
+	}
+	handle.IndexAdd(x.GPU, x.Shape, index, src.GPU, src.Shape)
+}
+
+func Permute(x Tensor, axes []int) Tensor {
+	return fromHost(cpu.Permute(toHost(x), axes))
+}
+
 func T(x Tensor) (z Tensor) {
 	// Transpose Tensor
 	z.GPU = handle.T(x.GPU, x.Shape)
@@ -210,12 +457,26 @@ func SqrtT(x Tensor, b, c float64) (z Tensor) {
 	return z
 }
 
-func ArgMaxCol(x Tensor) (z Tensor) {
-	z.GPU = handle.ArgMaxCol(x.GPU, x.Shape)
+func Clip(x Tensor, min, max float64) (z Tensor) {
+	z.GPU = handle.Clip(x.GPU, x.Shape, float32(min), float32(max))
 	z.Shape = x.Shape
 	return z
 }
 
+// ArgMaxCol fills every row with the column index of its maximum, found on
+// the host since cublasIsamax ranks by absolute value.
+func ArgMaxCol(x Tensor) Tensor {
+	index := cpu.ArgMaxCol(toHost(x).CPU)
+	z := make([][]float64, len(index))
+	for i := range index {
+		z[i] = make([]float64, len(index[i]))
+		for j := range index[i] {
+			z[i][j] = float64(index[i][j])
+		}
+	}
+	return fromHost(cpu.Tensor{CPU: z, Shape: x.Shape})
+}
+
 func Sum(x Tensor) float64 {
 	sum := handle.Sum(x.GPU, x.Shape)
 	return sum
@@ -225,3 +486,199 @@ func Max(x Tensor) float64 {
 	maxvalue := handle.Max(x.GPU, x.Shape)
 	return maxvalue
 }
+
+// matrixAxis returns axis normalised to 0 or 1 for matrices and -1 for the
+// other ranks, which are computed on the host.
+func matrixAxis(x Tensor, axis int) int {
+	if len(x.Shape) != 2 {
+		return -1
+	}
+	if axis < 0 {
+		axis += 2
+	}
+	if axis != 0 && axis != 1 {
+		log.Fatal("axis out of range")
+	}
+	return axis
+}
+
+func Softmax(x Tensor, axis int) (z Tensor) {
+	a := matrixAxis(x, axis)
+	if a < 0 {
+		return fromHost(cpu.Softmax(toHost(x), axis))
+	}
+	z.GPU = handle.Softmax(x.GPU, x.Shape, a, 0)
+	z.Shape = x.Shape
+	return z
+}
+
+func LogSoftmax(x Tensor, axis int) (z Tensor) {
+	a := matrixAxis(x, axis)
+	if a < 0 {
+		return fromHost(cpu.LogSoftmax(toHost(x), axis))
+	}
+	z.GPU = handle.Softmax(x.GPU, x.Shape, a, 1)
+	z.Shape = x.Shape
+	return z
+}
+
+func LogSumExp(x Tensor, axis int) (z Tensor) {
+	a := matrixAxis(x, axis)
+	if a < 0 {
+		return fromHost(cpu.LogSumExp(toHost(x), axis))
+	}
+	z.GPU = handle.Softmax(x.GPU, x.Shape, a, 2)
+	z.Shape = []int{x.Shape[0], x.Shape[1]}
+	z.Shape[a] = 1
+	return z
+}
+
+func SoftmaxBackward(y, grad Tensor, axis int) (z Tensor) {
+	a := matrixAxis(y, axis)
+	if a < 0 {
+		return fromHost(cpu.SoftmaxBackward(toHost(y), toHost(grad), axis))
+	}
+	z.GPU = handle.SoftmaxBackward(y.GPU, grad.GPU, y.Shape, a, false)
+	z.Shape = y.Shape
+	return z
+}
+
+func LogSoftmaxBackward(y, grad Tensor, axis int) (z Tensor) {
+	a := matrixAxis(y, axis)
+	if a < 0 {
+		return fromHost(cpu.LogSoftmaxBackward(toHost(y), toHost(grad), axis))
+	}
+	z.GPU = handle.SoftmaxBackward(y.GPU, grad.GPU, y.Shape, a, true)
+	z.Shape = y.Shape
+	return z
+}
+
+func SoftmaxCrossEntropy(logits, target Tensor) (float64, Tensor) {
+	if logits.Shape[0] != target.Shape[0] || logits.Shape[1] != target.Shape[1] {
+		log.Fatal("SoftmaxCrossEntropy.mismatch logits and target shape")
+	}
+	n := float64(logits.Shape[0])
+	logp := handle.Softmax(logits.GPU, logits.Shape, 1, 1)
+	prod := handle.Mul(target.GPU, logp, logits.Shape)
+	loss := -handle.Sum(prod, logits.Shape) / n
+	p := handle.Softmax(logits.GPU, logits.Shape, 1, 0)
+	diff := handle.Sub(p, target.GPU, logits.Shape)
+	grad := Tensor{Shape: logits.Shape}
+	grad.GPU = handle.MulE(diff, 1/n, logits.Shape)
+	handle.Free(logp)
+	handle.Free(prod)
+	handle.Free(p)
+	handle.Free(diff)
+	return loss, grad
+}
+
+func SparseSoftmaxCrossEntropy(logits Tensor, labels []int) (float64, Tensor) {
+	n, c := logits.Shape[0], logits.Shape[1]
+	if len(labels) != n {
+		log.Fatal("SparseSoftmaxCrossEntropy.mismatch logits and labels size")
+	}
+	target := cpu.Full([]int{n, c}, 0)
+	for i, label := range labels {
+		if label < 0 || label >= c {
+			log.Fatal("SparseSoftmaxCrossEntropy.label out of range")
+		}
+		target.CPU[i][label] = 1
+	}
+	t := fromHost(target)
+	defer handle.Free(t.GPU)
+	return SoftmaxCrossEntropy(logits, t)
+}
+
+func activation(x Tensor, op int, alpha float32) (z Tensor) {
+	z.GPU = handle.Activation(x.GPU, x.Shape, op, alpha)
+	z.Shape = x.Shape
+	return z
+}
+
+func ReLU(x Tensor) Tensor {
+	return activation(x, gpu.ActReLU, 0)
+}
+
+func ReLUDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActReLU+gpu.Derivative, 0)
+}
+
+func LeakyReLU(x Tensor, alpha float64) Tensor {
+	return activation(x, gpu.ActLeakyReLU, float32(alpha))
+}
+
+func LeakyReLUDerivative(x Tensor, alpha float64) Tensor {
+	return activation(x, gpu.ActLeakyReLU+gpu.Derivative, float32(alpha))
+}
+
+func ELU(x Tensor, alpha float64) Tensor {
+	return activation(x, gpu.ActELU, float32(alpha))
+}
+
+func ELUDerivative(x Tensor, alpha float64) Tensor {
+	return activation(x, gpu.ActELU+gpu.Derivative, float32(alpha))
+}
+
+func GELU(x Tensor) Tensor {
+	return activation(x, gpu.ActGELU, 0)
+}
+
+func GELUDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActGELU+gpu.Derivative, 0)
+}
+
+func SiLU(x Tensor) Tensor {
+	return activation(x, gpu.ActSiLU, 0)
+}
+
+func SiLUDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActSiLU+gpu.Derivative, 0)
+}
+
+func Swish(x Tensor) Tensor {
+	return activation(x, gpu.ActSiLU, 0)
+}
+
+func SwishDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActSiLU+gpu.Derivative, 0)
+}
+
+func Sigmoid(x Tensor) Tensor {
+	return activation(x, gpu.ActSigmoid, 0)
+}
+
+func SigmoidDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActSigmoid+gpu.Derivative, 0)
+}
+
+func Tanh(x Tensor) Tensor {
+	return activation(x, gpu.ActTanh, 0)
+}
+
+func TanhDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActTanh+gpu.Derivative, 0)
+}
+
+func Softplus(x Tensor) Tensor {
+	return activation(x, gpu.ActSoftplus, 0)
+}
+
+func SoftplusDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActSoftplus+gpu.Derivative, 0)
+}
+
+func HardSigmoid(x Tensor) Tensor {
+	return activation(x, gpu.ActHardSigmoid, 0)
+}
+
+func HardSigmoidDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActHardSigmoid+gpu.Derivative, 0)
+}
+
+func Mish(x Tensor) Tensor {
+	return activation(x, gpu.ActMish, 0)
+}
+
+func MishDerivative(x Tensor) Tensor {
+	return activation(x, gpu.ActMish+gpu.Derivative, 0)
+}
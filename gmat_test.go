@@ -1,4 +1,6 @@
+//go:build gpu
 // +build gpu
+
 // Copyright 2018 kurosawa. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
@@ -18,6 +20,8 @@ package gmat
 
 import (
 	"fmt"
+	"github.com/kuroko1t/gmat/cpu"
+	"math"
 	"testing"
 )
 
@@ -64,6 +68,22 @@ func ExpValueCheck(zReal float64, zExp float64, t *testing.T) {
 	}
 }
 
+func ExpNearCheck(zReal [][]float64, zExp [][]float64, tol float64, t *testing.T) {
+	success := true
+	for i, zArray := range zExp {
+		for j, _ := range zArray {
+			if math.Abs(zExp[i][j]-zReal[i][j]) > tol {
+				success = false
+			}
+		}
+	}
+	if !success {
+		fmt.Println("Real:", zReal)
+		fmt.Println("Exp:", zExp)
+		t.Fatal("failed Test!")
+	}
+}
+
 func TestDotSuccess(t *testing.T) {
 	xdot := [][]float64{
 		{2, 3, 6},
@@ -394,16 +414,40 @@ func TestlogSuccess(t *testing.T) {
 	ExpCheck(zReal, zExp, t)
 }
 
-func TestRandomNormSuccess(t *testing.T) {
+func TestUniformSuccess(t *testing.T) {
 	// make random matrix
 	shape := []int{2, 3}
-	zRealGPU := RandomNorm(shape)
+	zRealGPU := Uniform(0.0, 1.0, shape...)
 	CopyD2H(&zRealGPU)
 	zReal := zRealGPU.CPU
 	ExpRangeCheck(zReal, 0.0, 1.0, t)
 
 }
 
+func TestRandomNormSuccess(t *testing.T) {
+	// an odd size exercises the padding of the pairwise curand generator
+	z := ToSlice(RandomNorm([]int{3, 333}))
+	if len(z) != 999 {
+		t.Fatal("failed Test!")
+	}
+	mean, sq := 0.0, 0.0
+	for _, v := range z {
+		mean += v / 999
+		sq += v * v / 999
+	}
+	if math.Abs(mean) > 0.15 || math.Abs(sq-mean*mean-1) > 0.15 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestTruncatedNormalSuccess(t *testing.T) {
+	shape := []int{3, 3}
+	zRealGPU := TruncatedNormal(1.0, 0.5, shape...)
+	CopyD2H(&zRealGPU)
+	zReal := zRealGPU.CPU
+	ExpRangeCheck(zReal, 0.0, 2.0, t)
+}
+
 func TestTSuccess(t *testing.T) {
 	var x = [][]float64{
 		{2, 1, 3},
@@ -486,15 +530,23 @@ func TestArgMaxColSuccess(t *testing.T) {
 		{4, 4},
 	}
 	zExp := [][]float64{
-		{2, 2},
-		{3, 3},
-		{4, 4},
+		{1, 1},
+		{0, 0},
+		{0, 0},
 	}
 	xGPU := CopyH2D(x)
 	zRealGPU := ArgMaxCol(xGPU)
 	CopyD2H(&zRealGPU)
 	zReal := zRealGPU.CPU
 	ExpCheck(zReal, zExp, t)
+	// the largest of negative values, not the one of largest magnitude
+	xGPU = CopyH2D([][]float64{
+		{-3, -1, -2},
+		{-1, -5, -4},
+	})
+	zRealGPU = ArgMaxCol(xGPU)
+	CopyD2H(&zRealGPU)
+	ExpCheck(zRealGPU.CPU, [][]float64{{1, 1, 1}, {0, 0, 0}}, t)
 }
 
 func TestSumSuccess(t *testing.T) {
@@ -520,3 +572,153 @@ func TestMaxSuccess(t *testing.T) {
 	zReal := Max(xGPU)
 	ExpValueCheck(zReal, zExp, t)
 }
+
+func TestFullSuccess(t *testing.T) {
+	zExp := [][]float64{
+		{2.5, 2.5, 2.5},
+		{2.5, 2.5, 2.5},
+	}
+	zRealGPU := Full(2.5, 2, 3)
+	CopyD2H(&zRealGPU)
+	zReal := zRealGPU.CPU
+	ExpCheck(zReal, zExp, t)
+}
+
+func TestTrilSuccess(t *testing.T) {
+	var x = [][]float64{
+		{1, 2, 3},
+		{4, 5, 6},
+	}
+	zExp := [][]float64{
+		{1, 0, 0},
+		{4, 5, 0},
+	}
+	xGPU := CopyH2D(x)
+	zRealGPU := Tril(xGPU, 0)
+	CopyD2H(&zRealGPU)
+	zReal := zRealGPU.CPU
+	ExpCheck(zReal, zExp, t)
+}
+
+func TestSoftmaxSuccess(t *testing.T) {
+	// large logits must not overflow
+	var x = [][]float64{
+		{1000, 1001, 1002},
+		{-5, 0, 5},
+	}
+	xCPU := cpu.Tensor{CPU: x, Shape: []int{2, 3}}
+	xGPU := CopyH2D(x)
+	for _, axis := range []int{0, 1, -1} {
+		zRealGPU := Softmax(xGPU, axis)
+		CopyD2H(&zRealGPU)
+		ExpNearCheck(zRealGPU.CPU, cpu.Softmax(xCPU, axis).CPU, 1e-5, t)
+		zRealGPU = LogSoftmax(xGPU, axis)
+		CopyD2H(&zRealGPU)
+		ExpNearCheck(zRealGPU.CPU, cpu.LogSoftmax(xCPU, axis).CPU, 1e-4, t)
+	}
+	zRealGPU := LogSumExp(xGPU, 1)
+	CopyD2H(&zRealGPU)
+	ExpNearCheck(zRealGPU.CPU, cpu.LogSumExp(xCPU, 1).CPU, 1e-3, t)
+}
+
+func TestSoftmaxCrossEntropySuccess(t *testing.T) {
+	var x = [][]float64{
+		{1, 2, 3},
+		{100, 0, -100},
+	}
+	labels := []int{2, 1}
+	lossExp, gradExp := cpu.SparseSoftmaxCrossEntropy(x, labels)
+	loss, gradGPU := SparseSoftmaxCrossEntropy(CopyH2D(x), labels)
+	CopyD2H(&gradGPU)
+	ExpNearCheck([][]float64{{loss}}, [][]float64{{lossExp}}, 1e-3, t)
+	ExpNearCheck(gradGPU.CPU, gradExp, 1e-5, t)
+}
+
+func TestActivationSuccess(t *testing.T) {
+	var x = [][]float64{
+		{-4, -1, 0.5},
+		{2, 3.5, 100},
+	}
+	xCPU := cpu.Tensor{CPU: x, Shape: []int{2, 3}}
+	xGPU := CopyH2D(x)
+	pairs := []struct {
+		gpu func(Tensor) Tensor
+		cpu func(cpu.Tensor) cpu.Tensor
+	}{
+		{ReLU, cpu.ReLU}, {ReLUDerivative, cpu.ReLUDerivative},
+		{GELU, cpu.GELU}, {GELUDerivative, cpu.GELUDerivative},
+		{SiLU, cpu.SiLU}, {SiLUDerivative, cpu.SiLUDerivative},
+		{Sigmoid, cpu.Sigmoid}, {SigmoidDerivative, cpu.SigmoidDerivative},
+		{Tanh, cpu.Tanh}, {TanhDerivative, cpu.TanhDerivative},
+		{Softplus, cpu.Softplus}, {SoftplusDerivative, cpu.SoftplusDerivative},
+		{HardSigmoid, cpu.HardSigmoid}, {HardSigmoidDerivative, cpu.HardSigmoidDerivative},
+		{Mish, cpu.Mish}, {MishDerivative, cpu.MishDerivative},
+	}
+	for _, pair := range pairs {
+		zRealGPU := pair.gpu(xGPU)
+		CopyD2H(&zRealGPU)
+		ExpNearCheck(zRealGPU.CPU, pair.cpu(xCPU).CPU, 1e-4, t)
+	}
+	zRealGPU := ELU(xGPU, 0.5)
+	CopyD2H(&zRealGPU)
+	ExpNearCheck(zRealGPU.CPU, cpu.ELU(xCPU, 0.5).CPU, 1e-5, t)
+	zRealGPU = LeakyReLUDerivative(xGPU, 0.2)
+	CopyD2H(&zRealGPU)
+	ExpNearCheck(zRealGPU.CPU, cpu.LeakyReLUDerivative(xCPU, 0.2).CPU, 1e-6, t)
+}
+
+func TestSumNegativeSuccess(t *testing.T) {
+	var x = [][]float64{
+		{1, -2},
+		{-3, 1},
+		{4, -4},
+	}
+	xGPU := CopyH2D(x)
+	zRealGPU := SumRow(xGPU)
+	CopyD2H(&zRealGPU)
+	ExpCheck(zRealGPU.CPU, [][]float64{{2, -5}}, t)
+	zRealGPU = SumCol(xGPU)
+	CopyD2H(&zRealGPU)
+	ExpCheck(zRealGPU.CPU, [][]float64{{-1}, {-2}, {0}}, t)
+	ExpValueCheck(Sum(xGPU), -3, t)
+	// Add leaves its inputs untouched
+	zRealGPU = Add(xGPU, xGPU)
+	CopyD2H(&zRealGPU)
+	ExpCheck(zRealGPU.CPU, [][]float64{{2, -4}, {-6, 2}, {8, -8}}, t)
+	CopyD2H(&xGPU)
+	ExpCheck(xGPU.CPU, x, t)
+}
+
+func TestCastColumnSuccess(t *testing.T) {
+	var x = [][]float64{
+		{1},
+		{2},
+		{3},
+	}
+	zRealGPU := Cast(CopyH2D(x), 2)
+	CopyD2H(&zRealGPU)
+	ExpCheck(zRealGPU.CPU, [][]float64{{1, 1}, {2, 2}, {3, 3}}, t)
+}
+
+func TestBatchDotSuccess(t *testing.T) {
+	x := cpu.Normal(0, 1, []int{3, 2, 4})
+	y := cpu.Normal(0, 1, []int{3, 5, 4})
+	zExp := cpu.BatchDot(x.CPU3D, y.CPU3D, false, true)
+	zGPU := BatchDot(FromSlice(cpu.Flatten(x), 3, 2, 4), FromSlice(cpu.Flatten(y), 3, 5, 4), false, true)
+	zReal := cpu.Unflatten(ToSlice(zGPU), zGPU.Shape)
+	for b := range zExp {
+		ExpNearCheck(zReal.CPU3D[b], zExp[b], 1e-4, t)
+	}
+}
+
+func TestIndexAddSuccess(t *testing.T) {
+	var x = [][]float64{
+		{1, 2},
+		{3, 4},
+		{5, 6},
+	}
+	xGPU := CopyH2D(x)
+	IndexAdd(xGPU, []int{2, 0, 2}, CopyH2D([][]float64{{1, 1}, {2, 2}, {3, 3}}))
+	CopyD2H(&xGPU)
+	ExpCheck(xGPU.CPU, [][]float64{{3, 4}, {3, 4}, {9, 10}}, t)
+}
@@ -1,4 +1,6 @@
+//go:build gpu
 // +build gpu
+
 // Copyright 2018 kurosawa. All Rights Reserved.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
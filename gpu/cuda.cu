@@ -26,9 +26,25 @@ void ksub(float *a, float *b, float *c) {
 }
 
 __global__
-void kfill(float *a, float b) {
+void kfill(float *a, float b, int n) {
   int i = blockIdx.x*blockDim.x + threadIdx.x;
-  a[i] = b;
+  if (i < n) {
+    a[i] = b;
+  }
+}
+
+__global__
+void ktri(float *a, int m, int n, int k, int upper, float *c) {
+  // a is column major with m rows
+  int i = blockIdx.x*blockDim.x + threadIdx.x;
+  if (i < m * n) {
+    int d = i / m - i % m;
+    if ((upper && d >= k) || (!upper && d <= k)) {
+      c[i] = a[i];
+    } else {
+      c[i] = 0;
+    }
+  }
 }
 
 __global__
@@ -47,6 +63,104 @@ void kaxpye(float *a, float b, float c, float *d) {
   d[i] = a[i] *b + c;
 }
 
+__global__
+void ksoftmax(float *a, int slices, int count, int sliceStride, int elemStride, int mode, float *c) {
+  // one thread per slice; mode 0 softmax, 1 log softmax, 2 log-sum-exp
+  int s = blockIdx.x*blockDim.x + threadIdx.x;
+  if (s >= slices) {
+    return;
+  }
+  float *x = a + s * sliceStride;
+  float max = -INFINITY;
+  for (int j = 0; j < count; j++) {
+    max = fmaxf(max, x[j * elemStride]);
+  }
+  float sum = 0;
+  for (int j = 0; j < count; j++) {
+    sum += expf(x[j * elemStride] - max);
+  }
+  float lse = max + logf(sum);
+  if (isinf(max)) {
+    lse = max;
+  }
+  if (mode == 2) {
+    c[s] = lse;
+    return;
+  }
+  float *z = c + s * sliceStride;
+  for (int j = 0; j < count; j++) {
+    float v = x[j * elemStride] - lse;
+    z[j * elemStride] = mode == 0 ? expf(v) : v;
+  }
+}
+
+__global__
+void ksoftmaxBackward(float *y, float *g, int slices, int count, int sliceStride, int elemStride, int logmode, float *c) {
+  // y is the softmax (logmode 0) or log softmax (logmode 1) output
+  int s = blockIdx.x*blockDim.x + threadIdx.x;
+  if (s >= slices) {
+    return;
+  }
+  int base = s * sliceStride;
+  float sum = 0;
+  for (int j = 0; j < count; j++) {
+    int i = base + j * elemStride;
+    sum += logmode ? g[i] : y[i] * g[i];
+  }
+  for (int j = 0; j < count; j++) {
+    int i = base + j * elemStride;
+    c[i] = logmode ? g[i] - expf(y[i]) * sum : y[i] * (g[i] - sum);
+  }
+}
+
+__device__
+float dsigmoid(float x) {
+  if (x >= 0) {
+    return 1 / (1 + expf(-x));
+  }
+  float e = expf(x);
+  return e / (1 + e);
+}
+
+__device__
+float dsoftplus(float x) {
+  return fmaxf(x, 0) + log1pf(expf(-fabsf(x)));
+}
+
+__global__
+void kactivation(float *a, int op, float alpha, int n, float *c) {
+  // op 2k evaluates activation k and op 2k+1 its derivative, in the order
+  // of the Act constants in gmat.go
+  int i = blockIdx.x*blockDim.x + threadIdx.x;
+  if (i >= n) {
+    return;
+  }
+  float x = a[i];
+  float s, t;
+  switch (op) {
+  case 0: c[i] = fmaxf(x, 0); break;
+  case 1: c[i] = x > 0 ? 1 : 0; break;
+  case 2: c[i] = x > 0 ? x : alpha * x; break;
+  case 3: c[i] = x > 0 ? 1 : alpha; break;
+  case 4: c[i] = x > 0 ? x : alpha * expm1f(x); break;
+  case 5: c[i] = x > 0 ? 1 : alpha * expf(x); break;
+  case 6: c[i] = 0.5f * x * (1 + erff(x * M_SQRT1_2)); break;
+  case 7: c[i] = 0.5f * (1 + erff(x * M_SQRT1_2)) + x * expf(-0.5f * x * x) * 0.3989422804f; break;
+  case 8: c[i] = x * dsigmoid(x); break;
+  case 9: s = dsigmoid(x); c[i] = s * (1 + x * (1 - s)); break;
+  case 10: c[i] = dsigmoid(x); break;
+  case 11: s = dsigmoid(x); c[i] = s * (1 - s); break;
+  case 12: c[i] = tanhf(x); break;
+  case 13: t = tanhf(x); c[i] = 1 - t * t; break;
+  case 14: c[i] = dsoftplus(x); break;
+  case 15: c[i] = dsigmoid(x); break;
+  case 16: c[i] = fminf(fmaxf(x / 6 + 0.5f, 0), 1); break;
+  case 17: c[i] = (x > -3 && x < 3) ? 1.0f / 6 : 0; break;
+  case 18: c[i] = x * tanhf(dsoftplus(x)); break;
+  case 19: t = tanhf(dsoftplus(x)); c[i] = t + x * (1 - t * t) * dsigmoid(x); break;
+  }
+}
+
 __global__
 void kexp(float *a, float b, float c, float *d) {
   int i = blockIdx.x*blockDim.x + threadIdx.x;
@@ -65,6 +179,14 @@ void klog(float *a, float b, float *c) {
   c[i] = logf(a[i] + b);
 }
 
+__global__
+void kclip(float *a, float lo, float hi, int n, float *c) {
+  int i = blockIdx.x*blockDim.x + threadIdx.x;
+  if (i < n) {
+    c[i] = fminf(fmaxf(a[i], lo), hi);
+  }
+}
+
 __global__
 void ksqrtT(float *a, float b, float d, float *c) {
   int i = blockIdx.x*blockDim.x + threadIdx.x;
@@ -102,12 +224,27 @@ extern "C" {
   void gexpT(int blocks, int threads, float *a, float b, float c, float *d) {
     kexpT<<<blocks, threads>>>(a, b, c, d);
   }
-  void gfill(int blocks, int threads, float *a, float b) {
-    kfill<<<blocks, threads>>>(a, b);
+  void gfill(int blocks, int threads, float *a, float b, int n) {
+    kfill<<<blocks, threads>>>(a, b, n);
+  }
+  void gtri(int blocks, int threads, float *a, int m, int n, int k, int upper, float *c) {
+    ktri<<<blocks, threads>>>(a, m, n, k, upper, c);
+  }
+  void gsoftmax(int blocks, int threads, float *a, int slices, int count, int sliceStride, int elemStride, int mode, float *c) {
+    ksoftmax<<<blocks, threads>>>(a, slices, count, sliceStride, elemStride, mode, c);
+  }
+  void gsoftmaxBackward(int blocks, int threads, float *y, float *g, int slices, int count, int sliceStride, int elemStride, int logmode, float *c) {
+    ksoftmaxBackward<<<blocks, threads>>>(y, g, slices, count, sliceStride, elemStride, logmode, c);
+  }
+  void gactivation(int blocks, int threads, float *a, int op, float alpha, int n, float *c) {
+    kactivation<<<blocks, threads>>>(a, op, alpha, n, c);
   }
   void glog(int blocks, int threads, float *a, float b, float *c) {
     klog<<<blocks, threads>>>(a, b, c);
   }
+  void gclip(int blocks, int threads, float *a, float lo, float hi, int n, float *c) {
+    kclip<<<blocks, threads>>>(a, lo, hi, n, c);
+  }
   void gsqrtT(int blocks, int threads, float *a, float b, float d, float *c) {
     ksqrtT<<<blocks, threads>>>(a, b, d, c);
   }
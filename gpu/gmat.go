@@ -56,6 +56,10 @@ func (handle *Handle) Malloc(n int) *C.float {
 	return (*C.float)(gpuptr)
 }
 
+func (handle *Handle) Free(x *C.float) {
+	cudaCheck(C.cudaFree(unsafe.Pointer(x)))
+}
+
 func (handle *Handle) CopyH2D(x [][]float64) (int, int, *C.float) {
 	n := len(x)
 	m := len(x[0])
@@ -70,25 +74,59 @@ func (handle *Handle) CopyH2D(x [][]float64) (int, int, *C.float) {
 }
 
 func (handle *Handle) MakeInit(m, n int, value float32) *C.float {
+	return handle.Fill([]int{m, n}, value)
+}
+
+func (handle *Handle) Fill(shape []int, value float32) *C.float {
+	size := sizeTensor(shape)
+	z := handle.Malloc(size)
+	N := C.int(size)
+	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
+	C.gfill(blocksPerGrid, threadsPerBlock, z, C.float(value), N)
+	return z
+}
+
+func (handle *Handle) Tri(x *C.float, shape []int, k int, upper bool) *C.float {
+	m := shape[0]
+	n := shape[1]
 	z := handle.Malloc(m * n)
 	N := C.int(m * n)
-	var threadsPerBlock C.int = 256
 	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
-	C.gfill(blocksPerGrid, threadsPerBlock, z, C.float(value))
+	var up C.int = 0
+	if upper {
+		up = 1
+	}
+	C.gtri(blocksPerGrid, threadsPerBlock, x, C.int(m), C.int(n), C.int(k), up, z)
 	return z
 }
 
 func (handle *Handle) CopyD2H(shape []int, gpuptr *C.float) [][]float64 {
-	n := shape[0]
-	m := shape[1]
-	z := make([]float32, n*m)
+	z64 := handle.Download(shape, gpuptr)
+	defer cudaCheck(C.cudaFree(unsafe.Pointer(gpuptr)))
+	return z64
+}
+
+func (handle *Handle) Download(shape []int, gpuptr *C.float) [][]float64 {
+	return f2d(handle.CopyD2H1D(shape, gpuptr), shape[0], shape[1])
+}
+
+func (handle *Handle) CopyH2D1D(x []float32) *C.float {
+	z := handle.Malloc(len(x))
+	typeSize := int(unsafe.Sizeof(float32(0)))
+	cudaCheck(C.cudaMemcpy(unsafe.Pointer(z), unsafe.Pointer(&x[0]),
+		C.size_t(len(x)*typeSize), C.cudaMemcpyHostToDevice))
+	return z
+}
+
+func (handle *Handle) CopyD2H1D(shape []int, gpuptr *C.float) []float32 {
+	// unlike CopyD2H the device memory stays allocated
+	size := sizeTensor(shape)
+	z := make([]float32, size)
 	typeSize := int(unsafe.Sizeof(float32(0)))
 	cudaCheck(C.cudaMemcpy(unsafe.Pointer(&z[0]),
 		unsafe.Pointer(gpuptr),
-		C.size_t(n*m*typeSize), C.cudaMemcpyDeviceToHost))
-	z64 := f2d(z, n, m)
-	defer cudaCheck(C.cudaFree(unsafe.Pointer(gpuptr)))
-	return z64
+		C.size_t(size*typeSize), C.cudaMemcpyDeviceToHost))
+	return z
 }
 
 func (handle *Handle) Dot(x, y *C.float, m, n, k int) *C.float {
@@ -145,75 +183,110 @@ func (handle *Handle) DotT(x, y *C.float, m, n, k int) *C.float {
 	return z
 }
 
+// BatchDot multiplies batch row-major matrices op(x) [m, k] and op(y) [k, n].
+// cuBLAS is column-major, so it computes z^T = op(y)^T op(x)^T instead.
+func (handle *Handle) BatchDot(x, y *C.float, batch, m, n, k int, transX, transY bool) *C.float {
+	z := handle.Malloc(batch * m * n)
+	if handle.cublasHandle == nil {
+		handle.cublasHandle = cublaInit()
+	}
+	var alpha C.float = 1
+	var beta C.float = 0
+	opY, ldy := C.cublasOperation_t(C.CUBLAS_OP_N), n
+	if transY {
+		opY, ldy = C.CUBLAS_OP_T, k
+	}
+	opX, ldx := C.cublasOperation_t(C.CUBLAS_OP_N), k
+	if transX {
+		opX, ldx = C.CUBLAS_OP_T, m
+	}
+	cublasCheck(C.cublasSgemmStridedBatched(handle.cublasHandle,
+		opY, opX,
+		C.int(n), C.int(m), C.int(k),
+		&alpha,
+		y, C.int(ldy), C.longlong(k*n),
+		x, C.int(ldx), C.longlong(m*k),
+		&beta,
+		z, C.int(n), C.longlong(m*n),
+		C.int(batch)))
+	return z
+}
+
 func (handle *Handle) Add(x, y *C.float, n int) *C.float {
 	if handle.cublasHandle == nil {
 		handle.cublasHandle = cublaInit()
 	}
+	// saxpy accumulates in place, so work on a copy of y
+	z := handle.Malloc(n)
+	cudaCheck(C.cudaMemcpy(unsafe.Pointer(z), unsafe.Pointer(y),
+		C.size_t(n*int(unsafe.Sizeof(float32(0)))), C.cudaMemcpyDeviceToDevice))
 	var alpha C.float = 1
 	cublasCheck(C.cublasSaxpy(handle.cublasHandle,
 		C.int(n),
 		&alpha,
 		x, 1,
-		y, 1))
-	return y
+		z, 1))
+	return z
 }
 
-func (handle *Handle) SumRow(x *C.float, shape []int) *C.float {
-	//sum | direction [a,b]
-	//    ^           [a,b]
-	m := shape[0]
-	n := shape[1]
-	z := handle.Malloc(1 * n)
+// IndexAdd adds row i of the column major src to row index[i] of x in
+// place; rows are strided by the number of rows of each matrix.
+func (handle *Handle) IndexAdd(x *C.float, shape []int, index []int, src *C.float, srcShape []int) {
 	if handle.cublasHandle == nil {
 		handle.cublasHandle = cublaInit()
 	}
-	var hostSum C.float
-	var offset C.size_t = 0
-	var offsetZ C.size_t = 0
-	for i := 0; i < n; i++ {
-		xoffset := (*C.float)(unsafe.Pointer((uintptr(offset) + uintptr(unsafe.Pointer(x)))))
-		zoffset := unsafe.Pointer((uintptr(offsetZ) + uintptr(unsafe.Pointer(z))))
-		cublasCheck(C.cublasSasum(handle.cublasHandle,
-			C.int(m),
-			xoffset, C.int(1), &hostSum))
-		C.cudaMemcpy(zoffset, unsafe.Pointer(&hostSum),
-			(C.size_t)(unsafe.Sizeof(float32(0))), C.cudaMemcpyHostToDevice)
-		offset += (C.size_t)(unsafe.Sizeof(float32(0)) * uintptr(m))
-		offsetZ += (C.size_t)(unsafe.Sizeof(float32(0)))
+	size := C.size_t(unsafe.Sizeof(float32(0)))
+	var alpha C.float = 1
+	for i, row := range index {
+		cublasCheck(C.cublasSaxpy(handle.cublasHandle,
+			C.int(shape[1]),
+			&alpha,
+			goffset(src, C.size_t(i)*size), C.int(srcShape[0]),
+			goffset(x, C.size_t(row)*size), C.int(shape[0])))
 	}
-	return z
 }
 
-func (handle *Handle) SumCol(x *C.float, shape []int) *C.float {
-	//sum | direction [a,b]
-	//    ^           [a,b]
-	m := shape[0]
-	n := shape[1]
-	z := handle.Malloc(m * 1)
+// sumAxis multiplies the column major m x n matrix x (or its transpose)
+// with a vector of ones. Sasum would sum absolute values.
+func (handle *Handle) sumAxis(x *C.float, m, n int, trans bool) *C.float {
 	if handle.cublasHandle == nil {
 		handle.cublasHandle = cublaInit()
 	}
-	hostSum := make([]float32, m)
-	var tmp C.float
-	var offset C.size_t = 0
-	for i := 0; i < m; i++ {
-		xoffset := (*C.float)(unsafe.Pointer((uintptr(offset) + uintptr(unsafe.Pointer(x)))))
-		cublasCheck(C.cublasSasum(handle.cublasHandle,
-			C.int(n),
-			xoffset, C.int(m), &tmp))
-		hostSum[i] = float32(tmp)
-		offset += (C.size_t)(unsafe.Sizeof(float32(0)))
+	op := C.cublasOperation_t(C.CUBLAS_OP_N)
+	size, length := m, n
+	if trans {
+		op = C.cublasOperation_t(C.CUBLAS_OP_T)
+		size, length = n, m
 	}
-	C.cudaMemcpy(unsafe.Pointer(z), unsafe.Pointer(&hostSum[0]),
-		(C.size_t)(unsafe.Sizeof(float32(0))*uintptr(m)), C.cudaMemcpyHostToDevice)
+	ones := handle.Fill([]int{length}, 1)
+	defer handle.Free(ones)
+	z := handle.Malloc(size)
+	var alpha C.float = 1
+	var beta C.float = 0
+	cublasCheck(C.cublasSgemv(handle.cublasHandle, op,
+		C.int(m), C.int(n),
+		&alpha, x, C.int(m),
+		ones, 1,
+		&beta, z, 1))
 	return z
 }
 
+func (handle *Handle) SumRow(x *C.float, shape []int) *C.float {
+	//sum | direction [a,b]
+	//    ^           [a,b]
+	return handle.sumAxis(x, shape[0], shape[1], true)
+}
+
+func (handle *Handle) SumCol(x *C.float, shape []int) *C.float {
+	//sum -> direction [a,a]
+	//                 [b,b]
+	return handle.sumAxis(x, shape[0], shape[1], false)
+}
+
 func (handle *Handle) Mul(x *C.float, y *C.float, shape []int) *C.float {
-	m := shape[0]
-	n := shape[1]
-	z := handle.Malloc(m * n)
-	N := C.int(m * n)
+	size := sizeTensor(shape)
+	z := handle.Malloc(size)
+	N := C.int(size)
 	var threadsPerBlock C.int = 256
 	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
 	//fmt.Println(blocksPerGrid)
@@ -222,10 +295,9 @@ func (handle *Handle) Mul(x *C.float, y *C.float, shape []int) *C.float {
 }
 
 func (handle *Handle) MulE(x *C.float, y float64, shape []int) *C.float {
-	m := shape[0]
-	n := shape[1]
-	z := handle.Malloc(m * n)
-	N := C.int(m * n)
+	size := sizeTensor(shape)
+	z := handle.Malloc(size)
+	N := C.int(size)
 	var threadsPerBlock C.int = 256
 	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
 	C.gmule(blocksPerGrid, threadsPerBlock, x, C.float(y), z)
@@ -233,10 +305,9 @@ func (handle *Handle) MulE(x *C.float, y float64, shape []int) *C.float {
 }
 
 func (handle *Handle) Div(x *C.float, y *C.float, shape []int) *C.float {
-	m := shape[0]
-	n := shape[1]
-	z := handle.Malloc(m * n)
-	N := C.int(m * n)
+	size := sizeTensor(shape)
+	z := handle.Malloc(size)
+	N := C.int(size)
 	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
 	C.gdiv(blocksPerGrid, threadsPerBlock, x, y, z)
 	return z
@@ -266,7 +337,7 @@ func (handle *Handle) Cast(x *C.float, shape []int, castSize int) *C.float {
 			zoffset := goffset(z, offset)
 			C.cudaMemcpyAsync(unsafe.Pointer(zoffset), unsafe.Pointer(x),
 				(C.size_t)(unsafe.Sizeof(float32(0))*uintptr(m)), C.cudaMemcpyDeviceToDevice, stream)
-			offset += (C.size_t)(unsafe.Sizeof(float32(0)) * uintptr(castSize))
+			offset += (C.size_t)(unsafe.Sizeof(float32(0)) * uintptr(m))
 		}
 		return z
 	} else {
@@ -319,14 +390,90 @@ func (handle *Handle) Log(x *C.float, shape []int, b float32) *C.float {
 	return z
 }
 
-func (handle *Handle) RandomNorm(shape []int) *C.float {
+// softmaxLayout describes the slices along axis of a column major matrix.
+func softmaxLayout(shape []int, axis int) (slices, count, sliceStride, elemStride int) {
+	m := shape[0]
+	n := shape[1]
+	if axis == 1 {
+		return m, n, 1, m
+	}
+	return n, m, m, 1
+}
+
+// Softmax computes softmax (mode 0), log softmax (mode 1) or log-sum-exp
+// (mode 2) along axis 0 or 1.
+func (handle *Handle) Softmax(x *C.float, shape []int, axis, mode int) *C.float {
+	slices, count, sliceStride, elemStride := softmaxLayout(shape, axis)
+	size := sizeTensor(shape)
+	if mode == 2 {
+		size = slices
+	}
+	z := handle.Malloc(size)
+	N := C.int(slices)
+	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
+	C.gsoftmax(blocksPerGrid, threadsPerBlock, x, C.int(slices), C.int(count),
+		C.int(sliceStride), C.int(elemStride), C.int(mode), z)
+	return z
+}
+
+func (handle *Handle) SoftmaxBackward(y, g *C.float, shape []int, axis int, logmode bool) *C.float {
+	slices, count, sliceStride, elemStride := softmaxLayout(shape, axis)
+	z := handle.Malloc(sizeTensor(shape))
+	var lm C.int = 0
+	if logmode {
+		lm = 1
+	}
+	N := C.int(slices)
+	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
+	C.gsoftmaxBackward(blocksPerGrid, threadsPerBlock, y, g, C.int(slices), C.int(count),
+		C.int(sliceStride), C.int(elemStride), lm, z)
+	return z
+}
+
+// Activation functions evaluated by Activation. Adding Derivative to a
+// constant selects the derivative of the function.
+const (
+	ActReLU = 2 * iota
+	ActLeakyReLU
+	ActELU
+	ActGELU
+	ActSiLU
+	ActSigmoid
+	ActTanh
+	ActSoftplus
+	ActHardSigmoid
+	ActMish
+)
+
+const Derivative = 1
+
+func (handle *Handle) Activation(x *C.float, shape []int, op int, alpha float32) *C.float {
+	size := sizeTensor(shape)
+	z := handle.Malloc(size)
+	N := C.int(size)
+	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
+	C.gactivation(blocksPerGrid, threadsPerBlock, x, C.int(op), C.float(alpha), N, z)
+	return z
+}
+
+func (handle *Handle) RandomNorm(shape []int, mean, std float32) *C.float {
+	if handle.curandgen == nil {
+		handle.curandgen = curandInit()
+	}
+	size := sizeTensor(shape)
+	// curand only generates normal values in pairs
+	z := handle.Malloc(size + size%2)
+	curandCheck(C.curandGenerateNormal(handle.curandgen, z, C.size_t(size+size%2), C.float(mean), C.float(std)))
+	return z
+}
+
+func (handle *Handle) RandomUniform(shape []int) *C.float {
 	if handle.curandgen == nil {
 		handle.curandgen = curandInit()
 	}
 	size := sizeTensor(shape)
 	z := handle.Malloc(size)
 	curandCheck(C.curandGenerateUniform(handle.curandgen, z, C.size_t(size)))
-	//curandCheck(C.curandGenerateNormal(handle.curandgen, z, C.size_t(size), 0.0, 0.5))
 	return z
 }
 
@@ -369,37 +516,12 @@ func (handle *Handle) SqrtT(x *C.float, shape []int, b, c float32) *C.float {
 	return z
 }
 
-func (handle *Handle) ArgMaxCol(x *C.float, shape []int) *C.float {
-	// max col
+func (handle *Handle) Clip(x *C.float, shape []int, lo, hi float32) *C.float {
 	size := sizeTensor(shape)
 	z := handle.Malloc(size)
-	if handle.cublasHandle == nil {
-		handle.cublasHandle = cublaInit()
-	}
-	m := shape[0]
-	n := shape[1]
-	tmpSum := handle.Malloc(m)
-	var tmp C.int
-	var offset C.size_t = 0
-	for i := 0; i < m; i++ {
-		xoffset := goffset(x, offset)
-		sumoffset := goffset(tmpSum, offset)
-		cublasCheck(C.cublasIsamax(handle.cublasHandle,
-			C.int(n),
-			xoffset, C.int(m), &tmp))
-		tmp = tmp - 1
-		xoffsetMax := goffset(xoffset, C.size_t(unsafe.Sizeof(float32(0))*uintptr(tmp*C.int(m))))
-		offset += (C.size_t)(unsafe.Sizeof(float32(0)))
-		C.cudaMemcpy(unsafe.Pointer(sumoffset), unsafe.Pointer(xoffsetMax),
-			(C.size_t)(unsafe.Sizeof(float32(0))), C.cudaMemcpyDeviceToDevice)
-	}
-	offset = 0
-	for i := 0; i < n; i++ {
-		zoffset := goffset(z, offset)
-		C.cudaMemcpy(unsafe.Pointer(zoffset), unsafe.Pointer(tmpSum),
-			(C.size_t)(unsafe.Sizeof(float32(0))*uintptr(m)), C.cudaMemcpyDeviceToDevice)
-		offset += (C.size_t)(unsafe.Sizeof(float32(0)) * uintptr(m))
-	}
+	N := C.int(size)
+	var blocksPerGrid C.int = (N + threadsPerBlock - 1) / threadsPerBlock
+	C.gclip(blocksPerGrid, threadsPerBlock, x, C.float(lo), C.float(hi), N, z)
 	return z
 }
 
@@ -413,11 +535,13 @@ func (handle *Handle) Sum(x *C.float, shape []int) float64 {
 	if handle.cublasHandle == nil {
 		handle.cublasHandle = cublaInit()
 	}
+	ones := handle.Fill(shape, 1)
+	defer handle.Free(ones)
 	var result C.float = 0
-	cublasCheck(C.cublasSasum(
+	cublasCheck(C.cublasSdot(
 		handle.cublasHandle,
 		C.int(size),
-		x, 1, &result))
+		x, 1, ones, 1, &result))
 	return float64(result)
 }
 
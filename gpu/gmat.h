@@ -7,6 +7,11 @@ void gexp(int blocks, int threads, float *a, float b, float c, float *d);
 void gexpT(int blocks, int threads, float *a, float b, float c, float *d);
 void glog(int blocks, int threads, float *a, float b, float *c);
 void gsub(int blocks, int threads, float *a, float *b, float *c);
-void gfill(int blocks, int threads, float *a, float b);
+void gfill(int blocks, int threads, float *a, float b, int n);
+void gtri(int blocks, int threads, float *a, int m, int n, int k, int upper, float *c);
+void gsoftmax(int blocks, int threads, float *a, int slices, int count, int sliceStride, int elemStride, int mode, float *c);
+void gsoftmaxBackward(int blocks, int threads, float *y, float *g, int slices, int count, int sliceStride, int elemStride, int logmode, float *c);
+void gactivation(int blocks, int threads, float *a, int op, float alpha, int n, float *c);
+void gclip(int blocks, int threads, float *a, float lo, float hi, int n, float *c);
 void gsqrtT(int blocks, int threads, float *a, float b, float d, float *c);
 void gdeviceMemset(int blocks, int threads, float *a, float *c);
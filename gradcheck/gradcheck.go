@@ -0,0 +1,179 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package gradcheck compares analytic gradients with central finite
+// differences.
+package gradcheck
+
+import (
+	"fmt"
+	"github.com/kuroko1t/gmat"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Options control the check. An element passes when
+// |analytic - numeric| <= AbsTol + RelTol * |numeric|, so a zero AbsTol
+// gives a pure relative check. Start from NewOptions to change single
+// fields.
+type Options struct {
+	Eps    float64
+	RelTol float64
+	AbsTol float64
+	// Worst is the number of mismatches kept in the report.
+	Worst int
+}
+
+var DefaultOptions = Options{Eps: 1e-6, RelTol: 1e-4, AbsTol: 1e-6, Worst: 5}
+
+// NewOptions returns a copy of DefaultOptions.
+func NewOptions() Options {
+	return DefaultOptions
+}
+
+func (o Options) check() {
+	if o.Eps <= 0 {
+		log.Fatal("gradcheck.Eps must be positive")
+	}
+	if o.RelTol < 0 || o.AbsTol < 0 || o.Worst < 0 {
+		log.Fatal("gradcheck.tolerances and Worst must not be negative")
+	}
+}
+
+type Mismatch struct {
+	Input    int
+	Index    []int
+	Analytic float64
+	Numeric  float64
+	AbsErr   float64
+	RelErr   float64
+	// excess is the error relative to the allowed tolerance
+	excess float64
+}
+
+type Report struct {
+	OK        bool
+	Shapes    [][]int
+	Checked   int
+	Failed    int
+	MaxAbsErr float64
+	MaxRelErr float64
+	Worst     []Mismatch
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "gradcheck: %d of %d elements failed, max abs err %g, max rel err %g\n",
+		r.Failed, r.Checked, r.MaxAbsErr, r.MaxRelErr)
+	for _, m := range r.Worst {
+		fmt.Fprintf(&b, "  input %d %v at %v: analytic %g numeric %g (abs %g rel %g)\n",
+			m.Input, r.Shapes[m.Input], m.Index, m.Analytic, m.Numeric, m.AbsErr, m.RelErr)
+	}
+	return b.String()
+}
+
+// unravel converts a row-major flat index into an index per axis.
+func unravel(flat int, shape []int) []int {
+	index := make([]int, len(shape))
+	for i := len(shape) - 1; i >= 0; i-- {
+		index[i] = flat % shape[i]
+		flat /= shape[i]
+	}
+	return index
+}
+
+// Check differentiates the scalar function f with respect to every
+// element of every input and compares with analytic, which holds one
+// gradient per input. The inputs are restored before returning.
+func Check(f func(inputs []gmat.Tensor) float64, inputs, analytic []gmat.Tensor, opt Options) Report {
+	if len(inputs) != len(analytic) {
+		log.Fatal("gradcheck.mismatch inputs and gradients")
+	}
+	opt.check()
+	report := Report{OK: true}
+	args := append([]gmat.Tensor{}, inputs...)
+	for n, input := range inputs {
+		report.Shapes = append(report.Shapes, input.Shape)
+		data := gmat.ToSlice(input)
+		grad := gmat.ToSlice(analytic[n])
+		if len(grad) != len(data) {
+			log.Fatal("gradcheck.mismatch input and gradient size")
+		}
+		for i := range data {
+			orig := data[i]
+			data[i] = orig + opt.Eps
+			args[n] = gmat.FromSlice(data, input.Shape...)
+			plus := f(args)
+			data[i] = orig - opt.Eps
+			args[n] = gmat.FromSlice(data, input.Shape...)
+			minus := f(args)
+			data[i] = orig
+			numeric := (plus - minus) / (2 * opt.Eps)
+			report.add(n, unravel(i, input.Shape), grad[i], numeric, opt)
+		}
+		args[n] = input
+	}
+	sort.Slice(report.Worst, func(i, j int) bool {
+		return report.Worst[i].excess > report.Worst[j].excess
+	})
+	if len(report.Worst) > opt.Worst {
+		report.Worst = report.Worst[:opt.Worst]
+	}
+	return report
+}
+
+func (r *Report) add(input int, index []int, analytic, numeric float64, opt Options) {
+	absErr := math.Abs(analytic - numeric)
+	relErr := absErr / math.Max(math.Abs(numeric), math.SmallestNonzeroFloat64)
+	r.Checked++
+	r.MaxAbsErr = math.Max(r.MaxAbsErr, absErr)
+	if numeric != 0 {
+		r.MaxRelErr = math.Max(r.MaxRelErr, relErr)
+	}
+	allowed := opt.AbsTol + opt.RelTol*math.Abs(numeric)
+	if absErr <= allowed && !math.IsNaN(analytic) {
+		return
+	}
+	r.OK = false
+	r.Failed++
+	excess := math.Inf(1)
+	if allowed > 0 && !math.IsNaN(absErr) {
+		excess = absErr / allowed
+	}
+	r.Worst = append(r.Worst, Mismatch{input, index, analytic, numeric, absErr, relErr, excess})
+	// keep memory bounded on large inputs
+	if len(r.Worst) > 4*opt.Worst+64 {
+		sort.Slice(r.Worst, func(i, j int) bool {
+			return r.Worst[i].excess > r.Worst[j].excess
+		})
+		r.Worst = r.Worst[:opt.Worst]
+	}
+}
+
+// CheckVJP checks the backward pass of a tensor valued f: analytic must
+// hold the gradients of sum(f(inputs) * gradOut).
+func CheckVJP(f func(inputs []gmat.Tensor) gmat.Tensor, inputs []gmat.Tensor, gradOut gmat.Tensor, analytic []gmat.Tensor, opt Options) Report {
+	weight := gmat.ToSlice(gradOut)
+	scalar := func(inputs []gmat.Tensor) float64 {
+		sum := 0.0
+		for i, v := range gmat.ToSlice(f(inputs)) {
+			sum += v * weight[i]
+		}
+		return sum
+	}
+	return Check(scalar, inputs, analytic, opt)
+}
@@ -0,0 +1,99 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package gradcheck
+
+import (
+	"github.com/kuroko1t/gmat"
+	"strings"
+	"testing"
+)
+
+func TestCheckSuccess(t *testing.T) {
+	x := gmat.Normal(0, 1, 3, 4)
+	w := gmat.Normal(0, 1, 4, 2)
+	// f = sum(x w), df/dx = 1 w^T, df/dw = x^T 1
+	f := func(in []gmat.Tensor) float64 {
+		return gmat.Sum(gmat.Dot(in[0], in[1]))
+	}
+	gx := gmat.Dot(gmat.Ones(3, 2), gmat.T(w))
+	gw := gmat.Dot(gmat.T(x), gmat.Ones(3, 2))
+	report := Check(f, []gmat.Tensor{x, w}, []gmat.Tensor{gx, gw}, DefaultOptions)
+	if !report.OK || report.Checked != 20 || report.Failed != 0 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestCheckVJPSuccess(t *testing.T) {
+	param := gmat.ConvParam{Stride: 2, Padding: 1}
+	x := gmat.Normal(0, 1, 2, 3, 8)
+	k := gmat.Normal(0, 1, 4, 3, 3)
+	f := func(in []gmat.Tensor) gmat.Tensor {
+		return gmat.Conv1D(in[0], in[1], gmat.Tensor{}, param)
+	}
+	gradOut := gmat.Normal(0, 1, f([]gmat.Tensor{x, k}).Shape...)
+	gx := gmat.Conv1DBackwardInput(gradOut, k, 8, param)
+	gk := gmat.Conv1DBackwardKernel(x, gradOut, 3, param)
+	report := CheckVJP(f, []gmat.Tensor{x, k}, gradOut, []gmat.Tensor{gx, gk}, DefaultOptions)
+	if !report.OK {
+		t.Fatal(report.String())
+	}
+}
+
+func TestCheckReportSuccess(t *testing.T) {
+	x := gmat.FromSlice([]float64{0.5, -1, 1.5, 2, -0.5, 1}, 2, 3)
+	f := func(in []gmat.Tensor) float64 {
+		return gmat.Sum(gmat.Apply(in[0], func(v float64) float64 {
+			return v * v
+		}))
+	}
+	grad := gmat.MulE(x, 2)
+	data := gmat.ToSlice(grad)
+	data[4] += 1
+	data[1] += 0.1
+	wrong := gmat.FromSlice(data, 2, 3)
+	report := Check(f, []gmat.Tensor{x}, []gmat.Tensor{wrong}, NewOptions())
+	if report.OK || report.Failed != 2 || len(report.Worst) != 2 {
+		t.Fatal("failed Test!")
+	}
+	worst := report.Worst[0]
+	if worst.Input != 0 || worst.Index[0] != 1 || worst.Index[1] != 1 {
+		t.Fatal("failed Test!")
+	}
+	if !strings.Contains(report.String(), "input 0 [2 3] at [1 1]") {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestCheckZeroTolSuccess(t *testing.T) {
+	// the numeric gradient of x*x at 0 is exactly 0, so only AbsTol can
+	// accept an analytic gradient that is slightly off
+	x := gmat.FromSlice([]float64{0, 1}, 1, 2)
+	f := func(in []gmat.Tensor) float64 {
+		return gmat.Sum(gmat.Mul(in[0], in[0]))
+	}
+	grad := gmat.FromSlice([]float64{1e-9, 2}, 1, 2)
+	if report := Check(f, []gmat.Tensor{x}, []gmat.Tensor{grad}, NewOptions()); !report.OK {
+		t.Fatal("failed Test!")
+	}
+	opt := NewOptions()
+	opt.AbsTol = 0
+	if report := Check(f, []gmat.Tensor{x}, []gmat.Tensor{grad}, opt); report.OK || report.Failed != 1 {
+		t.Fatal("failed Test!")
+	}
+}
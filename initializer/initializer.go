@@ -0,0 +1,180 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package initializer provides weight initialisers for dense kernels
+// shaped [in, out] and convolution kernels shaped [out, in, k...].
+package initializer
+
+import (
+	"github.com/kuroko1t/gmat"
+	"log"
+	"math"
+	"math/rand"
+)
+
+func Fans(shape []int) (fanIn, fanOut int) {
+	switch len(shape) {
+	case 0:
+		log.Fatal("Fans.empty shape")
+	case 1:
+		return shape[0], shape[0]
+	case 2:
+		return shape[0], shape[1]
+	}
+	receptive := 1
+	for _, s := range shape[2:] {
+		receptive *= s
+	}
+	return shape[1] * receptive, shape[0] * receptive
+}
+
+func GlorotUniform(shape []int) gmat.Tensor {
+	fanIn, fanOut := Fans(shape)
+	limit := math.Sqrt(6 / float64(fanIn+fanOut))
+	return gmat.Uniform(-limit, limit, shape...)
+}
+
+func GlorotNormal(shape []int) gmat.Tensor {
+	fanIn, fanOut := Fans(shape)
+	return gmat.Normal(0, math.Sqrt(2/float64(fanIn+fanOut)), shape...)
+}
+
+var (
+	XavierUniform = GlorotUniform
+	XavierNormal  = GlorotNormal
+)
+
+func HeUniform(shape []int) gmat.Tensor {
+	fanIn, _ := Fans(shape)
+	limit := math.Sqrt(6 / float64(fanIn))
+	return gmat.Uniform(-limit, limit, shape...)
+}
+
+func HeNormal(shape []int) gmat.Tensor {
+	fanIn, _ := Fans(shape)
+	return gmat.Normal(0, math.Sqrt(2/float64(fanIn)), shape...)
+}
+
+func LeCunUniform(shape []int) gmat.Tensor {
+	fanIn, _ := Fans(shape)
+	limit := math.Sqrt(3 / float64(fanIn))
+	return gmat.Uniform(-limit, limit, shape...)
+}
+
+func LeCunNormal(shape []int) gmat.Tensor {
+	fanIn, _ := Fans(shape)
+	return gmat.Normal(0, math.Sqrt(1/float64(fanIn)), shape...)
+}
+
+// matrixShape flattens a kernel shape into the 2D matrix the orthogonal
+// and identity initialisers work on.
+func matrixShape(shape []int) (rows, cols int) {
+	if len(shape) < 2 {
+		log.Fatal("initializer.need at least 2 dims")
+	}
+	if len(shape) == 2 {
+		return shape[0], shape[1]
+	}
+	cols = 1
+	for _, s := range shape[1:] {
+		cols *= s
+	}
+	return shape[0], cols
+}
+
+func fromMatrix(z [][]float64, shape []int) gmat.Tensor {
+	t := gmat.Make2DInitArray(z)
+	if len(shape) == 2 {
+		return t
+	}
+	return gmat.Reshape(t, shape)
+}
+
+// Orthogonal fills the kernel with a (semi-)orthogonal matrix computed by
+// QR decomposition of a gaussian matrix, scaled by gain.
+func Orthogonal(shape []int, gain float64) gmat.Tensor {
+	rows, cols := matrixShape(shape)
+	n, m := rows, cols
+	if n < m {
+		n, m = m, n
+	}
+	// columns of a are orthonormalised in place (modified Gram-Schmidt)
+	a := make([][]float64, m)
+	for j := range a {
+		a[j] = make([]float64, n)
+		for i := range a[j] {
+			a[j][i] = rand.NormFloat64()
+		}
+	}
+	for j := 0; j < m; j++ {
+		for k := 0; k < j; k++ {
+			dot := 0.0
+			for i := 0; i < n; i++ {
+				dot += a[j][i] * a[k][i]
+			}
+			for i := 0; i < n; i++ {
+				a[j][i] -= dot * a[k][i]
+			}
+		}
+		norm := 0.0
+		for i := 0; i < n; i++ {
+			norm += a[j][i] * a[j][i]
+		}
+		norm = math.Sqrt(norm)
+		for i := 0; i < n; i++ {
+			a[j][i] /= norm
+		}
+	}
+	z := make([][]float64, rows)
+	for i := range z {
+		z[i] = make([]float64, cols)
+		for j := range z[i] {
+			if rows >= cols {
+				z[i][j] = a[j][i] * gain
+			} else {
+				z[i][j] = a[i][j] * gain
+			}
+		}
+	}
+	return fromMatrix(z, shape)
+}
+
+// Identity returns an identity matrix for dense kernels. For convolution
+// kernels it keeps only the centre tap of each in==out channel pair, so the
+// convolution passes its input through unchanged.
+func Identity(shape []int, gain float64) gmat.Tensor {
+	rows, cols := matrixShape(shape)
+	z := make([][]float64, rows)
+	for i := range z {
+		z[i] = make([]float64, cols)
+	}
+	if len(shape) == 2 {
+		for i := 0; i < rows && i < cols; i++ {
+			z[i][i] = gain
+		}
+		return fromMatrix(z, shape)
+	}
+	receptive := cols / shape[1]
+	center := 0
+	stride := receptive
+	for _, k := range shape[2:] {
+		stride /= k
+		center += k / 2 * stride
+	}
+	for i := 0; i < shape[0] && i < shape[1]; i++ {
+		z[i][i*receptive+center] = gain
+	}
+	return fromMatrix(z, shape)
+}
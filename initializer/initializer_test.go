@@ -0,0 +1,97 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package initializer
+
+import (
+	"fmt"
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/cpu"
+	"math"
+	"testing"
+)
+
+func ExpNearCheck(zReal, zExp [][]float64, tol float64, t *testing.T) {
+	success := true
+	for i, zArray := range zExp {
+		for j := range zArray {
+			if math.Abs(zExp[i][j]-zReal[i][j]) > tol {
+				success = false
+			}
+		}
+	}
+	if !success {
+		fmt.Println("Real:", zReal)
+		fmt.Println("Exp:", zExp)
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestFansSuccess(t *testing.T) {
+	cases := []struct {
+		shape         []int
+		fanIn, fanOut int
+	}{
+		{[]int{3, 5}, 3, 5},
+		{[]int{8, 4, 3}, 12, 24},
+		{[]int{16, 3, 5, 5}, 75, 400},
+	}
+	for _, c := range cases {
+		fanIn, fanOut := Fans(c.shape)
+		if fanIn != c.fanIn || fanOut != c.fanOut {
+			fmt.Println("Real:", fanIn, fanOut)
+			fmt.Println("Exp:", c.fanIn, c.fanOut)
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestUniformLimitSuccess(t *testing.T) {
+	shape := []int{16, 3, 5, 5}
+	limit := math.Sqrt(6.0 / 75)
+	for _, v := range cpu.Flatten(cpu.Tensor(HeUniform(shape))) {
+		if math.Abs(v) > limit {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestOrthogonalSuccess(t *testing.T) {
+	eye := [][]float64{
+		{1, 0, 0},
+		{0, 1, 0},
+		{0, 0, 1},
+	}
+	tall := Orthogonal([]int{6, 3}, 1)
+	ExpNearCheck(gmat.Dot(gmat.T(tall), tall).CPU, eye, 1e-9, t)
+	wide := Orthogonal([]int{3, 2, 2, 2}, 1)
+	if len(wide.CPU4D) != 3 {
+		t.Fatal("failed Test!")
+	}
+	w := gmat.Reshape(wide, []int{3, 8})
+	ExpNearCheck(gmat.Dot(w, gmat.T(w)).CPU, eye, 1e-9, t)
+}
+
+func TestIdentitySuccess(t *testing.T) {
+	zExp := [][]float64{
+		{0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0},
+	}
+	z := gmat.Reshape(Identity([]int{2, 2, 3, 3}, 2), []int{2, 18})
+	ExpNearCheck(z.CPU, zExp, 0, t)
+}
@@ -0,0 +1,220 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package loss computes losses of predictions [N, D] together with their
+// gradients with respect to the predictions. All arithmetic goes through
+// gmat, so the same code runs on both builds.
+package loss
+
+import (
+	"github.com/kuroko1t/gmat"
+	"log"
+)
+
+type Reduction int
+
+const (
+	// Mean divides the weighted sum of per-sample losses by N.
+	Mean Reduction = iota
+	Sum
+	// None returns the per-sample losses [N, 1]; the gradient is then the
+	// gradient of every sample's own loss.
+	None
+)
+
+// Options selects the reduction and optional per-sample weights [N, 1].
+type Options struct {
+	Reduction Reduction
+	Weights   gmat.Tensor
+}
+
+// broadcast repeats the column v [N, 1] d times.
+func broadcast(v gmat.Tensor, d int) gmat.Tensor {
+	return gmat.Dot(v, gmat.Ones(1, d))
+}
+
+// reduce weights and reduces the per-sample losses [N, 1] and scales the
+// per-sample gradients accordingly. Mean and Sum return a [1, 1] loss.
+func reduce(losses gmat.Tensor, opt Options, grads ...gmat.Tensor) (gmat.Tensor, []gmat.Tensor) {
+	n := losses.Shape[0]
+	if len(opt.Weights.Shape) != 0 {
+		if opt.Weights.Shape[0] != n || opt.Weights.Shape[1] != 1 {
+			log.Fatal("loss.weights must be [N, 1]")
+		}
+		losses = gmat.Mul(losses, opt.Weights)
+		for i, g := range grads {
+			grads[i] = gmat.Mul(g, broadcast(opt.Weights, g.Shape[1]))
+		}
+	}
+	switch opt.Reduction {
+	case None:
+		return losses, grads
+	case Sum:
+		return gmat.MakeInit(1, 1, gmat.Sum(losses)), grads
+	case Mean:
+		for i, g := range grads {
+			grads[i] = gmat.MulE(g, 1/float64(n))
+		}
+		return gmat.MakeInit(1, 1, gmat.Sum(losses)/float64(n)), grads
+	}
+	log.Fatal("loss.unknown reduction")
+	return gmat.Tensor{}, nil
+}
+
+// elementwise reduces element losses and gradients [N, D] by averaging
+// every row.
+func elementwise(e, de gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+	d := float64(e.Shape[1])
+	loss, grads := reduce(gmat.MulE(gmat.SumCol(e), 1/d), opt, gmat.MulE(de, 1/d))
+	return loss, grads[0]
+}
+
+func checkShape(pred, target gmat.Tensor) {
+	if len(pred.Shape) != 2 || !equalShape(pred.Shape, target.Shape) {
+		log.Fatal("loss.prediction and target must be [N, D]")
+	}
+}
+
+func equalShape(x, y []int) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(x gmat.Tensor) gmat.Tensor {
+	return gmat.Add(gmat.ReLU(x), gmat.ReLU(gmat.MulE(x, -1)))
+}
+
+// sign is 0 at 0.
+func sign(x gmat.Tensor) gmat.Tensor {
+	return gmat.Sub(gmat.ReLUDerivative(x), gmat.ReLUDerivative(gmat.MulE(x, -1)))
+}
+
+func MSE(pred, target gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+	checkShape(pred, target)
+	d := gmat.Sub(pred, target)
+	return elementwise(gmat.Mul(d, d), gmat.MulE(d, 2), opt)
+}
+
+func MAE(pred, target gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+	checkShape(pred, target)
+	d := gmat.Sub(pred, target)
+	return elementwise(abs(d), sign(d), opt)
+}
+
+// Huber is quadratic for |pred-target| <= delta and linear beyond.
+func Huber(pred, target gmat.Tensor, delta float64, opt Options) (gmat.Tensor, gmat.Tensor) {
+	checkShape(pred, target)
+	d := gmat.Sub(pred, target)
+	c := gmat.Clip(d, -delta, delta)
+	// 0.5 c^2 + delta (|d| - |c|)
+	e := gmat.Add(gmat.MulE(gmat.Mul(c, c), 0.5), gmat.MulE(gmat.Sub(abs(d), abs(c)), delta))
+	return elementwise(e, c, opt)
+}
+
+// SmoothL1 is Huber divided by beta, and MAE for beta 0.
+func SmoothL1(pred, target gmat.Tensor, beta float64, opt Options) (gmat.Tensor, gmat.Tensor) {
+	if beta < 0 {
+		log.Fatal("loss.SmoothL1 beta must not be negative")
+	}
+	if beta == 0 {
+		return MAE(pred, target, opt)
+	}
+	loss, grad := Huber(pred, target, beta, opt)
+	return gmat.MulE(loss, 1/beta), gmat.MulE(grad, 1/beta)
+}
+
+// BCEWithLogits is the binary cross entropy of sigmoid(logits) computed
+// as softplus(x) - t x, which does not overflow for large logits.
+func BCEWithLogits(logits, target gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+	checkShape(logits, target)
+	e := gmat.Sub(gmat.Softplus(logits), gmat.Mul(target, logits))
+	return elementwise(e, gmat.Sub(gmat.Sigmoid(logits), target), opt)
+}
+
+// KLDivergence is KL(target || pred) for log probabilities logPred and
+// probabilities target. Unlike the elementwise losses every row is summed,
+// so Mean is the KL divergence per sample.
+func KLDivergence(logPred, target gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+	checkShape(logPred, target)
+	// 0 log 0 = 0
+	logTarget := gmat.Log(gmat.Clip(target, 1e-30, 1), 0)
+	e := gmat.Mul(target, gmat.Sub(logTarget, logPred))
+	loss, grads := reduce(gmat.SumCol(e), opt, gmat.MulE(target, -1))
+	return loss, grads[0]
+}
+
+// Hinge is max(0, margin - t x) for targets t in {-1, 1}.
+func Hinge(pred, target gmat.Tensor, margin float64, opt Options) (gmat.Tensor, gmat.Tensor) {
+	checkShape(pred, target)
+	z := gmat.AxpyE(gmat.Mul(target, pred), -1, margin)
+	return elementwise(gmat.ReLU(z), gmat.Mul(gmat.MulE(target, -1), gmat.ReLUDerivative(z)), opt)
+}
+
+// eps keeps norms and distances differentiable at zero.
+const eps = 1e-12
+
+// invNorm returns 1/sqrt(sum x^2 + eps) of every row as [N, 1].
+func invNorm(x gmat.Tensor) gmat.Tensor {
+	return gmat.SqrtT(gmat.SumCol(gmat.Mul(x, x)), eps, 0)
+}
+
+// CosineEmbedding pulls x1 and x2 together for y = 1 with 1 - cos and
+// pushes them apart for y = -1 with max(0, cos - margin). y is [N, 1]. It
+// returns the gradients of x1 and x2.
+func CosineEmbedding(x1, x2, y gmat.Tensor, margin float64, opt Options) (gmat.Tensor, gmat.Tensor, gmat.Tensor) {
+	checkShape(x1, x2)
+	d := x1.Shape[1]
+	inv1, inv2 := invNorm(x1), invNorm(x2)
+	inv12 := gmat.Mul(inv1, inv2)
+	cos := gmat.Mul(gmat.SumCol(gmat.Mul(x1, x2)), inv12)
+	pos := gmat.AxpyE(y, 0.5, 0.5)
+	neg := gmat.AxpyE(y, -0.5, 0.5)
+	far := gmat.AxpyE(cos, 1, -margin)
+	losses := gmat.Add(gmat.Mul(pos, gmat.AxpyE(cos, -1, 1)), gmat.Mul(neg, gmat.ReLU(far)))
+	dcos := gmat.Sub(gmat.Mul(neg, gmat.ReLUDerivative(far)), pos)
+	// dcos/dx1 = x2 / (|x1| |x2|) - cos x1 / |x1|^2
+	cosGrad := func(x, other, inv gmat.Tensor) gmat.Tensor {
+		g := gmat.Sub(gmat.Mul(other, broadcast(inv12, d)), gmat.Mul(x, broadcast(gmat.Mul(cos, gmat.Mul(inv, inv)), d)))
+		return gmat.Mul(g, broadcast(dcos, d))
+	}
+	loss, grads := reduce(losses, opt, cosGrad(x1, x2, inv1), cosGrad(x2, x1, inv2))
+	return loss, grads[0], grads[1]
+}
+
+// Triplet is max(0, |a-p| - |a-n| + margin) with Euclidean distances. It
+// returns the gradients of anchor, positive and negative.
+func Triplet(anchor, positive, negative gmat.Tensor, margin float64, opt Options) (gmat.Tensor, gmat.Tensor, gmat.Tensor, gmat.Tensor) {
+	checkShape(anchor, positive)
+	checkShape(anchor, negative)
+	d := anchor.Shape[1]
+	dp, dn := gmat.Sub(anchor, positive), gmat.Sub(anchor, negative)
+	invP, invN := invNorm(dp), invNorm(dn)
+	distP := gmat.Div(gmat.Ones(dp.Shape[0], 1), invP)
+	distN := gmat.Div(gmat.Ones(dn.Shape[0], 1), invN)
+	z := gmat.AxpyE(gmat.Sub(distP, distN), 1, margin)
+	active := gmat.ReLUDerivative(z)
+	// unit vectors from p and n towards a, zero for satisfied triplets
+	up := gmat.Mul(dp, broadcast(gmat.Mul(invP, active), d))
+	un := gmat.Mul(dn, broadcast(gmat.Mul(invN, active), d))
+	loss, grads := reduce(gmat.ReLU(z), opt, gmat.Sub(up, un), gmat.MulE(up, -1), un)
+	return loss, grads[0], grads[1], grads[2]
+}
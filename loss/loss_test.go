@@ -0,0 +1,152 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package loss
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/gradcheck"
+	"math"
+	"testing"
+)
+
+func near(x, y []float64, tol float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if math.Abs(x[i]-y[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// lossFunc returns the loss of inputs and the gradients of all of them.
+type lossFunc func(in []gmat.Tensor, opt Options) (gmat.Tensor, []gmat.Tensor)
+
+func checkLoss(f lossFunc, inputs []gmat.Tensor, t *testing.T) {
+	weights := gmat.Uniform(0.5, 2, inputs[0].Shape[0], 1)
+	for _, opt := range []Options{{Reduction: Mean}, {Reduction: Sum}, {Reduction: Mean, Weights: weights}} {
+		loss, grads := f(inputs, opt)
+		if loss.Shape[0] != 1 || loss.Shape[1] != 1 {
+			t.Fatal("failed Test!")
+		}
+		scalar := func(in []gmat.Tensor) float64 {
+			loss, _ := f(in, opt)
+			return gmat.Sum(loss)
+		}
+		report := gradcheck.Check(scalar, inputs, grads, gradcheck.DefaultOptions)
+		if !report.OK {
+			t.Fatal("failed Test!", report)
+		}
+	}
+	// None keeps one loss per sample and their sum is the Sum reduction
+	none, noneGrads := f(inputs, Options{Reduction: None})
+	sum, sumGrads := f(inputs, Options{Reduction: Sum})
+	if none.Shape[0] != inputs[0].Shape[0] || !near([]float64{gmat.Sum(none)}, gmat.ToSlice(sum), 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	for i := range sumGrads {
+		if !near(gmat.ToSlice(noneGrads[i]), gmat.ToSlice(sumGrads[i]), 1e-12) {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func pair(f func(pred, target gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor), target gmat.Tensor) lossFunc {
+	return func(in []gmat.Tensor, opt Options) (gmat.Tensor, []gmat.Tensor) {
+		loss, grad := f(in[0], target, opt)
+		return loss, []gmat.Tensor{grad}
+	}
+}
+
+func TestElementwiseSuccess(t *testing.T) {
+	pred := gmat.Normal(0, 2, 4, 3)
+	target := gmat.Normal(0, 2, 4, 3)
+	labels := gmat.Bernoulli(0.5, 4, 3)
+	signs := gmat.AxpyE(labels, 2, -1)
+	probs := gmat.Softmax(gmat.Normal(0, 1, 4, 3), 1)
+	funcs := []lossFunc{
+		pair(MSE, target),
+		pair(MAE, target),
+		pair(func(p, t gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+			return Huber(p, t, 1.5, opt)
+		}, target),
+		pair(func(p, t gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+			return SmoothL1(p, t, 0.5, opt)
+		}, target),
+		pair(BCEWithLogits, labels),
+		pair(KLDivergence, probs),
+		pair(func(p, t gmat.Tensor, opt Options) (gmat.Tensor, gmat.Tensor) {
+			return Hinge(p, t, 1, opt)
+		}, signs),
+	}
+	for _, f := range funcs {
+		checkLoss(f, []gmat.Tensor{pred}, t)
+	}
+}
+
+func TestValuesSuccess(t *testing.T) {
+	pred := gmat.FromSlice([]float64{1, 4}, 1, 2)
+	target := gmat.FromSlice([]float64{0, 0}, 1, 2)
+	loss, grad := MSE(pred, target, Options{})
+	if !near(gmat.ToSlice(loss), []float64{8.5}, 1e-12) || !near(gmat.ToSlice(grad), []float64{1, 4}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	loss, grad = Huber(pred, target, 2, Options{})
+	// 0.5 * 1 and 2 * (4 - 1)
+	if !near(gmat.ToSlice(loss), []float64{3.25}, 1e-12) || !near(gmat.ToSlice(grad), []float64{0.5, 1}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	loss, grad = SmoothL1(pred, target, 0, Options{})
+	if !near(gmat.ToSlice(loss), []float64{2.5}, 1e-12) || !near(gmat.ToSlice(grad), []float64{0.5, 0.5}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	logits := gmat.FromSlice([]float64{1000, -1000}, 1, 2)
+	loss, grad = BCEWithLogits(logits, gmat.FromSlice([]float64{1, 0}, 1, 2), Options{Reduction: Sum})
+	if !near(gmat.ToSlice(loss), []float64{0}, 1e-12) || !near(gmat.ToSlice(grad), []float64{0, 0}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	probs := gmat.FromSlice([]float64{0, 0.25, 0.75}, 1, 3)
+	loss, _ = KLDivergence(gmat.Log(probs, 0), probs, Options{})
+	if !near(gmat.ToSlice(loss), []float64{0}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestEmbeddingLossSuccess(t *testing.T) {
+	x1 := gmat.Normal(0, 1, 4, 3)
+	x2 := gmat.Normal(0, 1, 4, 3)
+	x3 := gmat.Normal(0, 1, 4, 3)
+	y := gmat.FromSlice([]float64{1, -1, 1, -1}, 4, 1)
+	cosine := func(in []gmat.Tensor, opt Options) (gmat.Tensor, []gmat.Tensor) {
+		loss, g1, g2 := CosineEmbedding(in[0], in[1], y, -0.5, opt)
+		return loss, []gmat.Tensor{g1, g2}
+	}
+	checkLoss(cosine, []gmat.Tensor{x1, x2}, t)
+	triplet := func(in []gmat.Tensor, opt Options) (gmat.Tensor, []gmat.Tensor) {
+		loss, ga, gp, gn := Triplet(in[0], in[1], in[2], 1, opt)
+		return loss, []gmat.Tensor{ga, gp, gn}
+	}
+	checkLoss(triplet, []gmat.Tensor{x1, x2, x3}, t)
+	loss, _, _ := CosineEmbedding(x1, x1, gmat.Ones(4, 1), 0, Options{})
+	if !near(gmat.ToSlice(loss), []float64{0}, 1e-9) {
+		t.Fatal("failed Test!")
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package metrics evaluates classifiers. Predictions are probability (or
+// logit) tensors [N, C] turned into classes with ArgMaxCol, or [N, 1]
+// scores of the positive class thresholded at 0.5. Targets are integer
+// label tensors [N, 1] or one-hot tensors [N, C].
+package metrics
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/cpu"
+	"log"
+	"math"
+	"sort"
+)
+
+func rows(x gmat.Tensor) [][]float64 {
+	if len(x.Shape) != 2 {
+		log.Fatal("metrics.rows: tensor must be 2D")
+	}
+	data := gmat.ToSlice(x)
+	n, m := x.Shape[0], x.Shape[1]
+	z := make([][]float64, n)
+	for i := range z {
+		z[i] = data[i*m : (i+1)*m]
+	}
+	return z
+}
+
+// Labels returns the class of every row of x.
+func Labels(x gmat.Tensor) []int {
+	r := rows(x)
+	labels := make([]int, len(r))
+	if x.Shape[1] == 1 {
+		for i := range r {
+			labels[i] = int(math.Round(r[i][0]))
+		}
+		return labels
+	}
+	for i, max := range cpu.ArgMaxCol(r) {
+		labels[i] = max[0]
+	}
+	return labels
+}
+
+// Predictions returns the predicted class of every row of pred.
+func Predictions(pred gmat.Tensor) []int {
+	r := rows(pred)
+	if pred.Shape[1] != 1 {
+		return Labels(pred)
+	}
+	classes := make([]int, len(r))
+	for i := range r {
+		if r[i][0] >= 0.5 {
+			classes[i] = 1
+		}
+	}
+	return classes
+}
+
+func checkLen(pred, target []int) {
+	if len(pred) != len(target) {
+		log.Fatal("metrics.checkLen: prediction and target sizes mismatch")
+	}
+}
+
+// Accuracy is the fraction of rows whose predicted class is the target.
+// It has the signature of trainer.Metric.Func.
+func Accuracy(pred, target gmat.Tensor) float64 {
+	p, y := Predictions(pred), Labels(target)
+	checkLen(p, y)
+	correct := 0
+	for i := range p {
+		if p[i] == y[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(p))
+}
+
+// TopKAccuracy is the fraction of rows whose target is among the k highest
+// scores of pred [N, C].
+func TopKAccuracy(pred, target gmat.Tensor, k int) float64 {
+	r, y := rows(pred), Labels(target)
+	if len(r) != len(y) {
+		log.Fatal("metrics.TopKAccuracy: prediction and target sizes mismatch")
+	}
+	correct := 0
+	for i := range r {
+		// count the classes ranked strictly above the target
+		above := 0
+		for _, v := range r[i] {
+			if v > r[i][y[i]] {
+				above++
+			}
+		}
+		if above < k {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(r))
+}
+
+// ConfusionMatrix counts targets along the rows and predictions along the
+// columns.
+func ConfusionMatrix(pred, target gmat.Tensor, classes int) [][]int {
+	p, y := Predictions(pred), Labels(target)
+	checkLen(p, y)
+	cm := make([][]int, classes)
+	for i := range cm {
+		cm[i] = make([]int, classes)
+	}
+	for i := range p {
+		if p[i] < 0 || p[i] >= classes || y[i] < 0 || y[i] >= classes {
+			log.Fatal("metrics.ConfusionMatrix: class out of range")
+		}
+		cm[y[i]][p[i]]++
+	}
+	return cm
+}
+
+// Report holds per-class scores and the number of targets of every class.
+type Report struct {
+	Precision []float64
+	Recall    []float64
+	F1        []float64
+	Support   []int
+}
+
+// NewReport computes precision, recall and F1 of every class of a
+// confusion matrix. Undefined ratios are 0.
+func NewReport(cm [][]int) Report {
+	n := len(cm)
+	r := Report{make([]float64, n), make([]float64, n), make([]float64, n), make([]int, n)}
+	for c := 0; c < n; c++ {
+		predicted := 0
+		for i := 0; i < n; i++ {
+			predicted += cm[i][c]
+			r.Support[c] += cm[c][i]
+		}
+		tp := float64(cm[c][c])
+		if predicted > 0 {
+			r.Precision[c] = tp / float64(predicted)
+		}
+		if r.Support[c] > 0 {
+			r.Recall[c] = tp / float64(r.Support[c])
+		}
+		if r.Precision[c]+r.Recall[c] > 0 {
+			r.F1[c] = 2 * r.Precision[c] * r.Recall[c] / (r.Precision[c] + r.Recall[c])
+		}
+	}
+	return r
+}
+
+// Classification is NewReport of the confusion matrix of pred and target.
+func Classification(pred, target gmat.Tensor, classes int) Report {
+	return NewReport(ConfusionMatrix(pred, target, classes))
+}
+
+// MacroF1 is the unweighted mean of the per-class F1 scores.
+func (r Report) MacroF1() float64 {
+	sum := 0.0
+	for _, f := range r.F1 {
+		sum += f
+	}
+	return sum / float64(len(r.F1))
+}
+
+// scores returns the positive-class scores [N, 1] and binary targets
+// sorted by decreasing score.
+func scores(score, target gmat.Tensor) ([]float64, []bool) {
+	if len(score.Shape) != 2 || score.Shape[1] != 1 {
+		log.Fatal("metrics.scores: score must be [N, 1]")
+	}
+	s, y := gmat.ToSlice(score), Labels(target)
+	if len(s) != len(y) {
+		log.Fatal("metrics.scores: score and target sizes mismatch")
+	}
+	order := make([]int, len(s))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return s[order[i]] > s[order[j]] })
+	sorted := make([]float64, len(s))
+	positive := make([]bool, len(s))
+	for i, o := range order {
+		sorted[i] = s[o]
+		positive[i] = y[o] == 1
+	}
+	return sorted, positive
+}
+
+// curve walks the thresholds from the highest score down and calls point
+// with the true and false positive counts at every distinct score.
+func curve(s []float64, positive []bool, point func(tp, fp int, threshold float64)) (int, int) {
+	tp, fp := 0, 0
+	for i := range s {
+		if positive[i] {
+			tp++
+		} else {
+			fp++
+		}
+		if i == len(s)-1 || s[i+1] != s[i] {
+			point(tp, fp, s[i])
+		}
+	}
+	return tp, fp
+}
+
+// ROC returns the false and true positive rates of the binary scores
+// [N, 1] for every distinct threshold, starting at (0, 0).
+func ROC(score, target gmat.Tensor) (fpr, tpr, thresholds []float64) {
+	s, positive := scores(score, target)
+	var tps, fps []int
+	p, n := curve(s, positive, func(tp, fp int, threshold float64) {
+		tps, fps = append(tps, tp), append(fps, fp)
+		thresholds = append(thresholds, threshold)
+	})
+	if p == 0 || n == 0 {
+		log.Fatal("metrics.ROC: target needs both classes")
+	}
+	fpr, tpr = []float64{0}, []float64{0}
+	thresholds = append([]float64{math.Inf(1)}, thresholds...)
+	for i := range tps {
+		fpr = append(fpr, float64(fps[i])/float64(n))
+		tpr = append(tpr, float64(tps[i])/float64(p))
+	}
+	return fpr, tpr, thresholds
+}
+
+// AUC is the area under the curve y(x) by the trapezoidal rule.
+func AUC(x, y []float64) float64 {
+	area := 0.0
+	for i := 1; i < len(x); i++ {
+		area += (x[i] - x[i-1]) * (y[i] + y[i-1]) / 2
+	}
+	return area
+}
+
+// ROCAUC is the area under the ROC curve.
+func ROCAUC(score, target gmat.Tensor) float64 {
+	fpr, tpr, _ := ROC(score, target)
+	return AUC(fpr, tpr)
+}
+
+// PrecisionRecall returns the precision and recall of the binary scores
+// [N, 1] for every distinct threshold, from the highest down.
+func PrecisionRecall(score, target gmat.Tensor) (precision, recall, thresholds []float64) {
+	s, positive := scores(score, target)
+	var tps, fps []int
+	p, _ := curve(s, positive, func(tp, fp int, threshold float64) {
+		tps, fps = append(tps, tp), append(fps, fp)
+		thresholds = append(thresholds, threshold)
+	})
+	if p == 0 {
+		log.Fatal("metrics.PrecisionRecall: target has no positive")
+	}
+	for i := range tps {
+		precision = append(precision, float64(tps[i])/float64(tps[i]+fps[i]))
+		recall = append(recall, float64(tps[i])/float64(p))
+	}
+	return precision, recall, thresholds
+}
+
+// PRAUC is the area under the precision-recall curve computed as average
+// precision, the sum of the precisions weighted by the recall increments.
+func PRAUC(score, target gmat.Tensor) float64 {
+	precision, recall, _ := PrecisionRecall(score, target)
+	area, last := 0.0, 0.0
+	for i := range precision {
+		area += (recall[i] - last) * precision[i]
+		last = recall[i]
+	}
+	return area
+}
+
+// LogLoss is the mean negative log-likelihood of the targets. pred holds
+// probabilities [N, C] or positive-class probabilities [N, 1], clipped to
+// [eps, 1-eps].
+func LogLoss(pred, target gmat.Tensor, eps float64) float64 {
+	r, y := rows(pred), Labels(target)
+	if len(r) != len(y) {
+		log.Fatal("metrics.LogLoss: prediction and target sizes mismatch")
+	}
+	clip := func(p float64) float64 { return math.Min(math.Max(p, eps), 1-eps) }
+	sum := 0.0
+	for i := range r {
+		if len(r[i]) == 1 {
+			p := clip(r[i][0])
+			if y[i] == 1 {
+				sum -= math.Log(p)
+			} else {
+				sum -= math.Log(1 - p)
+			}
+			continue
+		}
+		sum -= math.Log(clip(r[i][y[i]]))
+	}
+	return sum / float64(len(r))
+}
@@ -0,0 +1,123 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package metrics
+
+import (
+	"github.com/kuroko1t/gmat"
+	"math"
+	"testing"
+)
+
+func near(x, y []float64, tol float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if math.Abs(x[i]-y[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// logits of 3 classes, all negative so that ArgMaxCol must not start at 0
+var logits = gmat.FromSlice([]float64{
+	-1, -2, -3,
+	-5, -4, -6,
+	-3, -2, -1,
+	-1, -3, -2,
+	-2, -1, -3,
+}, 5, 3)
+
+var labels = gmat.FromSlice([]float64{0, 1, 2, 2, 0}, 5, 1)
+
+func TestAccuracySuccess(t *testing.T) {
+	if Accuracy(logits, labels) != 0.6 {
+		t.Fatal("failed Test!")
+	}
+	oneHot := gmat.FromSlice([]float64{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 1, 1, 0, 0}, 5, 3)
+	if Accuracy(logits, oneHot) != 0.6 {
+		t.Fatal("failed Test!")
+	}
+	if TopKAccuracy(logits, labels, 1) != 0.6 || TopKAccuracy(logits, labels, 2) != 1 {
+		t.Fatal("failed Test!")
+	}
+	binary := gmat.FromSlice([]float64{0.9, 0.2, 0.6, 0.4}, 4, 1)
+	if Accuracy(binary, gmat.FromSlice([]float64{1, 0, 0, 0}, 4, 1)) != 0.75 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestConfusionMatrixSuccess(t *testing.T) {
+	cm := ConfusionMatrix(logits, labels, 3)
+	exp := [][]int{{1, 1, 0}, {0, 1, 0}, {1, 0, 1}}
+	for i := range exp {
+		for j := range exp[i] {
+			if cm[i][j] != exp[i][j] {
+				t.Fatal("failed Test!", cm)
+			}
+		}
+	}
+	r := NewReport(cm)
+	if !near(r.Precision, []float64{0.5, 0.5, 1}, 1e-12) || !near(r.Recall, []float64{0.5, 1, 0.5}, 1e-12) {
+		t.Fatal("failed Test!", r)
+	}
+	if !near(r.F1, []float64{0.5, 2.0 / 3, 2.0 / 3}, 1e-12) || r.Support[2] != 2 {
+		t.Fatal("failed Test!", r)
+	}
+	if math.Abs(r.MacroF1()-11.0/18) > 1e-12 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestROCSuccess(t *testing.T) {
+	score := gmat.FromSlice([]float64{0.1, 0.4, 0.35, 0.8}, 4, 1)
+	target := gmat.FromSlice([]float64{0, 0, 1, 1}, 4, 1)
+	fpr, tpr, thresholds := ROC(score, target)
+	if !near(fpr, []float64{0, 0, 0.5, 0.5, 1}, 1e-12) || !near(tpr, []float64{0, 0.5, 0.5, 1, 1}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	if !math.IsInf(thresholds[0], 1) || thresholds[4] != 0.1 {
+		t.Fatal("failed Test!")
+	}
+	if math.Abs(ROCAUC(score, target)-0.75) > 1e-12 {
+		t.Fatal("failed Test!")
+	}
+	if math.Abs(PRAUC(score, target)-5.0/6) > 1e-12 {
+		t.Fatal("failed Test!")
+	}
+	// tied scores form a single point on the diagonal
+	tied := gmat.FromSlice([]float64{0.5, 0.5, 0.5, 0.5}, 4, 1)
+	if fpr, _, _ := ROC(tied, target); len(fpr) != 2 || ROCAUC(tied, target) != 0.5 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestLogLossSuccess(t *testing.T) {
+	binary := gmat.FromSlice([]float64{0.9, 0.2}, 2, 1)
+	exp := -(math.Log(0.9) + math.Log(0.8)) / 2
+	if math.Abs(LogLoss(binary, gmat.FromSlice([]float64{1, 0}, 2, 1), 1e-15)-exp) > 1e-12 {
+		t.Fatal("failed Test!")
+	}
+	probs := gmat.FromSlice([]float64{0.7, 0.2, 0.1, 0, 0.5, 0.5}, 2, 3)
+	exp = -(math.Log(0.2) + math.Log(1e-7)) / 2
+	if math.Abs(LogLoss(probs, gmat.FromSlice([]float64{1, 0}, 2, 1), 1e-7)-exp) > 1e-9 {
+		t.Fatal("failed Test!")
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"log"
+	"math"
+)
+
+// maskedLogit is added to the scores of masked pairs.
+const maskedLogit = -1e9
+
+// CausalMask lets every query attend to the keys up to its own position.
+// Masks are [B, Lq, Lk] with 1 where a query may attend to a key and 0
+// elsewhere.
+func CausalMask(batch, lq, lk int) gmat.Tensor {
+	// queries are aligned with the last keys, so lq < lk sees a prefix
+	data := make([]float64, batch*lq*lk)
+	for b := 0; b < batch; b++ {
+		for i := 0; i < lq; i++ {
+			for j := 0; j <= i+lk-lq && j < lk; j++ {
+				data[(b*lq+i)*lk+j] = 1
+			}
+		}
+	}
+	return gmat.FromSlice(data, batch, lq, lk)
+}
+
+// PaddingMask hides the keys at and after lengths[b] of every sequence b.
+func PaddingMask(lengths []int, lq, lk int) gmat.Tensor {
+	data := make([]float64, len(lengths)*lq*lk)
+	for b, length := range lengths {
+		for i := 0; i < lq; i++ {
+			for j := 0; j < length && j < lk; j++ {
+				data[(b*lq+i)*lk+j] = 1
+			}
+		}
+	}
+	return gmat.FromSlice(data, len(lengths), lq, lk)
+}
+
+// ScaledDotProductAttention returns softmax(q k^T / sqrt(D)) v for
+// q [B, Lq, D], k [B, Lk, D] and v [B, Lk, Dv] with the attention weights
+// [B, Lq, Lk]. An empty mask attends everywhere, and a query with no key to
+// attend gets meaningless weights.
+func ScaledDotProductAttention(q, k, v, mask gmat.Tensor) (gmat.Tensor, gmat.Tensor) {
+	if q.Shape[2] != k.Shape[2] || k.Shape[1] != v.Shape[1] {
+		log.Fatal("ScaledDotProductAttention.mismatch input shape")
+	}
+	scores := gmat.MulE(gmat.BatchDot(q, k, false, true), 1/math.Sqrt(float64(q.Shape[2])))
+	if len(mask.Shape) != 0 {
+		scores = gmat.Add(scores, gmat.AxpyE(mask, -maskedLogit, maskedLogit))
+	}
+	weights := gmat.Softmax(scores, -1)
+	return gmat.BatchDot(weights, v, false, false), weights
+}
+
+// ScaledDotProductAttentionBackward returns the gradients of q, k and v
+// given the weights of the forward pass; masked pairs have zero weight and
+// need no mask here.
+func ScaledDotProductAttentionBackward(q, k, v, weights, grad gmat.Tensor) (gmat.Tensor, gmat.Tensor, gmat.Tensor) {
+	dv := gmat.BatchDot(weights, grad, true, false)
+	dweights := gmat.BatchDot(grad, v, false, true)
+	dscores := gmat.MulE(gmat.SoftmaxBackward(weights, dweights, -1), 1/math.Sqrt(float64(q.Shape[2])))
+	dq := gmat.BatchDot(dscores, k, false, false)
+	dk := gmat.BatchDot(dscores, q, true, false)
+	return dq, dk, dv
+}
+
+// chunks splits n into pieces of at most size.
+func chunks(n, size int) []int {
+	if size <= 0 || size > n {
+		size = n
+	}
+	sizes := []int{}
+	for n > 0 {
+		if size > n {
+			size = n
+		}
+		sizes = append(sizes, size)
+		n -= size
+	}
+	return sizes
+}
+
+func splitQueries(q, mask gmat.Tensor, chunk int) ([]gmat.Tensor, []gmat.Tensor) {
+	sizes := chunks(q.Shape[1], chunk)
+	qs := gmat.Split(q, sizes, 1)
+	masks := make([]gmat.Tensor, len(qs))
+	if len(mask.Shape) != 0 {
+		masks = gmat.Split(mask, sizes, 1)
+	}
+	return qs, masks
+}
+
+// ChunkedAttention is ScaledDotProductAttention over chunk queries at a
+// time, so only [B, chunk, Lk] weights exist at once.
+func ChunkedAttention(q, k, v, mask gmat.Tensor, chunk int) gmat.Tensor {
+	qs, masks := splitQueries(q, mask, chunk)
+	outs := make([]gmat.Tensor, len(qs))
+	for i := range qs {
+		outs[i], _ = ScaledDotProductAttention(qs[i], k, v, masks[i])
+	}
+	return gmat.Concat(outs, 1)
+}
+
+// ChunkedAttentionBackward recomputes the weights chunk by chunk instead
+// of keeping them from the forward pass.
+func ChunkedAttentionBackward(q, k, v, mask, grad gmat.Tensor, chunk int) (gmat.Tensor, gmat.Tensor, gmat.Tensor) {
+	qs, masks := splitQueries(q, mask, chunk)
+	grads := gmat.Split(grad, chunks(q.Shape[1], chunk), 1)
+	dqs := make([]gmat.Tensor, len(qs))
+	var dk, dv gmat.Tensor
+	for i := range qs {
+		_, weights := ScaledDotProductAttention(qs[i], k, v, masks[i])
+		dq, dki, dvi := ScaledDotProductAttentionBackward(qs[i], k, v, weights, grads[i])
+		dqs[i] = dq
+		if i == 0 {
+			dk, dv = dki, dvi
+		} else {
+			dk, dv = gmat.Add(dk, dki), gmat.Add(dv, dvi)
+		}
+	}
+	return gmat.Concat(dqs, 1), dk, dv
+}
+
+// MultiHeadAttention projects queries, keys and values [B, L, E] with Dense
+// layers, attends in Heads heads of E/Heads features and projects the
+// concatenated heads with Wo.
+type MultiHeadAttention struct {
+	Heads int
+	Wq    *Dense
+	Wk    *Dense
+	Wv    *Dense
+	Wo    *Dense
+	// Causal hides later keys; Mask [B, Lq, Lk] hides padding. Both may
+	// be combined.
+	Causal bool
+	Mask   gmat.Tensor
+	// Chunk > 0 uses ChunkedAttention with chunks of that many queries.
+	Chunk int
+	// Weights [B*Heads, Lq, Lk] of the last Forward, unless chunked.
+	Weights gmat.Tensor
+	q       gmat.Tensor
+	k       gmat.Tensor
+	v       gmat.Tensor
+	mask    gmat.Tensor
+	batch   int
+	lq      int
+	lk      int
+}
+
+func NewMultiHeadAttention(embed, heads int) *MultiHeadAttention {
+	if embed%heads != 0 {
+		log.Fatal("NewMultiHeadAttention.embed not divisible by heads")
+	}
+	return &MultiHeadAttention{
+		Heads: heads,
+		Wq:    NewDense(embed, embed, true),
+		Wk:    NewDense(embed, embed, true),
+		Wv:    NewDense(embed, embed, true),
+		Wo:    NewDense(embed, embed, true),
+	}
+}
+
+// splitHeads turns [B*L, E] into [B*Heads, L, E/Heads].
+func (a *MultiHeadAttention) splitHeads(x gmat.Tensor, l int) gmat.Tensor {
+	e := x.Shape[1]
+	x = gmat.Reshape(x, []int{a.batch, l, a.Heads, e / a.Heads})
+	x = gmat.Permute(x, []int{0, 2, 1, 3})
+	return gmat.Reshape(x, []int{a.batch * a.Heads, l, e / a.Heads})
+}
+
+// mergeHeads is the inverse of splitHeads.
+func (a *MultiHeadAttention) mergeHeads(x gmat.Tensor, l int) gmat.Tensor {
+	d := x.Shape[2]
+	x = gmat.Reshape(x, []int{a.batch, a.Heads, l, d})
+	x = gmat.Permute(x, []int{0, 2, 1, 3})
+	return gmat.Reshape(x, []int{a.batch * l, a.Heads * d})
+}
+
+// headMask combines the masks and repeats them for every head.
+func (a *MultiHeadAttention) headMask() gmat.Tensor {
+	mask := a.Mask
+	if a.Causal {
+		causal := CausalMask(a.batch, a.lq, a.lk)
+		if len(mask.Shape) == 0 {
+			mask = causal
+		} else {
+			mask = gmat.Mul(mask, causal)
+		}
+	}
+	if len(mask.Shape) == 0 {
+		return mask
+	}
+	masks := []gmat.Tensor{}
+	for _, m := range gmat.Unstack(mask) {
+		for h := 0; h < a.Heads; h++ {
+			masks = append(masks, m)
+		}
+	}
+	return gmat.Stack(masks)
+}
+
+// ForwardQKV attends from query [B, Lq, E] to key and value [B, Lk, E].
+func (a *MultiHeadAttention) ForwardQKV(query, key, value gmat.Tensor) gmat.Tensor {
+	a.batch, a.lq, a.lk = query.Shape[0], query.Shape[1], key.Shape[1]
+	e := query.Shape[2]
+	a.q = a.splitHeads(a.Wq.Forward(gmat.Reshape(query, []int{a.batch * a.lq, e})), a.lq)
+	a.k = a.splitHeads(a.Wk.Forward(gmat.Reshape(key, []int{a.batch * a.lk, e})), a.lk)
+	a.v = a.splitHeads(a.Wv.Forward(gmat.Reshape(value, []int{a.batch * a.lk, e})), a.lk)
+	a.mask = a.headMask()
+	var out gmat.Tensor
+	if a.Chunk > 0 {
+		out = ChunkedAttention(a.q, a.k, a.v, a.mask, a.Chunk)
+		a.Weights = gmat.Tensor{}
+	} else {
+		out, a.Weights = ScaledDotProductAttention(a.q, a.k, a.v, a.mask)
+	}
+	out = a.Wo.Forward(a.mergeHeads(out, a.lq))
+	return gmat.Reshape(out, []int{a.batch, a.lq, e})
+}
+
+// BackwardQKV returns the gradients of query, key and value.
+func (a *MultiHeadAttention) BackwardQKV(grad gmat.Tensor) (gmat.Tensor, gmat.Tensor, gmat.Tensor) {
+	e := grad.Shape[2]
+	dout := a.splitHeads(a.Wo.Backward(gmat.Reshape(grad, []int{a.batch * a.lq, e})), a.lq)
+	var dq, dk, dv gmat.Tensor
+	if a.Chunk > 0 {
+		dq, dk, dv = ChunkedAttentionBackward(a.q, a.k, a.v, a.mask, dout, a.Chunk)
+	} else {
+		dq, dk, dv = ScaledDotProductAttentionBackward(a.q, a.k, a.v, a.Weights, dout)
+	}
+	dq = a.Wq.Backward(a.mergeHeads(dq, a.lq))
+	dk = a.Wk.Backward(a.mergeHeads(dk, a.lk))
+	dv = a.Wv.Backward(a.mergeHeads(dv, a.lk))
+	return gmat.Reshape(dq, []int{a.batch, a.lq, e}), gmat.Reshape(dk, []int{a.batch, a.lk, e}), gmat.Reshape(dv, []int{a.batch, a.lk, e})
+}
+
+// Forward is self attention of x [B, L, E].
+func (a *MultiHeadAttention) Forward(x gmat.Tensor) gmat.Tensor {
+	return a.ForwardQKV(x, x, x)
+}
+
+func (a *MultiHeadAttention) Backward(grad gmat.Tensor) gmat.Tensor {
+	dq, dk, dv := a.BackwardQKV(grad)
+	return gmat.Add(gmat.Add(dq, dk), dv)
+}
+
+func (a *MultiHeadAttention) Params() []*autograd.Variable {
+	params := []*autograd.Variable{}
+	for _, d := range []*Dense{a.Wq, a.Wk, a.Wv, a.Wo} {
+		params = append(params, d.Params()...)
+	}
+	return params
+}
@@ -0,0 +1,102 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/gradcheck"
+	"testing"
+)
+
+func TestAttentionBackwardSuccess(t *testing.T) {
+	q := gmat.Normal(0, 1, 2, 3, 4)
+	k := gmat.Normal(0, 1, 2, 5, 4)
+	v := gmat.Normal(0, 1, 2, 5, 2)
+	mask := gmat.Mul(CausalMask(2, 3, 5), PaddingMask([]int{5, 4}, 3, 5))
+	grad := gmat.Normal(0, 1, 2, 3, 2)
+	out, weights := ScaledDotProductAttention(q, k, v, mask)
+	dq, dk, dv := ScaledDotProductAttentionBackward(q, k, v, weights, grad)
+	f := func(in []gmat.Tensor) gmat.Tensor {
+		out, _ := ScaledDotProductAttention(in[0], in[1], in[2], mask)
+		return out
+	}
+	inputs := []gmat.Tensor{q, k, v}
+	report := gradcheck.CheckVJP(f, inputs, grad, []gmat.Tensor{dq, dk, dv}, gradcheck.DefaultOptions)
+	if !report.OK {
+		t.Fatal("failed Test!", report)
+	}
+	// the second sequence never attends to its padded last key
+	w := gmat.ToSlice(weights)
+	for i := 0; i < 3; i++ {
+		if w[15+i*5+4] != 0 {
+			t.Fatal("failed Test!")
+		}
+	}
+	chunked := ChunkedAttention(q, k, v, mask, 2)
+	cq, ck, cv := ChunkedAttentionBackward(q, k, v, mask, grad, 2)
+	pairs := [][2]gmat.Tensor{{out, chunked}, {dq, cq}, {dk, ck}, {dv, cv}}
+	for _, p := range pairs {
+		if !near(gmat.ToSlice(p[0]), gmat.ToSlice(p[1]), 1e-12) {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestCausalMaskSuccess(t *testing.T) {
+	mask := gmat.ToSlice(CausalMask(1, 2, 3))
+	if !equal(mask, []float64{1, 1, 0, 1, 1, 1}) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestMultiHeadAttentionSuccess(t *testing.T) {
+	x := gmat.Normal(0, 1, 2, 3, 4)
+	a := NewMultiHeadAttention(4, 2)
+	a.Causal = true
+	a.Mask = PaddingMask([]int{3, 2}, 3, 3)
+	checkLayer(a, x, t)
+	a.Chunk = 2
+	checkLayer(a, x, t)
+	// with a causal mask the first step ignores later ones
+	y := gmat.ToSlice(a.Forward(x))
+	data := gmat.ToSlice(x)
+	for i := 4; i < 12; i++ {
+		data[i] += 1
+	}
+	z := gmat.ToSlice(a.Forward(gmat.FromSlice(data, 2, 3, 4)))
+	if !near(y[:4], z[:4], 1e-12) || near(y[4:8], z[4:8], 1e-3) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestCrossAttentionSuccess(t *testing.T) {
+	q := gmat.Normal(0, 1, 2, 2, 4)
+	kv := []gmat.Tensor{gmat.Normal(0, 1, 2, 3, 4), gmat.Normal(0, 1, 2, 3, 4)}
+	a := NewMultiHeadAttention(4, 4)
+	grad := gmat.Normal(0, 1, 2, 2, 4)
+	a.ForwardQKV(q, kv[0], kv[1])
+	dq, dk, dv := a.BackwardQKV(grad)
+	f := func(in []gmat.Tensor) gmat.Tensor {
+		return a.ForwardQKV(in[0], in[1], in[2])
+	}
+	report := gradcheck.CheckVJP(f, []gmat.Tensor{q, kv[0], kv[1]}, grad, []gmat.Tensor{dq, dk, dv}, gradcheck.DefaultOptions)
+	if !report.OK {
+		t.Fatal("failed Test!", report)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"github.com/kuroko1t/gmat/initializer"
+	"log"
+)
+
+// Dense computes x W + b for x [N, In], W [In, Out] and b [1, Out].
+type Dense struct {
+	W *autograd.Variable
+	B *autograd.Variable
+	x gmat.Tensor
+}
+
+// NewDense initialises W with Glorot uniform and b with zeros. Pass
+// bias false for a layer without b.
+func NewDense(in, out int, bias bool) *Dense {
+	d := &Dense{W: autograd.NewVariable(initializer.GlorotUniform([]int{in, out}), true)}
+	if bias {
+		d.B = autograd.NewVariable(gmat.MakeInit(1, out, 0), true)
+	}
+	return d
+}
+
+func (d *Dense) Forward(x gmat.Tensor) gmat.Tensor {
+	if len(x.Shape) != 2 || x.Shape[1] != d.W.Value.Shape[0] {
+		log.Fatal("Dense.Forward mismatch input shape")
+	}
+	d.x = x
+	z := gmat.Dot(x, d.W.Value)
+	if d.B != nil {
+		z = gmat.Add(z, gmat.Cast(d.B.Value, x.Shape[0]))
+	}
+	return z
+}
+
+func (d *Dense) Backward(grad gmat.Tensor) gmat.Tensor {
+	accumulate(d.W, gmat.Dot(gmat.T(d.x), grad))
+	if d.B != nil {
+		accumulate(d.B, gmat.SumRow(grad))
+	}
+	return gmat.Dot(grad, gmat.T(d.W.Value))
+}
+
+func (d *Dense) Params() []*autograd.Variable {
+	if d.B == nil {
+		return []*autograd.Variable{d.W}
+	}
+	return []*autograd.Variable{d.W, d.B}
+}
+
+// Embedding looks up rows of W [Num, Dim]. Forward takes indices [N, 1] or
+// [N, T] stored as floats and returns [N, T*Dim], the embeddings of each
+// sample side by side. Backward only adds into the rows that were used.
+type Embedding struct {
+	W     *autograd.Variable
+	index []int
+}
+
+func NewEmbedding(num, dim int) *Embedding {
+	return &Embedding{W: autograd.NewVariable(gmat.Normal(0, 1, num, dim), true)}
+}
+
+func (e *Embedding) Lookup(index []int) gmat.Tensor {
+	num := e.W.Value.Shape[0]
+	for _, i := range index {
+		if i < 0 || i >= num {
+			log.Fatal("Embedding.Lookup index out of range")
+		}
+	}
+	e.index = index
+	return gmat.Gather(e.W.Value, index)
+}
+
+func (e *Embedding) Forward(x gmat.Tensor) gmat.Tensor {
+	ids := gmat.ToSlice(x)
+	index := make([]int, len(ids))
+	for i, v := range ids {
+		index[i] = int(v)
+	}
+	z := e.Lookup(index)
+	n := x.Shape[0]
+	return gmat.Reshape(z, []int{n, len(index) / n * e.W.Value.Shape[1]})
+}
+
+// Backward returns nil since indices have no gradient.
+func (e *Embedding) Backward(grad gmat.Tensor) gmat.Tensor {
+	rows := gmat.Reshape(grad, []int{len(e.index), e.W.Value.Shape[1]})
+	accumulate(e.W, gmat.ScatterAdd(gmat.ZerosLike(e.W.Value), e.index, rows))
+	return gmat.Tensor{}
+}
+
+func (e *Embedding) Params() []*autograd.Variable {
+	return []*autograd.Variable{e.W}
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"log"
+)
+
+// Dropout zeroes elements with probability Rate while training and scales
+// the rest by 1/(1-Rate), so evaluation is the identity. A Dropout starts
+// in training mode.
+type Dropout struct {
+	Rate float64
+	eval bool
+	mask gmat.Tensor
+}
+
+func NewDropout(rate float64) *Dropout {
+	if rate < 0 || rate >= 1 {
+		log.Fatal("Dropout.rate must be in [0, 1)")
+	}
+	return &Dropout{Rate: rate}
+}
+
+func (d *Dropout) SetTraining(training bool) {
+	d.eval = !training
+}
+
+func (d *Dropout) Forward(x gmat.Tensor) gmat.Tensor {
+	if d.eval || d.Rate == 0 {
+		d.mask = gmat.Tensor{}
+		return x
+	}
+	d.mask = gmat.MulE(gmat.Bernoulli(1-d.Rate, x.Shape...), 1/(1-d.Rate))
+	return gmat.Mul(x, d.mask)
+}
+
+func (d *Dropout) Backward(grad gmat.Tensor) gmat.Tensor {
+	if len(d.mask.Shape) == 0 {
+		return grad
+	}
+	return gmat.Mul(grad, d.mask)
+}
+
+func (d *Dropout) Params() []*autograd.Variable {
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package nn provides layers with explicit forward and backward passes.
+// Parameters are autograd Variables, so the optimizer package updates
+// them directly; Backward accumulates into their Grad.
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+)
+
+// Layer caches what it needs in Forward; Backward takes the gradient of the
+// output and returns the gradient of the input.
+type Layer interface {
+	Forward(x gmat.Tensor) gmat.Tensor
+	Backward(grad gmat.Tensor) gmat.Tensor
+	Params() []*autograd.Variable
+}
+
+// Mode is implemented by layers that behave differently in training.
+type Mode interface {
+	SetTraining(training bool)
+}
+
+// Stateful is implemented by layers that keep tensors besides their
+// parameters, such as running statistics. Buffers points at them so that
+// checkpoints can save and restore them.
+type Stateful interface {
+	Buffers() []*gmat.Tensor
+}
+
+// Buffers returns the buffers of l and of every layer it contains.
+func Buffers(l Layer) []*gmat.Tensor {
+	if s, ok := l.(Stateful); ok {
+		return s.Buffers()
+	}
+	return nil
+}
+
+// Train switches l and every layer it contains to training mode.
+func Train(l Layer) {
+	if m, ok := l.(Mode); ok {
+		m.SetTraining(true)
+	}
+}
+
+func Eval(l Layer) {
+	if m, ok := l.(Mode); ok {
+		m.SetTraining(false)
+	}
+}
+
+func ZeroGrad(l Layer) {
+	for _, p := range l.Params() {
+		p.ZeroGrad()
+	}
+}
+
+func accumulate(p *autograd.Variable, g gmat.Tensor) {
+	if p.HasGrad() {
+		p.Grad = gmat.Add(p.Grad, g)
+	} else {
+		p.Grad = g
+	}
+}
+
+type Sequential struct {
+	Layers []Layer
+}
+
+func NewSequential(layers ...Layer) *Sequential {
+	return &Sequential{Layers: layers}
+}
+
+func (s *Sequential) Add(l Layer) {
+	s.Layers = append(s.Layers, l)
+}
+
+func (s *Sequential) Forward(x gmat.Tensor) gmat.Tensor {
+	for _, l := range s.Layers {
+		x = l.Forward(x)
+	}
+	return x
+}
+
+func (s *Sequential) Backward(grad gmat.Tensor) gmat.Tensor {
+	for i := len(s.Layers) - 1; i >= 0; i-- {
+		grad = s.Layers[i].Backward(grad)
+	}
+	return grad
+}
+
+func (s *Sequential) Params() []*autograd.Variable {
+	params := []*autograd.Variable{}
+	for _, l := range s.Layers {
+		params = append(params, l.Params()...)
+	}
+	return params
+}
+
+func (s *Sequential) Buffers() []*gmat.Tensor {
+	var buffers []*gmat.Tensor
+	for _, l := range s.Layers {
+		buffers = append(buffers, Buffers(l)...)
+	}
+	return buffers
+}
+
+func (s *Sequential) SetTraining(training bool) {
+	for _, l := range s.Layers {
+		if m, ok := l.(Mode); ok {
+			m.SetTraining(training)
+		}
+	}
+}
+
+// Flatten reshapes [N, ...] to [N, D].
+type Flatten struct {
+	shape []int
+}
+
+func NewFlatten() *Flatten {
+	return &Flatten{}
+}
+
+func (f *Flatten) Forward(x gmat.Tensor) gmat.Tensor {
+	f.shape = x.Shape
+	d := 1
+	for _, s := range x.Shape[1:] {
+		d *= s
+	}
+	return gmat.Reshape(x, []int{x.Shape[0], d})
+}
+
+func (f *Flatten) Backward(grad gmat.Tensor) gmat.Tensor {
+	return gmat.Reshape(grad, f.shape)
+}
+
+func (f *Flatten) Params() []*autograd.Variable {
+	return nil
+}
+
+// Activation applies f elementwise; df is its derivative at the input.
+type Activation struct {
+	F  func(gmat.Tensor) gmat.Tensor
+	DF func(gmat.Tensor) gmat.Tensor
+	x  gmat.Tensor
+}
+
+func NewActivation(f, df func(gmat.Tensor) gmat.Tensor) *Activation {
+	return &Activation{F: f, DF: df}
+}
+
+func (a *Activation) Forward(x gmat.Tensor) gmat.Tensor {
+	a.x = x
+	return a.F(x)
+}
+
+func (a *Activation) Backward(grad gmat.Tensor) gmat.Tensor {
+	return gmat.Mul(grad, a.DF(a.x))
+}
+
+func (a *Activation) Params() []*autograd.Variable {
+	return nil
+}
+
+func ReLU() *Activation {
+	return NewActivation(gmat.ReLU, gmat.ReLUDerivative)
+}
+
+func LeakyReLU(alpha float64) *Activation {
+	return NewActivation(func(x gmat.Tensor) gmat.Tensor {
+		return gmat.LeakyReLU(x, alpha)
+	}, func(x gmat.Tensor) gmat.Tensor {
+		return gmat.LeakyReLUDerivative(x, alpha)
+	})
+}
+
+func GELU() *Activation {
+	return NewActivation(gmat.GELU, gmat.GELUDerivative)
+}
+
+func SiLU() *Activation {
+	return NewActivation(gmat.SiLU, gmat.SiLUDerivative)
+}
+
+func Sigmoid() *Activation {
+	return NewActivation(gmat.Sigmoid, gmat.SigmoidDerivative)
+}
+
+func Tanh() *Activation {
+	return NewActivation(gmat.Tanh, gmat.TanhDerivative)
+}
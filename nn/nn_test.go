@@ -0,0 +1,161 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/gradcheck"
+	"github.com/kuroko1t/gmat/optimizer"
+	"math"
+	"testing"
+)
+
+// checkLayer compares Backward with central differences for the input and
+// every parameter of l.
+func checkLayer(l Layer, x gmat.Tensor, t *testing.T) {
+	params := l.Params()
+	gradOut := gmat.Normal(0, 1, l.Forward(x).Shape...)
+	ZeroGrad(l)
+	gx := l.Backward(gradOut)
+	inputs := []gmat.Tensor{x}
+	analytic := []gmat.Tensor{gx}
+	for _, p := range params {
+		inputs = append(inputs, p.Value)
+		analytic = append(analytic, p.Grad)
+	}
+	f := func(in []gmat.Tensor) gmat.Tensor {
+		for i, p := range params {
+			p.Value = in[i+1]
+		}
+		return l.Forward(in[0])
+	}
+	report := gradcheck.CheckVJP(f, inputs, gradOut, analytic, gradcheck.DefaultOptions)
+	if !report.OK {
+		t.Fatal("failed Test!", report)
+	}
+}
+
+func TestDenseSuccess(t *testing.T) {
+	d := NewDense(4, 3, true)
+	d.B.Value = gmat.Normal(0, 1, 1, 3)
+	checkLayer(d, gmat.Normal(0, 1, 5, 4), t)
+	if len(NewDense(4, 3, false).Params()) != 1 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestSequentialSuccess(t *testing.T) {
+	model := NewSequential(NewFlatten(), NewDense(6, 5, true), Tanh(), NewDense(5, 2, true))
+	checkLayer(model, gmat.Normal(0, 1, 3, 2, 3), t)
+	if len(model.Params()) != 4 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestXorSuccess(t *testing.T) {
+	x := gmat.FromSlice([]float64{0, 0, 0, 1, 1, 0, 1, 1}, 4, 2)
+	target := gmat.FromSlice([]float64{1, 0, 0, 1, 0, 1, 1, 0}, 4, 2)
+	model := NewSequential(NewDense(2, 8, true), Tanh(), NewDense(8, 2, true))
+	opt := optimizer.NewAdam(model.Params(), optimizer.Config{LR: 0.05})
+	loss := 0.0
+	for i := 0; i < 500; i++ {
+		ZeroGrad(model)
+		var grad gmat.Tensor
+		loss, grad = gmat.SoftmaxCrossEntropy(model.Forward(x), target)
+		model.Backward(grad)
+		opt.Step()
+	}
+	if loss > 0.05 {
+		t.Fatal("failed Test!", loss)
+	}
+}
+
+func TestDropoutSuccess(t *testing.T) {
+	d := NewDropout(0.5)
+	x := gmat.Ones(100, 100)
+	y := gmat.ToSlice(d.Forward(x))
+	g := gmat.ToSlice(d.Backward(gmat.Ones(100, 100)))
+	zeros := 0
+	for i, v := range y {
+		if v != 0 && v != 2 || g[i] != v {
+			t.Fatal("failed Test!")
+		}
+		if v == 0 {
+			zeros++
+		}
+	}
+	if math.Abs(float64(zeros)/1e4-0.5) > 0.05 {
+		t.Fatal("failed Test!")
+	}
+	model := NewSequential(d)
+	Eval(model)
+	if gmat.Sum(model.Forward(x)) != 1e4 {
+		t.Fatal("failed Test!")
+	}
+	Train(model)
+	if equal(gmat.ToSlice(model.Forward(x)), gmat.ToSlice(x)) {
+		t.Fatal("failed Test!")
+	}
+	// a literal Dropout trains too
+	if equal(gmat.ToSlice((&Dropout{Rate: 0.5}).Forward(x)), gmat.ToSlice(x)) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestEmbeddingSuccess(t *testing.T) {
+	e := NewEmbedding(5, 2)
+	e.W.Value = gmat.FromSlice([]float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 5, 2)
+	z := e.Forward(gmat.FromSlice([]float64{4, 1, 1, 0}, 2, 2))
+	if z.Shape[0] != 2 || z.Shape[1] != 4 {
+		t.Fatal("failed Test!")
+	}
+	if !equal(gmat.ToSlice(z), []float64{8, 9, 2, 3, 2, 3, 0, 1}) {
+		t.Fatal("failed Test!")
+	}
+	e.Backward(gmat.Ones(2, 4))
+	if !equal(gmat.ToSlice(e.W.Grad), []float64{1, 1, 2, 2, 0, 0, 0, 0, 1, 1}) {
+		t.Fatal("failed Test!")
+	}
+	// one index per sample, accumulated into the existing gradient without
+	// writing through to a tensor that shares its storage
+	shared := e.W.Grad
+	z = e.Forward(gmat.FromSlice([]float64{3, 1}, 2, 1))
+	if z.Shape[0] != 2 || z.Shape[1] != 2 {
+		t.Fatal("failed Test!")
+	}
+	e.Backward(gmat.Ones(2, 2))
+	if !equal(gmat.ToSlice(e.W.Grad), []float64{1, 1, 3, 3, 0, 0, 1, 1, 1, 1}) {
+		t.Fatal("failed Test!")
+	}
+	if !equal(gmat.ToSlice(shared), []float64{1, 1, 2, 2, 0, 0, 0, 0, 1, 1}) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func equal(x, y []float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
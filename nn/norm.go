@@ -0,0 +1,208 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"log"
+)
+
+func affine(size int) (*autograd.Variable, *autograd.Variable) {
+	return autograd.NewVariable(gmat.Ones(1, size), true), autograd.NewVariable(gmat.MakeInit(1, size, 0), true)
+}
+
+func value(v *autograd.Variable) gmat.Tensor {
+	if v == nil {
+		return gmat.Tensor{}
+	}
+	return v.Value
+}
+
+func affineParams(gamma, beta *autograd.Variable) []*autograd.Variable {
+	if gamma == nil {
+		return nil
+	}
+	return []*autograd.Variable{gamma, beta}
+}
+
+func accumulateAffine(gamma, beta *autograd.Variable, dgamma, dbeta gmat.Tensor) {
+	if gamma != nil {
+		accumulate(gamma, dgamma)
+		accumulate(beta, dbeta)
+	}
+}
+
+// BatchNorm normalises each channel with batch statistics while training
+// and with running statistics in eval mode. RunningVar is unbiased. Like
+// convolutions it runs on the cpu build only. Gamma and Beta are [1, C], or
+// nil without an affine transform.
+type BatchNorm struct {
+	Gamma       *autograd.Variable
+	Beta        *autograd.Variable
+	RunningMean gmat.Tensor
+	RunningVar  gmat.Tensor
+	Momentum    float64
+	Eps         float64
+	rank        int
+	training    bool
+	frozen      bool
+	x           gmat.Tensor
+}
+
+func newBatchNorm(channels, rank int, affineParam bool) *BatchNorm {
+	b := &BatchNorm{
+		RunningMean: gmat.MakeInit(1, channels, 0),
+		RunningVar:  gmat.Ones(1, channels),
+		Momentum:    0.1,
+		Eps:         1e-5,
+		rank:        rank,
+		training:    true,
+	}
+	if affineParam {
+		b.Gamma, b.Beta = affine(channels)
+	}
+	return b
+}
+
+// NewBatchNorm1D takes [N, C] or [N, C, L].
+func NewBatchNorm1D(channels int, affine bool) *BatchNorm {
+	return newBatchNorm(channels, 1, affine)
+}
+
+// NewBatchNorm2D takes [N, C, H, W].
+func NewBatchNorm2D(channels int, affine bool) *BatchNorm {
+	return newBatchNorm(channels, 2, affine)
+}
+
+func (b *BatchNorm) Buffers() []*gmat.Tensor {
+	return []*gmat.Tensor{&b.RunningMean, &b.RunningVar}
+}
+
+func (b *BatchNorm) SetTraining(training bool) {
+	b.training = training
+}
+
+func (b *BatchNorm) Forward(x gmat.Tensor) gmat.Tensor {
+	rank := len(x.Shape)
+	if b.rank == 1 && rank != 2 && rank != 3 || b.rank == 2 && rank != 4 {
+		log.Fatal("BatchNorm.Forward mismatch input rank")
+	}
+	b.x = x
+	b.frozen = !b.training
+	if b.frozen {
+		return gmat.BatchNormInference(x, b.RunningMean, b.RunningVar, value(b.Gamma), value(b.Beta), b.Eps)
+	}
+	z, mean, variance := gmat.BatchNorm(x, value(b.Gamma), value(b.Beta), b.Eps)
+	m := float64(len(gmat.ToSlice(x)) / x.Shape[1])
+	if m > 1 {
+		variance = gmat.MulE(variance, m/(m-1))
+	}
+	b.RunningMean = gmat.Add(gmat.MulE(b.RunningMean, 1-b.Momentum), gmat.MulE(mean, b.Momentum))
+	b.RunningVar = gmat.Add(gmat.MulE(b.RunningVar, 1-b.Momentum), gmat.MulE(variance, b.Momentum))
+	return z
+}
+
+func (b *BatchNorm) Backward(grad gmat.Tensor) gmat.Tensor {
+	var gx, dgamma, dbeta gmat.Tensor
+	if b.frozen {
+		gx, dgamma, dbeta = gmat.BatchNormInferenceBackward(b.x, grad, b.RunningMean, b.RunningVar, value(b.Gamma), b.Eps)
+	} else {
+		gx, dgamma, dbeta = gmat.BatchNormBackward(b.x, grad, value(b.Gamma), b.Eps)
+	}
+	accumulateAffine(b.Gamma, b.Beta, dgamma, dbeta)
+	return gx
+}
+
+func (b *BatchNorm) Params() []*autograd.Variable {
+	return affineParams(b.Gamma, b.Beta)
+}
+
+// LayerNorm normalises every sample over all dims but the first, which
+// hold size elements, with Gamma and Beta of [1, size] or nil.
+type LayerNorm struct {
+	Gamma *autograd.Variable
+	Beta  *autograd.Variable
+	Eps   float64
+	x     gmat.Tensor
+}
+
+func NewLayerNorm(size int, affineParam bool) *LayerNorm {
+	l := &LayerNorm{Eps: 1e-5}
+	if affineParam {
+		l.Gamma, l.Beta = affine(size)
+	}
+	return l
+}
+
+func (l *LayerNorm) Forward(x gmat.Tensor) gmat.Tensor {
+	l.x = x
+	return gmat.LayerNorm(x, value(l.Gamma), value(l.Beta), l.Eps)
+}
+
+func (l *LayerNorm) Backward(grad gmat.Tensor) gmat.Tensor {
+	gx, dgamma, dbeta := gmat.LayerNormBackward(l.x, grad, value(l.Gamma), l.Eps)
+	accumulateAffine(l.Gamma, l.Beta, dgamma, dbeta)
+	return gx
+}
+
+func (l *LayerNorm) Params() []*autograd.Variable {
+	return affineParams(l.Gamma, l.Beta)
+}
+
+// GroupNorm normalises every sample over Groups groups of channels.
+// InstanceNorm is GroupNorm with one channel per group. Gamma and Beta
+// are [1, C] as in BatchNorm.
+type GroupNorm struct {
+	Groups int
+	Gamma  *autograd.Variable
+	Beta   *autograd.Variable
+	Eps    float64
+	x      gmat.Tensor
+}
+
+func NewGroupNorm(groups, channels int, affineParam bool) *GroupNorm {
+	if channels%groups != 0 {
+		log.Fatal("NewGroupNorm.channels not divisible by groups")
+	}
+	g := &GroupNorm{Groups: groups, Eps: 1e-5}
+	if affineParam {
+		g.Gamma, g.Beta = affine(channels)
+	}
+	return g
+}
+
+func NewInstanceNorm(channels int, affineParam bool) *GroupNorm {
+	return NewGroupNorm(channels, channels, affineParam)
+}
+
+func (g *GroupNorm) Forward(x gmat.Tensor) gmat.Tensor {
+	g.x = x
+	return gmat.GroupNorm(x, g.Groups, value(g.Gamma), value(g.Beta), g.Eps)
+}
+
+func (g *GroupNorm) Backward(grad gmat.Tensor) gmat.Tensor {
+	gx, dgamma, dbeta := gmat.GroupNormBackward(g.x, grad, g.Groups, value(g.Gamma), g.Eps)
+	accumulateAffine(g.Gamma, g.Beta, dgamma, dbeta)
+	return gx
+}
+
+func (g *GroupNorm) Params() []*autograd.Variable {
+	return affineParams(g.Gamma, g.Beta)
+}
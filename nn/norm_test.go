@@ -0,0 +1,76 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"math"
+	"testing"
+)
+
+func near(x, y []float64, tol float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if math.Abs(x[i]-y[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNormLayersSuccess(t *testing.T) {
+	x := gmat.Normal(1, 2, 3, 4, 2, 2)
+	layers := []Layer{
+		NewBatchNorm2D(4, true),
+		NewLayerNorm(16, true),
+		NewGroupNorm(2, 4, true),
+		NewInstanceNorm(4, false),
+	}
+	for _, l := range layers {
+		for _, p := range l.Params() {
+			p.Value = gmat.Normal(1, 1, p.Value.Shape...)
+		}
+		checkLayer(l, x, t)
+	}
+	b := NewBatchNorm1D(3, true)
+	Eval(b)
+	checkLayer(b, gmat.Normal(0, 1, 4, 3, 5), t)
+}
+
+func TestBatchNormRunningSuccess(t *testing.T) {
+	b := NewBatchNorm1D(2, false)
+	b.Momentum = 1
+	x := gmat.FromSlice([]float64{1, 10, 3, 30}, 2, 2)
+	y := gmat.ToSlice(b.Forward(x))
+	if !near(y, []float64{-1, -1, 1, 1}, 1e-4) {
+		t.Fatal("failed Test!")
+	}
+	// running variance is unbiased
+	if !near(gmat.ToSlice(b.RunningMean), []float64{2, 20}, 1e-12) ||
+		!near(gmat.ToSlice(b.RunningVar), []float64{2, 200}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	Eval(b)
+	y = gmat.ToSlice(b.Forward(x))
+	if !near(y, []float64{-1 / math.Sqrt(2), -1 / math.Sqrt(2), 1 / math.Sqrt(2), 1 / math.Sqrt(2)}, 1e-4) {
+		t.Fatal("failed Test!")
+	}
+}
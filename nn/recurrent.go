@@ -0,0 +1,415 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"log"
+	"math"
+)
+
+// StepCache keeps what a Cell needs to differentiate one step.
+type StepCache struct {
+	x     gmat.Tensor
+	prev  []gmat.Tensor
+	gates []gmat.Tensor
+}
+
+// Cell is one step of a recurrent layer. States are [h] or, for LSTM,
+// [h, c], each [N, Hidden].
+type Cell interface {
+	Step(x gmat.Tensor, state []gmat.Tensor) ([]gmat.Tensor, *StepCache)
+	// StepBackward takes the gradients of the next states and returns the
+	// gradients of x and of the previous states.
+	StepBackward(cache *StepCache, grad []gmat.Tensor) (gmat.Tensor, []gmat.Tensor)
+	InitState(n int) []gmat.Tensor
+	HiddenSize() int
+	Params() []*autograd.Variable
+}
+
+// cellParams projects x [N, F] and h [N, H] to gates*H columns with
+// Wx [F, gates*H], Wh [H, gates*H] and biases Bx, Bh [1, gates*H].
+type cellParams struct {
+	Wx     *autograd.Variable
+	Wh     *autograd.Variable
+	Bx     *autograd.Variable
+	Bh     *autograd.Variable
+	hidden int
+	gates  int
+}
+
+func newCellParams(input, hidden, gates int) cellParams {
+	k := 1 / math.Sqrt(float64(hidden))
+	uniform := func(shape ...int) *autograd.Variable {
+		return autograd.NewVariable(gmat.Uniform(-k, k, shape...), true)
+	}
+	return cellParams{
+		Wx:     uniform(input, gates*hidden),
+		Wh:     uniform(hidden, gates*hidden),
+		Bx:     uniform(1, gates*hidden),
+		Bh:     uniform(1, gates*hidden),
+		hidden: hidden,
+		gates:  gates,
+	}
+}
+
+func (p *cellParams) project(x, h gmat.Tensor) (gmat.Tensor, gmat.Tensor) {
+	n := x.Shape[0]
+	gx := gmat.Add(gmat.Dot(x, p.Wx.Value), gmat.Cast(p.Bx.Value, n))
+	gh := gmat.Add(gmat.Dot(h, p.Wh.Value), gmat.Cast(p.Bh.Value, n))
+	return gx, gh
+}
+
+func (p *cellParams) projectBackward(x, h, dgx, dgh gmat.Tensor) (gmat.Tensor, gmat.Tensor) {
+	accumulate(p.Wx, gmat.Dot(gmat.T(x), dgx))
+	accumulate(p.Bx, gmat.SumRow(dgx))
+	accumulate(p.Wh, gmat.Dot(gmat.T(h), dgh))
+	accumulate(p.Bh, gmat.SumRow(dgh))
+	return gmat.Dot(dgx, gmat.T(p.Wx.Value)), gmat.Dot(dgh, gmat.T(p.Wh.Value))
+}
+
+// split cuts [N, gates*H] into the gates.
+func (p *cellParams) split(x gmat.Tensor) []gmat.Tensor {
+	sizes := make([]int, p.gates)
+	for i := range sizes {
+		sizes[i] = p.hidden
+	}
+	return gmat.Split(x, sizes, 1)
+}
+
+func (p *cellParams) zeros(n int) gmat.Tensor {
+	return gmat.MakeInit(n, p.hidden, 0)
+}
+
+func (p *cellParams) HiddenSize() int {
+	return p.hidden
+}
+
+func (p *cellParams) Params() []*autograd.Variable {
+	return []*autograd.Variable{p.Wx, p.Wh, p.Bx, p.Bh}
+}
+
+func oneMinus(x gmat.Tensor) gmat.Tensor {
+	return gmat.AxpyE(x, -1, 1)
+}
+
+// sigmoidGrad and tanhGrad take the output of the function.
+func sigmoidGrad(s gmat.Tensor) gmat.Tensor {
+	return gmat.Mul(s, oneMinus(s))
+}
+
+func tanhGrad(t gmat.Tensor) gmat.Tensor {
+	return oneMinus(gmat.Mul(t, t))
+}
+
+// RNNCell computes h' = tanh(x Wx + bx + h Wh + bh), or relu with ReLU.
+type RNNCell struct {
+	cellParams
+	ReLU bool
+}
+
+func NewRNNCell(input, hidden int) *RNNCell {
+	return &RNNCell{cellParams: newCellParams(input, hidden, 1)}
+}
+
+func (c *RNNCell) InitState(n int) []gmat.Tensor {
+	return []gmat.Tensor{c.zeros(n)}
+}
+
+func (c *RNNCell) Step(x gmat.Tensor, state []gmat.Tensor) ([]gmat.Tensor, *StepCache) {
+	gx, gh := c.project(x, state[0])
+	a := gmat.Add(gx, gh)
+	var h gmat.Tensor
+	if c.ReLU {
+		h = gmat.ReLU(a)
+	} else {
+		h = gmat.Tanh(a)
+	}
+	return []gmat.Tensor{h}, &StepCache{x: x, prev: state, gates: []gmat.Tensor{a, h}}
+}
+
+func (c *RNNCell) StepBackward(cache *StepCache, grad []gmat.Tensor) (gmat.Tensor, []gmat.Tensor) {
+	var da gmat.Tensor
+	if c.ReLU {
+		da = gmat.Mul(grad[0], gmat.ReLUDerivative(cache.gates[0]))
+	} else {
+		da = gmat.Mul(grad[0], tanhGrad(cache.gates[1]))
+	}
+	dx, dh := c.projectBackward(cache.x, cache.prev[0], da, da)
+	return dx, []gmat.Tensor{dh}
+}
+
+// LSTMCell has the gates i, f, g, o in this order along the columns.
+type LSTMCell struct {
+	cellParams
+}
+
+func NewLSTMCell(input, hidden int) *LSTMCell {
+	return &LSTMCell{newCellParams(input, hidden, 4)}
+}
+
+func (c *LSTMCell) InitState(n int) []gmat.Tensor {
+	return []gmat.Tensor{c.zeros(n), c.zeros(n)}
+}
+
+func (c *LSTMCell) Step(x gmat.Tensor, state []gmat.Tensor) ([]gmat.Tensor, *StepCache) {
+	gx, gh := c.project(x, state[0])
+	a := c.split(gmat.Add(gx, gh))
+	i, f, g, o := gmat.Sigmoid(a[0]), gmat.Sigmoid(a[1]), gmat.Tanh(a[2]), gmat.Sigmoid(a[3])
+	cell := gmat.Add(gmat.Mul(f, state[1]), gmat.Mul(i, g))
+	tc := gmat.Tanh(cell)
+	h := gmat.Mul(o, tc)
+	return []gmat.Tensor{h, cell}, &StepCache{x: x, prev: state, gates: []gmat.Tensor{i, f, g, o, tc}}
+}
+
+func (c *LSTMCell) StepBackward(cache *StepCache, grad []gmat.Tensor) (gmat.Tensor, []gmat.Tensor) {
+	i, f, g, o, tc := cache.gates[0], cache.gates[1], cache.gates[2], cache.gates[3], cache.gates[4]
+	dh := grad[0]
+	do := gmat.Mul(dh, tc)
+	dc := gmat.Add(grad[1], gmat.Mul(gmat.Mul(dh, o), tanhGrad(tc)))
+	da := gmat.Concat([]gmat.Tensor{
+		gmat.Mul(gmat.Mul(dc, g), sigmoidGrad(i)),
+		gmat.Mul(gmat.Mul(dc, cache.prev[1]), sigmoidGrad(f)),
+		gmat.Mul(gmat.Mul(dc, i), tanhGrad(g)),
+		gmat.Mul(do, sigmoidGrad(o)),
+	}, 1)
+	dx, dhPrev := c.projectBackward(cache.x, cache.prev[0], da, da)
+	return dx, []gmat.Tensor{dhPrev, gmat.Mul(dc, f)}
+}
+
+// GRUCell has the gates r, z, n in this order along the columns and
+// computes n = tanh(x Wxn + bxn + r * (h Whn + bhn)), h' = (1-z) n + z h.
+type GRUCell struct {
+	cellParams
+}
+
+func NewGRUCell(input, hidden int) *GRUCell {
+	return &GRUCell{newCellParams(input, hidden, 3)}
+}
+
+func (c *GRUCell) InitState(n int) []gmat.Tensor {
+	return []gmat.Tensor{c.zeros(n)}
+}
+
+func (c *GRUCell) Step(x gmat.Tensor, state []gmat.Tensor) ([]gmat.Tensor, *StepCache) {
+	gx, gh := c.project(x, state[0])
+	xs, hs := c.split(gx), c.split(gh)
+	r := gmat.Sigmoid(gmat.Add(xs[0], hs[0]))
+	z := gmat.Sigmoid(gmat.Add(xs[1], hs[1]))
+	n := gmat.Tanh(gmat.Add(xs[2], gmat.Mul(r, hs[2])))
+	h := gmat.Add(gmat.Mul(oneMinus(z), n), gmat.Mul(z, state[0]))
+	return []gmat.Tensor{h}, &StepCache{x: x, prev: state, gates: []gmat.Tensor{r, z, n, hs[2]}}
+}
+
+func (c *GRUCell) StepBackward(cache *StepCache, grad []gmat.Tensor) (gmat.Tensor, []gmat.Tensor) {
+	r, z, n, hn := cache.gates[0], cache.gates[1], cache.gates[2], cache.gates[3]
+	h := cache.prev[0]
+	dh := grad[0]
+	dan := gmat.Mul(gmat.Mul(dh, oneMinus(z)), tanhGrad(n))
+	dar := gmat.Mul(gmat.Mul(dan, hn), sigmoidGrad(r))
+	daz := gmat.Mul(gmat.Mul(dh, gmat.Sub(h, n)), sigmoidGrad(z))
+	dgx := gmat.Concat([]gmat.Tensor{dar, daz, dan}, 1)
+	dgh := gmat.Concat([]gmat.Tensor{dar, daz, gmat.Mul(dan, r)}, 1)
+	dx, dhPrev := c.projectBackward(cache.x, h, dgx, dgh)
+	return dx, []gmat.Tensor{gmat.Add(dhPrev, gmat.Mul(dh, z))}
+}
+
+// Recurrent runs one cell per direction over x [T, N, F] and returns the
+// hidden states [T, N, H], or [T, N, 2H] with the backward direction
+// concatenated when bidirectional.
+type Recurrent struct {
+	Cells []Cell
+	// Mask [T, N] marks valid steps with 1. Masked steps keep the previous
+	// state and output zeros, so right padded sequences end in their last
+	// valid state.
+	Mask gmat.Tensor
+	// TBPTT cuts the gradient between chunks of TBPTT steps; 0 runs full
+	// backpropagation through time. Carry Final into Initial to train over
+	// a long sequence one chunk per Forward.
+	TBPTT int
+	// Initial states per direction; nil starts from zeros. Final holds the
+	// last states after Forward.
+	Initial [][]gmat.Tensor
+	Final   [][]gmat.Tensor
+	// FinalGrad, when set, is the gradient of Final added in Backward.
+	// InitialGrad holds the gradient of the initial states afterwards.
+	FinalGrad   [][]gmat.Tensor
+	InitialGrad [][]gmat.Tensor
+	caches      [][]*StepCache
+	masks       []gmat.Tensor
+}
+
+// NewRecurrent takes one cell, or two for a bidirectional layer whose
+// second cell runs backwards in time.
+func NewRecurrent(cells ...Cell) *Recurrent {
+	if len(cells) != 1 && len(cells) != 2 {
+		log.Fatal("NewRecurrent.need one or two cells")
+	}
+	return &Recurrent{Cells: cells}
+}
+
+func NewRNN(input, hidden int, bidirectional bool) *Recurrent {
+	if bidirectional {
+		return NewRecurrent(NewRNNCell(input, hidden), NewRNNCell(input, hidden))
+	}
+	return NewRecurrent(NewRNNCell(input, hidden))
+}
+
+func NewLSTM(input, hidden int, bidirectional bool) *Recurrent {
+	if bidirectional {
+		return NewRecurrent(NewLSTMCell(input, hidden), NewLSTMCell(input, hidden))
+	}
+	return NewRecurrent(NewLSTMCell(input, hidden))
+}
+
+func NewGRU(input, hidden int, bidirectional bool) *Recurrent {
+	if bidirectional {
+		return NewRecurrent(NewGRUCell(input, hidden), NewGRUCell(input, hidden))
+	}
+	return NewRecurrent(NewGRUCell(input, hidden))
+}
+
+// time returns the time index of the k-th step of direction d.
+func (r *Recurrent) time(d, k, steps int) int {
+	if d == 1 {
+		return steps - 1 - k
+	}
+	return k
+}
+
+// stepMasks returns one [N, H] mask per step, or nil without Mask.
+func (r *Recurrent) stepMasks(steps int) []gmat.Tensor {
+	if len(r.Mask.Shape) == 0 {
+		return nil
+	}
+	if r.Mask.Shape[0] != steps {
+		log.Fatal("Recurrent.mismatch mask steps")
+	}
+	sizes := make([]int, steps)
+	for i := range sizes {
+		sizes[i] = 1
+	}
+	masks := gmat.Split(r.Mask, sizes, 0)
+	for t := range masks {
+		masks[t] = gmat.Cast(gmat.T(masks[t]), r.Cells[0].HiddenSize())
+	}
+	return masks
+}
+
+func (r *Recurrent) Forward(x gmat.Tensor) gmat.Tensor {
+	if len(x.Shape) != 3 {
+		log.Fatal("Recurrent.Forward need [T, N, F]")
+	}
+	steps := gmat.Unstack(x)
+	n := x.Shape[1]
+	r.masks = r.stepMasks(len(steps))
+	r.caches = make([][]*StepCache, len(r.Cells))
+	r.Final = make([][]gmat.Tensor, len(r.Cells))
+	outputs := make([][]gmat.Tensor, len(r.Cells))
+	for d, cell := range r.Cells {
+		state := cell.InitState(n)
+		if d < len(r.Initial) && r.Initial[d] != nil {
+			state = r.Initial[d]
+		}
+		r.caches[d] = make([]*StepCache, len(steps))
+		outputs[d] = make([]gmat.Tensor, len(steps))
+		for k := range steps {
+			t := r.time(d, k, len(steps))
+			next, cache := cell.Step(steps[t], state)
+			r.caches[d][t] = cache
+			if r.masks != nil {
+				m := r.masks[t]
+				for i := range next {
+					next[i] = gmat.Add(gmat.Mul(m, next[i]), gmat.Mul(oneMinus(m), state[i]))
+				}
+				outputs[d][t] = gmat.Mul(m, next[0])
+			} else {
+				outputs[d][t] = next[0]
+			}
+			state = next
+		}
+		r.Final[d] = state
+	}
+	out := make([]gmat.Tensor, len(steps))
+	for t := range out {
+		if len(r.Cells) == 1 {
+			out[t] = outputs[0][t]
+		} else {
+			out[t] = gmat.Concat([]gmat.Tensor{outputs[0][t], outputs[1][t]}, 1)
+		}
+	}
+	return gmat.Stack(out)
+}
+
+func (r *Recurrent) Backward(grad gmat.Tensor) gmat.Tensor {
+	gsteps := gmat.Unstack(grad)
+	steps := len(gsteps)
+	hidden := r.Cells[0].HiddenSize()
+	dx := make([]gmat.Tensor, steps)
+	r.InitialGrad = make([][]gmat.Tensor, len(r.Cells))
+	for d, cell := range r.Cells {
+		n := gsteps[0].Shape[0]
+		carry := cell.InitState(n)
+		if d < len(r.FinalGrad) && r.FinalGrad[d] != nil {
+			carry = r.FinalGrad[d]
+		}
+		for k := steps - 1; k >= 0; k-- {
+			t := r.time(d, k, steps)
+			dout := gsteps[t]
+			if len(r.Cells) == 2 {
+				dout = gmat.Split(dout, []int{hidden, hidden}, 1)[d]
+			}
+			dnext := append([]gmat.Tensor{}, carry...)
+			skip := make([]gmat.Tensor, len(carry))
+			if r.masks != nil {
+				m := r.masks[t]
+				dnext[0] = gmat.Add(dnext[0], gmat.Mul(m, dout))
+				for i := range dnext {
+					skip[i] = gmat.Mul(oneMinus(m), dnext[i])
+					dnext[i] = gmat.Mul(m, dnext[i])
+				}
+			} else {
+				dnext[0] = gmat.Add(dnext[0], dout)
+			}
+			dxt, dprev := cell.StepBackward(r.caches[d][t], dnext)
+			if r.masks != nil {
+				for i := range dprev {
+					dprev[i] = gmat.Add(dprev[i], skip[i])
+				}
+			}
+			if len(dx[t].Shape) == 0 {
+				dx[t] = dxt
+			} else {
+				dx[t] = gmat.Add(dx[t], dxt)
+			}
+			carry = dprev
+			if r.TBPTT > 0 && k > 0 && k%r.TBPTT == 0 {
+				carry = cell.InitState(n)
+			}
+		}
+		r.InitialGrad[d] = carry
+	}
+	return gmat.Stack(dx)
+}
+
+func (r *Recurrent) Params() []*autograd.Variable {
+	params := []*autograd.Variable{}
+	for _, cell := range r.Cells {
+		params = append(params, cell.Params()...)
+	}
+	return params
+}
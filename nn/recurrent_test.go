@@ -0,0 +1,100 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package nn
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/gradcheck"
+	"testing"
+)
+
+func TestRecurrentBackwardSuccess(t *testing.T) {
+	x := gmat.Normal(0, 1, 4, 3, 2)
+	relu := NewRNN(2, 3, false)
+	relu.Cells[0].(*RNNCell).ReLU = true
+	layers := []*Recurrent{
+		NewRNN(2, 3, false), relu,
+		NewLSTM(2, 3, false), NewGRU(2, 3, false),
+		NewLSTM(2, 3, true), NewGRU(2, 3, true),
+	}
+	for _, l := range layers {
+		checkLayer(l, x, t)
+	}
+	masked := NewLSTM(2, 3, true)
+	masked.Mask = gmat.FromSlice([]float64{1, 1, 1, 1, 1, 0, 1, 0, 0, 0, 0, 0}, 4, 3)
+	checkLayer(masked, x, t)
+}
+
+func TestRecurrentStateSuccess(t *testing.T) {
+	x := gmat.Normal(0, 1, 3, 2, 2)
+	l := NewLSTM(2, 3, false)
+	h0 := []gmat.Tensor{gmat.Normal(0, 1, 2, 3), gmat.Normal(0, 1, 2, 3)}
+	l.Initial = [][]gmat.Tensor{h0}
+	gradOut := gmat.Normal(0, 1, 3, 2, 3)
+	l.Forward(x)
+	l.FinalGrad = [][]gmat.Tensor{{gmat.Normal(0, 1, 2, 3), gmat.Normal(0, 1, 2, 3)}}
+	l.Backward(gradOut)
+	finalGrad := l.FinalGrad[0]
+	// loss = sum(out * gradOut) + sum(final * finalGrad)
+	f := func(in []gmat.Tensor) float64 {
+		l.Initial = [][]gmat.Tensor{in}
+		out := l.Forward(x)
+		sum := gmat.Sum(gmat.Mul(out, gradOut))
+		for i, s := range l.Final[0] {
+			sum += gmat.Sum(gmat.Mul(s, finalGrad[i]))
+		}
+		return sum
+	}
+	report := gradcheck.Check(f, h0, l.InitialGrad[0], gradcheck.DefaultOptions)
+	if !report.OK {
+		t.Fatal("failed Test!", report)
+	}
+}
+
+func TestRecurrentMaskSuccess(t *testing.T) {
+	x := gmat.Normal(0, 1, 4, 2, 3)
+	l := NewGRU(3, 2, false)
+	l.Mask = gmat.FromSlice([]float64{1, 1, 1, 1, 1, 0, 1, 0}, 4, 2)
+	out := gmat.ToSlice(l.Forward(x))
+	// the second sequence has length 2 and outputs zeros afterwards
+	for _, i := range []int{10, 11, 14, 15} {
+		if out[i] != 0 {
+			t.Fatal("failed Test!")
+		}
+	}
+	final := gmat.ToSlice(l.Final[0][0])
+	if !equal(final[2:], out[6:8]) || !equal(final[:2], out[12:14]) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestTruncatedBPTTSuccess(t *testing.T) {
+	x := gmat.Normal(0, 1, 4, 2, 3)
+	l := NewRNN(3, 2, false)
+	l.TBPTT = 2
+	l.Forward(x)
+	// only the last output has a gradient, which must not reach the first chunk
+	grad := gmat.FromSlice(append(make([]float64, 12), 1, 1, 1, 1), 4, 2, 2)
+	dx := gmat.ToSlice(l.Backward(grad))
+	for i, v := range dx {
+		if i < 12 && v != 0 || i >= 12 && v == 0 {
+			t.Fatal("failed Test!")
+		}
+	}
+}
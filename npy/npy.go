@@ -0,0 +1,403 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package npy reads and writes NumPy .npy files and .npz archives.
+//
+// Arrays of any rank map onto tensors of the same shape, except that a
+// scalar becomes a [1, 1] tensor. Values are converted to float64 on
+// reading and from float64 on writing.
+package npy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/kuroko1t/gmat"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const magic = "\x93NUMPY"
+
+// maxElements bounds the element count a header may declare.
+const maxElements = math.MaxInt32
+
+// chunk is the number of elements Read decodes at a time, so that a
+// truncated file fails before its declared size is allocated.
+const chunk = 1 << 16
+
+// dtype is a parsed array-protocol type string such as "<f8".
+type dtype struct {
+	order binary.ByteOrder
+	kind  byte
+	size  int
+}
+
+func parseDtype(descr string) (dtype, error) {
+	if len(descr) < 3 {
+		return dtype{}, fmt.Errorf("npy: unsupported dtype %q", descr)
+	}
+	d := dtype{order: binary.LittleEndian, kind: descr[1]}
+	if descr[0] == '>' {
+		d.order = binary.BigEndian
+	} else if !strings.ContainsRune("<=|", rune(descr[0])) {
+		return d, fmt.Errorf("npy: unsupported dtype %q", descr)
+	}
+	size, err := strconv.Atoi(descr[2:])
+	d.size = size
+	ok := err == nil
+	switch d.kind {
+	case 'f':
+		ok = ok && (size == 4 || size == 8)
+	case 'i', 'u':
+		ok = ok && (size == 1 || size == 2 || size == 4 || size == 8)
+	case 'b':
+		ok = ok && size == 1
+	default:
+		ok = false
+	}
+	if !ok {
+		return d, fmt.Errorf("npy: unsupported dtype %q", descr)
+	}
+	return d, nil
+}
+
+// String returns the canonical type string written to headers.
+func (d dtype) String() string {
+	order := "<"
+	if d.size == 1 {
+		order = "|"
+	} else if d.order == binary.BigEndian {
+		order = ">"
+	}
+	return order + string(d.kind) + strconv.Itoa(d.size)
+}
+
+func (d dtype) decode(b []byte) float64 {
+	switch d.size {
+	case 1:
+		if d.kind == 'i' {
+			return float64(int8(b[0]))
+		}
+		return float64(b[0])
+	case 2:
+		v := d.order.Uint16(b)
+		if d.kind == 'i' {
+			return float64(int16(v))
+		}
+		return float64(v)
+	case 4:
+		v := d.order.Uint32(b)
+		switch d.kind {
+		case 'f':
+			return float64(math.Float32frombits(v))
+		case 'i':
+			return float64(int32(v))
+		}
+		return float64(v)
+	}
+	v := d.order.Uint64(b)
+	switch d.kind {
+	case 'f':
+		return math.Float64frombits(v)
+	case 'i':
+		return float64(int64(v))
+	}
+	return float64(v)
+}
+
+// encode stores v, rounding to the nearest integer for integer types.
+func (d dtype) encode(b []byte, v float64) {
+	if d.kind != 'f' {
+		v = math.Round(v)
+	}
+	switch d.size {
+	case 1:
+		if d.kind == 'i' {
+			b[0] = byte(int8(v))
+		} else if d.kind == 'b' && v != 0 {
+			b[0] = 1
+		} else {
+			b[0] = byte(v)
+		}
+	case 2:
+		if d.kind == 'i' {
+			d.order.PutUint16(b, uint16(int16(v)))
+		} else {
+			d.order.PutUint16(b, uint16(v))
+		}
+	case 4:
+		switch d.kind {
+		case 'f':
+			d.order.PutUint32(b, math.Float32bits(float32(v)))
+		case 'i':
+			d.order.PutUint32(b, uint32(int32(v)))
+		default:
+			d.order.PutUint32(b, uint32(v))
+		}
+	case 8:
+		switch d.kind {
+		case 'f':
+			d.order.PutUint64(b, math.Float64bits(v))
+		case 'i':
+			d.order.PutUint64(b, uint64(int64(v)))
+		default:
+			d.order.PutUint64(b, uint64(v))
+		}
+	}
+}
+
+// field returns the text following 'key': in the header dictionary.
+func field(header, key string) (string, error) {
+	i := strings.Index(header, "'"+key+"'")
+	if i < 0 {
+		i = strings.Index(header, `"`+key+`"`)
+	}
+	if i < 0 {
+		return "", fmt.Errorf("npy: header has no %s", key)
+	}
+	rest := strings.TrimSpace(header[i+len(key)+2:])
+	if !strings.HasPrefix(rest, ":") {
+		return "", errors.New("npy: malformed header")
+	}
+	return strings.TrimSpace(rest[1:]), nil
+}
+
+func parseHeader(header string) (d dtype, fortran bool, shape []int, err error) {
+	descr, err := field(header, "descr")
+	if err != nil {
+		return
+	}
+	if len(descr) < 2 || (descr[0] != '\'' && descr[0] != '"') {
+		return d, false, nil, errors.New("npy: unsupported descr")
+	}
+	end := strings.IndexByte(descr[1:], descr[0])
+	if end < 0 {
+		return d, false, nil, errors.New("npy: unsupported descr")
+	}
+	if d, err = parseDtype(descr[1 : end+1]); err != nil {
+		return
+	}
+	order, err := field(header, "fortran_order")
+	if err != nil {
+		return
+	}
+	fortran = strings.HasPrefix(order, "True")
+	dims, err := field(header, "shape")
+	if err != nil {
+		return
+	}
+	end = strings.IndexByte(dims, ')')
+	if !strings.HasPrefix(dims, "(") || end < 0 {
+		return d, false, nil, errors.New("npy: malformed shape")
+	}
+	shape = []int{}
+	for _, s := range strings.Split(dims[1:end], ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return d, false, nil, errors.New("npy: malformed shape")
+		}
+		shape = append(shape, n)
+	}
+	return d, fortran, shape, nil
+}
+
+// size returns the element count of shape, or an error when it exceeds
+// maxElements.
+func size(shape []int) (int, error) {
+	n := 1
+	for _, s := range shape {
+		if s != 0 && n > maxElements/s {
+			return 0, errors.New("npy: too many elements")
+		}
+		n *= s
+	}
+	return n, nil
+}
+
+// fromFortran reorders column-major data into row-major order.
+func fromFortran(data []float64, shape []int) []float64 {
+	z := make([]float64, len(data))
+	index := make([]int, len(shape))
+	for i := range z {
+		// i walks the row-major order, f is the column-major offset
+		f, stride := 0, 1
+		for k := range shape {
+			f += index[k] * stride
+			stride *= shape[k]
+		}
+		z[i] = data[f]
+		for k := len(shape) - 1; k >= 0; k-- {
+			if index[k]++; index[k] < shape[k] {
+				break
+			}
+			index[k] = 0
+		}
+	}
+	return z
+}
+
+// tensorShape maps a NumPy shape onto a tensor shape. gmat has no rank 0
+// tensors, so a scalar becomes [1, 1].
+func tensorShape(shape []int) []int {
+	if len(shape) == 0 {
+		return []int{1, 1}
+	}
+	return shape
+}
+
+// Read decodes an .npy stream of header version 1.0, 2.0 or 3.0. The
+// tensor keeps the array's shape, except that a scalar reads as [1, 1] and
+// so is written back with shape (1, 1).
+func Read(r io.Reader) (gmat.Tensor, error) {
+	pre := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, pre); err != nil {
+		return gmat.Tensor{}, err
+	}
+	if string(pre[:len(magic)]) != magic {
+		return gmat.Tensor{}, errors.New("npy: bad magic string")
+	}
+	var length int
+	switch pre[len(magic)] {
+	case 1:
+		var n uint16
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return gmat.Tensor{}, err
+		}
+		length = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return gmat.Tensor{}, err
+		}
+		length = int(n)
+	default:
+		return gmat.Tensor{}, fmt.Errorf("npy: unsupported version %d", pre[len(magic)])
+	}
+	// the header grows as it is read, as its length is untrusted
+	header, err := io.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return gmat.Tensor{}, err
+	}
+	if len(header) < length {
+		return gmat.Tensor{}, io.ErrUnexpectedEOF
+	}
+	d, fortran, shape, err := parseHeader(string(header))
+	if err != nil {
+		return gmat.Tensor{}, err
+	}
+	tshape := tensorShape(shape)
+	total, err := size(shape)
+	if err != nil {
+		return gmat.Tensor{}, err
+	}
+	n := chunk
+	if total < n {
+		n = total
+	}
+	data := make([]float64, 0, n)
+	raw := make([]byte, n*d.size)
+	for len(data) < total {
+		if left := total - len(data); left < n {
+			n = left
+		}
+		if _, err := io.ReadFull(r, raw[:n*d.size]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return gmat.Tensor{}, err
+		}
+		for i := 0; i < n; i++ {
+			data = append(data, d.decode(raw[i*d.size:(i+1)*d.size]))
+		}
+	}
+	if fortran {
+		data = fromFortran(data, shape)
+	}
+	return gmat.FromSliceNoCopy(data, tshape...), nil
+}
+
+// Write encodes x in C order as the given dtype, "<f8" when empty.
+func Write(w io.Writer, x gmat.Tensor, descr string) error {
+	if descr == "" {
+		descr = "<f8"
+	}
+	d, err := parseDtype(descr)
+	if err != nil {
+		return err
+	}
+	dims := make([]string, len(x.Shape))
+	for i, s := range x.Shape {
+		dims[i] = strconv.Itoa(s)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		// a one element tuple needs a trailing comma
+		shape += ","
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", d, shape)
+	// pad with spaces so the data starts at a multiple of 64 bytes
+	version, prefix := byte(1), len(magic)+4
+	if len(header)+prefix+64 > math.MaxUint16 {
+		version, prefix = 2, len(magic)+6
+	}
+	header += strings.Repeat(" ", 63-(prefix+len(header))%64) + "\n"
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	buf.Write([]byte{version, 0})
+	if version == 1 {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	} else {
+		binary.Write(&buf, binary.LittleEndian, uint32(len(header)))
+	}
+	buf.WriteString(header)
+	data := gmat.ToSlice(x)
+	raw := make([]byte, len(data)*d.size)
+	for i, v := range data {
+		d.encode(raw[i*d.size:(i+1)*d.size], v)
+	}
+	buf.Write(raw)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+func Load(path string) (gmat.Tensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return gmat.Tensor{}, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Save writes x to path as float64.
+func Save(path string, x gmat.Tensor) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = Write(f, x, "")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
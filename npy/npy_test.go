@@ -0,0 +1,183 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package npy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/kuroko1t/gmat"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func equal(x, y gmat.Tensor) bool {
+	if len(x.Shape) != len(y.Shape) {
+		return false
+	}
+	for i := range x.Shape {
+		if x.Shape[i] != y.Shape[i] {
+			return false
+		}
+	}
+	a, b := gmat.ToSlice(x), gmat.ToSlice(y)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// file assembles an .npy stream the way NumPy lays it out.
+func file(version byte, header string, data interface{}, order binary.ByteOrder) []byte {
+	var b bytes.Buffer
+	b.WriteString(magic)
+	b.Write([]byte{version, 0})
+	if version == 1 {
+		binary.Write(&b, binary.LittleEndian, uint16(len(header)))
+	} else {
+		binary.Write(&b, binary.LittleEndian, uint32(len(header)))
+	}
+	b.WriteString(header)
+	binary.Write(&b, order, data)
+	return b.Bytes()
+}
+
+func TestReadSuccess(t *testing.T) {
+	// a Fortran-ordered big-endian int32 array [[1, 2, 3], [4, 5, 6]]
+	f := file(1, "{'descr': '>i4', 'fortran_order': True, 'shape': (2, 3), }          \n", []int32{1, 4, 2, 5, -3, 6}, binary.BigEndian)
+	x, err := Read(bytes.NewReader(f))
+	if err != nil || !equal(x, gmat.FromSlice([]float64{1, 2, -3, 4, 5, 6}, 2, 3)) {
+		t.Fatal("failed Test!", err)
+	}
+	f = file(2, "{'descr': '<f4', 'fortran_order': False, 'shape': (3,), }\n", []float32{0.5, -1, 2}, binary.LittleEndian)
+	if x, err = Read(bytes.NewReader(f)); err != nil || !equal(x, gmat.FromSlice([]float64{0.5, -1, 2}, 3)) {
+		t.Fatal("failed Test!", err)
+	}
+	f = file(3, "{\"descr\": \"<f8\", \"fortran_order\": False, \"shape\": ()}\n", []float64{7}, binary.LittleEndian)
+	if x, err = Read(bytes.NewReader(f)); err != nil || !equal(x, gmat.FromSlice([]float64{7}, 1, 1)) {
+		t.Fatal("failed Test!", err)
+	}
+	// a Fortran-ordered 3D array matches its C-ordered transpose
+	data := []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	f = file(1, "{'descr': '<f8', 'fortran_order': True, 'shape': (2, 3, 2), }\n", data, binary.LittleEndian)
+	x, err = Read(bytes.NewReader(f))
+	if err != nil || gmat.ToSlice(x)[1] != 6 || gmat.ToSlice(x)[2] != 2 || gmat.ToSlice(x)[6] != 1 {
+		t.Fatal("failed Test!", err)
+	}
+}
+
+func TestReadErrorSuccess(t *testing.T) {
+	for _, f := range [][]byte{
+		[]byte("NUMPY\x01\x00"),
+		file(1, "{'descr': '<c16', 'fortran_order': False, 'shape': (1, 1), }\n", []float64{1, 0}, binary.LittleEndian),
+		file(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (2, 2), }\n", []float64{1, 2, 3}, binary.LittleEndian),
+		file(4, "{'descr': '<f8', 'fortran_order': False, 'shape': (1, 1), }\n", []float64{1}, binary.LittleEndian),
+		file(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (-1, 2), }\n", []float64{1, 2}, binary.LittleEndian),
+		file(1, "{'descr':", []float64{}, binary.LittleEndian),
+		file(1, "{'descr': ''", []float64{}, binary.LittleEndian),
+		file(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (65536, 65536), }\n", []float64{1}, binary.LittleEndian),
+		file(1, "{'descr': '<f8', 'fortran_order': False, 'shape': (1000, 1000), }\n", []float64{1}, binary.LittleEndian),
+		file(2, "{'descr': '<f8'", []float64{}, binary.LittleEndian)[:15],
+	} {
+		if _, err := Read(bytes.NewReader(f)); err == nil {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestWriteSuccess(t *testing.T) {
+	shapes := [][]int{{6}, {2, 3}, {2, 1, 3}, {1, 2, 1, 3}, {1, 2, 1, 3, 1}, {1, 1, 2, 1, 3, 1}, {1, 1, 1, 2, 1, 3, 1}}
+	for _, descr := range []string{"", "<f4", ">f8", "<i8", ">i4", "<i2", "|i1", "|u1", "<u4", "|b1"} {
+		for _, shape := range shapes {
+			data := []float64{0, 1, 0, 1, 1, 0}
+			if descr != "|b1" {
+				data = []float64{0, 1, 2, 3, 4, 5}
+			}
+			x := gmat.FromSlice(data, shape...)
+			var b bytes.Buffer
+			if err := Write(&b, x, descr); err != nil {
+				t.Fatal("failed Test!", err)
+			}
+			d, _ := parseDtype("<f8")
+			if descr != "" {
+				d, _ = parseDtype(descr)
+			}
+			if (b.Len()-6*d.size)%64 != 0 || !strings.Contains(b.String(), "'"+d.String()+"'") {
+				t.Fatal("failed Test!", descr)
+			}
+			z, err := Read(&b)
+			if err != nil || !equal(x, z) {
+				t.Fatal("failed Test!", descr, err)
+			}
+		}
+	}
+	x := gmat.FromSlice([]float64{-1.5, 2.4}, 1, 2)
+	var b bytes.Buffer
+	Write(&b, x, "<i4")
+	if z, _ := Read(&b); !equal(z, gmat.FromSlice([]float64{-2, 2}, 1, 2)) {
+		t.Fatal("failed Test!")
+	}
+	if err := Write(&b, x, "<f16"); err == nil {
+		t.Fatal("failed Test!")
+	}
+	b.Reset()
+	if Write(&b, gmat.FromSlice([]float64{1, 2}, 2), ""); !strings.Contains(b.String(), "'shape': (2,)") {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestNPZSuccess(t *testing.T) {
+	tensors := map[string]gmat.Tensor{
+		"w": gmat.Normal(0, 1, 3, 4),
+		"b": gmat.Normal(0, 1, 1, 4),
+		"x": gmat.Normal(0, 1, 2, 1, 3, 3),
+	}
+	for _, compress := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := WriteNPZ(&b, tensors, compress); err != nil {
+			t.Fatal("failed Test!", err)
+		}
+		z, err := ReadNPZ(bytes.NewReader(b.Bytes()), int64(b.Len()))
+		if err != nil || len(z) != 3 {
+			t.Fatal("failed Test!", err)
+		}
+		for name, x := range tensors {
+			if !equal(x, z[name]) {
+				t.Fatal("failed Test!", name)
+			}
+		}
+	}
+	dir := t.TempDir()
+	if err := SaveNPZ(filepath.Join(dir, "a.npz"), tensors, true); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	z, err := LoadNPZ(filepath.Join(dir, "a.npz"))
+	if err != nil || !equal(z["x"], tensors["x"]) {
+		t.Fatal("failed Test!", err)
+	}
+	if err := Save(filepath.Join(dir, "w.npy"), tensors["w"]); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	w, err := Load(filepath.Join(dir, "w.npy"))
+	if err != nil || !equal(w, tensors["w"]) {
+		t.Fatal("failed Test!", err)
+	}
+}
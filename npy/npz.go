@@ -0,0 +1,98 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package npy
+
+import (
+	"archive/zip"
+	"github.com/kuroko1t/gmat"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ReadNPZ decodes every array of an .npz archive, keyed by its name
+// without the .npy suffix.
+func ReadNPZ(r io.ReaderAt, size int64) (map[string]gmat.Tensor, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	tensors := map[string]gmat.Tensor{}
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		x, err := Read(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		tensors[strings.TrimSuffix(f.Name, ".npy")] = x
+	}
+	return tensors, nil
+}
+
+// WriteNPZ archives the tensors as float64 arrays in name order, deflated
+// like numpy.savez_compressed when compress is set.
+func WriteNPZ(w io.Writer, tensors map[string]gmat.Tensor, compress bool) error {
+	names := make([]string, 0, len(tensors))
+	for name := range tensors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	method := zip.Store
+	if compress {
+		method = zip.Deflate
+	}
+	z := zip.NewWriter(w)
+	for _, name := range names {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: name + ".npy", Method: method})
+		if err != nil {
+			return err
+		}
+		if err := Write(f, tensors[name], ""); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func LoadNPZ(path string) (map[string]gmat.Tensor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ReadNPZ(f, info.Size())
+}
+
+func SaveNPZ(path string, tensors map[string]gmat.Tensor, compress bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = WriteNPZ(f, tensors, compress)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package optimizer updates autograd parameters from their gradients.
+// All arithmetic goes through gmat, so the same code runs on both builds.
+package optimizer
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"log"
+	"math"
+)
+
+const (
+	SGD     = "sgd"
+	RMSProp = "rmsprop"
+	Adagrad = "adagrad"
+	Adam    = "adam"
+	AdamW   = "adamw"
+)
+
+// Config holds the hyper parameters of every optimizer. Fields that do not
+// apply to an algorithm are ignored; zero values of Beta1, Beta2, Rho and
+// Eps are replaced by the usual defaults.
+type Config struct {
+	LR float64
+	// WeightDecay is added to the gradient as an L2 term, except for
+	// AdamW which decays the weights directly.
+	WeightDecay float64
+	// ClipNorm rescales all gradients together when their global L2 norm
+	// exceeds it; ClipValue clips every element. Zero disables clipping.
+	ClipNorm  float64
+	ClipValue float64
+	Momentum  float64
+	Nesterov  bool
+	Beta1     float64
+	Beta2     float64
+	Rho       float64
+	Eps       float64
+}
+
+type Optimizer struct {
+	Config
+	Kind   string
+	Params []*autograd.Variable
+	Steps  int
+	// Scheduler, when set, updates LR at the start of every Step.
+	Scheduler Scheduler
+	// slots keep per-parameter state such as moments, one tensor per
+	// parameter in the order of Params
+	slots map[string][]gmat.Tensor
+}
+
+func New(kind string, params []*autograd.Variable, config Config) *Optimizer {
+	switch kind {
+	case SGD, RMSProp, Adagrad, Adam, AdamW:
+	default:
+		log.Fatal("optimizer.unknown kind " + kind)
+	}
+	defaults := func(v *float64, d float64) {
+		if *v == 0 {
+			*v = d
+		}
+	}
+	defaults(&config.Beta1, 0.9)
+	defaults(&config.Beta2, 0.999)
+	defaults(&config.Rho, 0.99)
+	if kind == Adagrad {
+		defaults(&config.Eps, 1e-10)
+	}
+	defaults(&config.Eps, 1e-8)
+	return &Optimizer{Config: config, Kind: kind, Params: params, slots: map[string][]gmat.Tensor{}}
+}
+
+func NewSGD(params []*autograd.Variable, config Config) *Optimizer {
+	return New(SGD, params, config)
+}
+
+func NewRMSProp(params []*autograd.Variable, config Config) *Optimizer {
+	return New(RMSProp, params, config)
+}
+
+func NewAdagrad(params []*autograd.Variable, config Config) *Optimizer {
+	return New(Adagrad, params, config)
+}
+
+func NewAdam(params []*autograd.Variable, config Config) *Optimizer {
+	return New(Adam, params, config)
+}
+
+func NewAdamW(params []*autograd.Variable, config Config) *Optimizer {
+	return New(AdamW, params, config)
+}
+
+func (o *Optimizer) ZeroGrad() {
+	for _, p := range o.Params {
+		p.ZeroGrad()
+	}
+}
+
+// slot returns the state tensor name of parameter i, zero initialised.
+func (o *Optimizer) slot(name string, i int) gmat.Tensor {
+	if o.slots[name] == nil {
+		o.slots[name] = make([]gmat.Tensor, len(o.Params))
+	}
+	if len(o.slots[name][i].Shape) == 0 {
+		o.slots[name][i] = gmat.ZerosLike(o.Params[i].Value)
+	}
+	return o.slots[name][i]
+}
+
+func (o *Optimizer) setSlot(name string, i int, value gmat.Tensor) {
+	o.slots[name][i] = value
+}
+
+// GradNorm returns the global L2 norm of all gradients.
+func GradNorm(params []*autograd.Variable) float64 {
+	sum := 0.0
+	for _, p := range params {
+		if p.HasGrad() {
+			sum += gmat.Sum(gmat.Mul(p.Grad, p.Grad))
+		}
+	}
+	return math.Sqrt(sum)
+}
+
+// ClipGradNorm rescales the gradients in place so that their global norm
+// is at most maxNorm and returns the norm before clipping.
+func ClipGradNorm(params []*autograd.Variable, maxNorm float64) float64 {
+	norm := GradNorm(params)
+	if norm > maxNorm {
+		scale := maxNorm / (norm + 1e-6)
+		for _, p := range params {
+			if p.HasGrad() {
+				p.Grad = gmat.MulE(p.Grad, scale)
+			}
+		}
+	}
+	return norm
+}
+
+func ClipGradValue(params []*autograd.Variable, value float64) {
+	for _, p := range params {
+		if p.HasGrad() {
+			p.Grad = gmat.Clip(p.Grad, -value, value)
+		}
+	}
+}
+
+// Step applies one update to every parameter that has a gradient.
+func (o *Optimizer) Step() {
+	if o.ClipValue > 0 {
+		ClipGradValue(o.Params, o.ClipValue)
+	}
+	if o.ClipNorm > 0 {
+		ClipGradNorm(o.Params, o.ClipNorm)
+	}
+	if o.Scheduler != nil {
+		o.Scheduler.Step(o)
+	}
+	o.Steps++
+	for i, p := range o.Params {
+		if !p.HasGrad() {
+			continue
+		}
+		g := p.Grad
+		if o.WeightDecay != 0 {
+			if o.Kind == AdamW {
+				p.Value = gmat.MulE(p.Value, 1-o.LR*o.WeightDecay)
+			} else {
+				g = gmat.Add(g, gmat.MulE(p.Value, o.WeightDecay))
+			}
+		}
+		p.Value = gmat.Sub(p.Value, gmat.MulE(o.update(i, g), o.LR))
+	}
+}
+
+// update returns the direction of parameter i, before scaling by LR.
+func (o *Optimizer) update(i int, g gmat.Tensor) gmat.Tensor {
+	switch o.Kind {
+	case SGD:
+		if o.Momentum == 0 {
+			return g
+		}
+		v := gmat.Add(gmat.MulE(o.slot("momentum", i), o.Momentum), g)
+		o.setSlot("momentum", i, v)
+		if o.Nesterov {
+			return gmat.Add(g, gmat.MulE(v, o.Momentum))
+		}
+		return v
+	case RMSProp:
+		s := gmat.Add(gmat.MulE(o.slot("square", i), o.Rho), gmat.MulE(gmat.Mul(g, g), 1-o.Rho))
+		o.setSlot("square", i, s)
+		direction := gmat.Mul(g, gmat.SqrtT(s, 0, o.Eps))
+		if o.Momentum == 0 {
+			return direction
+		}
+		v := gmat.Add(gmat.MulE(o.slot("momentum", i), o.Momentum), direction)
+		o.setSlot("momentum", i, v)
+		return v
+	case Adagrad:
+		s := gmat.Add(o.slot("square", i), gmat.Mul(g, g))
+		o.setSlot("square", i, s)
+		return gmat.Mul(g, gmat.SqrtT(s, 0, o.Eps))
+	}
+	// Adam and AdamW
+	m := gmat.Add(gmat.MulE(o.slot("m", i), o.Beta1), gmat.MulE(g, 1-o.Beta1))
+	v := gmat.Add(gmat.MulE(o.slot("v", i), o.Beta2), gmat.MulE(gmat.Mul(g, g), 1-o.Beta2))
+	o.setSlot("m", i, m)
+	o.setSlot("v", i, v)
+	t := float64(o.Steps)
+	mHat := gmat.MulE(m, 1/(1-math.Pow(o.Beta1, t)))
+	vHat := gmat.MulE(v, 1/(1-math.Pow(o.Beta2, t)))
+	return gmat.Mul(mHat, gmat.SqrtT(vHat, 0, o.Eps))
+}
@@ -0,0 +1,150 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package optimizer
+
+import (
+	"bytes"
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"math"
+	"testing"
+)
+
+func ExpNearCheck(x, y []float64, tol float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if math.Abs(x[i]-y[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+// quadratic sets the gradient of sum((p - 3)^2) and returns the loss.
+func quadratic(p *autograd.Variable) float64 {
+	diff := gmat.Sub(p.Value, gmat.MulE(gmat.OnesLike(p.Value), 3))
+	p.Grad = gmat.MulE(diff, 2)
+	return gmat.Sum(gmat.Mul(diff, diff))
+}
+
+func newParam() *autograd.Variable {
+	return autograd.NewVariable(gmat.FromSlice([]float64{0, 1, -2, 5}, 2, 2), true)
+}
+
+func TestConvergeSuccess(t *testing.T) {
+	configs := map[string]Config{
+		SGD:     {LR: 0.1},
+		"mom":   {LR: 0.05, Momentum: 0.9},
+		"nest":  {LR: 0.05, Momentum: 0.9, Nesterov: true},
+		RMSProp: {LR: 0.01},
+		Adagrad: {LR: 0.5},
+		Adam:    {LR: 0.1},
+		AdamW:   {LR: 0.1},
+	}
+	for name, config := range configs {
+		kind := name
+		if name == "mom" || name == "nest" {
+			kind = SGD
+		}
+		p := newParam()
+		opt := New(kind, []*autograd.Variable{p}, config)
+		for i := 0; i < 1000; i++ {
+			opt.ZeroGrad()
+			quadratic(p)
+			opt.Step()
+		}
+		if loss := quadratic(p); loss > 1e-3 {
+			t.Fatal("failed Test!", name, loss)
+		}
+	}
+}
+
+func TestAdamStepSuccess(t *testing.T) {
+	p := autograd.NewVariable(gmat.FromSlice([]float64{1, 2}, 1, 2), true)
+	opt := NewAdam([]*autograd.Variable{p}, Config{LR: 0.1})
+	p.Grad = gmat.FromSlice([]float64{0.5, -4}, 1, 2)
+	opt.Step()
+	// the first bias corrected step moves every element by lr * sign(g)
+	if !ExpNearCheck(gmat.ToSlice(p.Value), []float64{0.9, 2.1}, 1e-6) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestWeightDecaySuccess(t *testing.T) {
+	p := autograd.NewVariable(gmat.FromSlice([]float64{2, -4}, 1, 2), true)
+	opt := NewSGD([]*autograd.Variable{p}, Config{LR: 0.5, WeightDecay: 0.1})
+	p.Grad = gmat.FromSlice([]float64{0, 0}, 1, 2)
+	opt.Step()
+	if !ExpNearCheck(gmat.ToSlice(p.Value), []float64{1.9, -3.8}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	q := autograd.NewVariable(gmat.FromSlice([]float64{2, -4}, 1, 2), true)
+	adamw := NewAdamW([]*autograd.Variable{q}, Config{LR: 0.5, WeightDecay: 0.1})
+	q.Grad = gmat.FromSlice([]float64{0, 0}, 1, 2)
+	adamw.Step()
+	if !ExpNearCheck(gmat.ToSlice(q.Value), []float64{1.9, -3.8}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestClipSuccess(t *testing.T) {
+	p := autograd.NewVariable(gmat.FromSlice([]float64{0, 0}, 1, 2), true)
+	p.Grad = gmat.FromSlice([]float64{3, 4}, 1, 2)
+	if norm := ClipGradNorm([]*autograd.Variable{p}, 1); norm != 5 {
+		t.Fatal("failed Test!")
+	}
+	if !ExpNearCheck(gmat.ToSlice(p.Grad), []float64{0.6, 0.8}, 1e-6) {
+		t.Fatal("failed Test!")
+	}
+	p.Grad = gmat.FromSlice([]float64{3, -4}, 1, 2)
+	opt := NewSGD([]*autograd.Variable{p}, Config{LR: 1, ClipValue: 1})
+	opt.Step()
+	if !ExpNearCheck(gmat.ToSlice(p.Value), []float64{-1, 1}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestSaveLoadSuccess(t *testing.T) {
+	p := newParam()
+	opt := NewAdam([]*autograd.Variable{p}, Config{LR: 0.1})
+	for i := 0; i < 3; i++ {
+		quadratic(p)
+		opt.Step()
+	}
+	var buf bytes.Buffer
+	if err := opt.Save(&buf); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	q := autograd.NewVariable(gmat.FromSlice(gmat.ToSlice(p.Value), 2, 2), true)
+	restored := NewAdam([]*autograd.Variable{q}, Config{})
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	for i := 0; i < 3; i++ {
+		quadratic(p)
+		opt.Step()
+		quadratic(q)
+		restored.Step()
+	}
+	if restored.Steps != 6 || !ExpNearCheck(gmat.ToSlice(p.Value), gmat.ToSlice(q.Value), 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
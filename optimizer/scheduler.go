@@ -0,0 +1,335 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package optimizer
+
+import (
+	"log"
+	"math"
+)
+
+// Scheduler sets the learning rate of an optimizer. Attach it to
+// Optimizer.Scheduler to advance once per Step, or call Step yourself,
+// e.g. once per epoch. The base rate is the optimizer LR at the first Step.
+type Scheduler interface {
+	Step(o *Optimizer)
+	State() SchedulerState
+	SetState(state SchedulerState)
+}
+
+// SchedulerState is the serialisable state of a Scheduler.
+type SchedulerState struct {
+	Count  int
+	BaseLR float64
+	Values map[string]float64
+	Inner  *SchedulerState
+}
+
+// counter is shared by the schedulers whose rate only depends on the
+// number of steps taken.
+type counter struct {
+	Count  int
+	BaseLR float64
+}
+
+// next records the base rate and returns the index of the current step.
+func (c *counter) next(o *Optimizer) int {
+	if c.Count == 0 && c.BaseLR == 0 {
+		c.BaseLR = o.LR
+	}
+	c.Count++
+	return c.Count - 1
+}
+
+func (c *counter) State() SchedulerState {
+	return SchedulerState{Count: c.Count, BaseLR: c.BaseLR}
+}
+
+func (c *counter) SetState(state SchedulerState) {
+	c.Count = state.Count
+	c.BaseLR = state.BaseLR
+}
+
+// StepDecay multiplies the rate by Gamma every StepSize steps.
+type StepDecay struct {
+	counter
+	StepSize int
+	Gamma    float64
+}
+
+func NewStepDecay(stepSize int, gamma float64) *StepDecay {
+	s := &StepDecay{StepSize: stepSize, Gamma: gamma}
+	s.check()
+	return s
+}
+
+func (s *StepDecay) check() {
+	if s.StepSize < 1 {
+		log.Fatal("StepDecay.step size must be positive")
+	}
+}
+
+func (s *StepDecay) Step(o *Optimizer) {
+	s.check()
+	t := s.next(o)
+	o.LR = s.BaseLR * math.Pow(s.Gamma, float64(t/s.StepSize))
+}
+
+type Exponential struct {
+	counter
+	Gamma float64
+}
+
+func NewExponential(gamma float64) *Exponential {
+	return &Exponential{Gamma: gamma}
+}
+
+func (s *Exponential) Step(o *Optimizer) {
+	t := s.next(o)
+	o.LR = s.BaseLR * math.Pow(s.Gamma, float64(t))
+}
+
+// CosineWarmRestarts anneals from the base rate to MinLR over T0 steps and
+// restarts, each cycle TMult times longer than the previous one.
+type CosineWarmRestarts struct {
+	counter
+	T0    int
+	TMult int
+	MinLR float64
+}
+
+func NewCosineWarmRestarts(t0, tMult int, minLR float64) *CosineWarmRestarts {
+	if tMult < 1 {
+		tMult = 1
+	}
+	s := &CosineWarmRestarts{T0: t0, TMult: tMult, MinLR: minLR}
+	s.check()
+	return s
+}
+
+func (s *CosineWarmRestarts) check() {
+	if s.T0 < 1 {
+		log.Fatal("CosineWarmRestarts.T0 must be positive")
+	}
+}
+
+func (s *CosineWarmRestarts) Step(o *Optimizer) {
+	s.check()
+	t := s.next(o)
+	period := s.T0
+	for t >= period {
+		t -= period
+		if s.TMult > 1 {
+			period *= s.TMult
+		}
+	}
+	o.LR = cosineAnneal(s.BaseLR, s.MinLR, float64(t)/float64(period))
+}
+
+// cosineAnneal goes from start to end as pct goes from 0 to 1.
+func cosineAnneal(start, end, pct float64) float64 {
+	return end + (start-end)*(1+math.Cos(math.Pi*pct))/2
+}
+
+// LinearWarmup ramps the rate from StartFactor * base to base over Steps
+// steps, then hands over to After when it is set.
+type LinearWarmup struct {
+	counter
+	Steps       int
+	StartFactor float64
+	After       Scheduler
+}
+
+func NewLinearWarmup(steps int, startFactor float64, after Scheduler) *LinearWarmup {
+	return &LinearWarmup{Steps: steps, StartFactor: startFactor, After: after}
+}
+
+func (s *LinearWarmup) Step(o *Optimizer) {
+	t := s.next(o)
+	if t < s.Steps {
+		pct := float64(t) / float64(s.Steps)
+		o.LR = s.BaseLR * (s.StartFactor + (1-s.StartFactor)*pct)
+		return
+	}
+	o.LR = s.BaseLR
+	if s.After != nil {
+		s.After.Step(o)
+	}
+}
+
+func (s *LinearWarmup) State() SchedulerState {
+	state := s.counter.State()
+	if s.After != nil {
+		inner := s.After.State()
+		state.Inner = &inner
+	}
+	return state
+}
+
+func (s *LinearWarmup) SetState(state SchedulerState) {
+	s.counter.SetState(state)
+	if s.After != nil && state.Inner != nil {
+		s.After.SetState(*state.Inner)
+	}
+}
+
+// OneCycle warms up from MaxLR/DivFactor to MaxLR over the first PctStart
+// of Total steps, then anneals to MaxLR/(DivFactor*FinalDivFactor). A zero
+// MaxLR uses the base rate.
+type OneCycle struct {
+	counter
+	MaxLR          float64
+	Total          int
+	PctStart       float64
+	DivFactor      float64
+	FinalDivFactor float64
+}
+
+func NewOneCycle(maxLR float64, total int) *OneCycle {
+	s := &OneCycle{MaxLR: maxLR, Total: total, PctStart: 0.3, DivFactor: 25, FinalDivFactor: 1e4}
+	s.check()
+	return s
+}
+
+func (s *OneCycle) check() {
+	if s.Total < 1 {
+		log.Fatal("OneCycle.total steps must be positive")
+	}
+	if s.PctStart < 0 || s.PctStart >= 1 {
+		log.Fatal("OneCycle.PctStart must be in [0, 1)")
+	}
+	if s.DivFactor <= 0 || s.FinalDivFactor <= 0 {
+		log.Fatal("OneCycle.div factors must be positive")
+	}
+}
+
+func (s *OneCycle) Step(o *Optimizer) {
+	s.check()
+	t := s.next(o)
+	max := s.MaxLR
+	if max == 0 {
+		max = s.BaseLR
+	}
+	initial := max / s.DivFactor
+	final := initial / s.FinalDivFactor
+	up := int(s.PctStart * float64(s.Total))
+	if t > s.Total {
+		t = s.Total
+	}
+	if t < up {
+		o.LR = cosineAnneal(initial, max, float64(t)/float64(up))
+		return
+	}
+	o.LR = cosineAnneal(max, final, float64(t-up)/float64(s.Total-up))
+}
+
+// ReduceOnPlateau multiplies the rate by Factor when the metric passed to
+// Observe has not improved by Threshold (relative) for Patience calls.
+type ReduceOnPlateau struct {
+	counter
+	// Max is set when larger metrics are better.
+	Max       bool
+	Factor    float64
+	Patience  int
+	Threshold float64
+	Cooldown  int
+	MinLR     float64
+	scale     float64
+	best      float64
+	bad       int
+	cooldown  int
+	seen      bool
+}
+
+func NewReduceOnPlateau(factor float64, patience int) *ReduceOnPlateau {
+	if factor <= 0 || factor >= 1 {
+		log.Fatal("ReduceOnPlateau.factor must be in (0, 1)")
+	}
+	if patience < 0 {
+		log.Fatal("ReduceOnPlateau.patience must not be negative")
+	}
+	return &ReduceOnPlateau{Factor: factor, Patience: patience, Threshold: 1e-4, scale: 1}
+}
+
+func (s *ReduceOnPlateau) better(metric float64) bool {
+	if !s.seen {
+		return true
+	}
+	if s.Max {
+		return metric > s.best*(1+s.Threshold)
+	}
+	return metric < s.best*(1-s.Threshold)
+}
+
+// Observe records a metric and reports whether the rate was reduced. The
+// new rate takes effect at the next Step.
+func (s *ReduceOnPlateau) Observe(metric float64) bool {
+	if s.better(metric) {
+		s.best = metric
+		s.seen = true
+		s.bad = 0
+	} else {
+		s.bad++
+	}
+	if s.cooldown > 0 {
+		s.cooldown--
+		s.bad = 0
+	}
+	if s.bad > s.Patience {
+		s.scale = s.currentScale() * s.Factor
+		s.cooldown = s.Cooldown
+		s.bad = 0
+		return true
+	}
+	return false
+}
+
+// currentScale treats the zero scale of a struct literal as 1.
+func (s *ReduceOnPlateau) currentScale() float64 {
+	if s.scale == 0 {
+		s.scale = 1
+	}
+	return s.scale
+}
+
+func (s *ReduceOnPlateau) Step(o *Optimizer) {
+	s.next(o)
+	o.LR = math.Max(s.BaseLR*s.currentScale(), s.MinLR)
+}
+
+func (s *ReduceOnPlateau) State() SchedulerState {
+	state := s.counter.State()
+	seen := 0.0
+	if s.seen {
+		seen = 1
+	}
+	state.Values = map[string]float64{
+		"scale": s.scale, "best": s.best, "bad": float64(s.bad),
+		"cooldown": float64(s.cooldown), "seen": seen,
+	}
+	return state
+}
+
+func (s *ReduceOnPlateau) SetState(state SchedulerState) {
+	s.counter.SetState(state)
+	s.scale = state.Values["scale"]
+	if s.scale == 0 {
+		s.scale = 1
+	}
+	s.best = state.Values["best"]
+	s.bad = int(state.Values["bad"])
+	s.cooldown = int(state.Values["cooldown"])
+	s.seen = state.Values["seen"] == 1
+}
@@ -0,0 +1,170 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package optimizer
+
+import (
+	"bytes"
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/autograd"
+	"math"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// rates steps a scheduler n times on a plain SGD optimizer.
+func rates(s Scheduler, lr float64, n int) []float64 {
+	opt := NewSGD(nil, Config{LR: lr})
+	out := make([]float64, n)
+	for i := range out {
+		s.Step(opt)
+		out[i] = opt.LR
+	}
+	return out
+}
+
+func TestStepDecaySuccess(t *testing.T) {
+	got := rates(NewStepDecay(2, 0.5), 1, 5)
+	if !ExpNearCheck(got, []float64{1, 1, 0.5, 0.5, 0.25}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	got = rates(NewExponential(0.5), 1, 3)
+	if !ExpNearCheck(got, []float64{1, 0.5, 0.25}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestCosineWarmRestartsSuccess(t *testing.T) {
+	got := rates(NewCosineWarmRestarts(2, 2, 0), 1, 7)
+	// cycles of 2 and 4 steps
+	if !ExpNearCheck(got, []float64{1, 0.5, 1, 0.5 + 0.5*math.Cos(math.Pi/4), 0.5, 0.5 - 0.5*math.Cos(math.Pi/4), 1}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestLinearWarmupSuccess(t *testing.T) {
+	got := rates(NewLinearWarmup(4, 0, NewStepDecay(1, 0.5)), 1, 6)
+	if !ExpNearCheck(got, []float64{0, 0.25, 0.5, 0.75, 1, 0.5}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestOneCycleSuccess(t *testing.T) {
+	s := NewOneCycle(1, 10)
+	got := rates(s, 0.1, 11)
+	if !ExpNearCheck(got[:1], []float64{0.04}, 1e-12) || got[3] != 1 {
+		t.Fatal("failed Test!")
+	}
+	if !ExpNearCheck(got[10:], []float64{0.04 / 1e4}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	for i := 4; i < 11; i++ {
+		if got[i] > got[i-1] {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestReduceOnPlateauSuccess(t *testing.T) {
+	s := NewReduceOnPlateau(0.5, 1)
+	opt := NewSGD(nil, Config{LR: 1})
+	reduced := []bool{}
+	for _, loss := range []float64{3, 2, 2, 2, 2, 2, 1} {
+		reduced = append(reduced, s.Observe(loss))
+		s.Step(opt)
+	}
+	want := []bool{false, false, false, true, false, true, false}
+	for i := range want {
+		if reduced[i] != want[i] {
+			t.Fatal("failed Test!")
+		}
+	}
+	if opt.LR != 0.25 {
+		t.Fatal("failed Test!")
+	}
+	// a struct literal and a state without a scale keep the base rate
+	literal := &ReduceOnPlateau{Factor: 0.5}
+	opt.LR = 1
+	literal.Step(opt)
+	if opt.LR != 1 {
+		t.Fatal("failed Test!")
+	}
+	literal.SetState(SchedulerState{Count: 1, BaseLR: 2})
+	literal.Step(opt)
+	if opt.LR != 2 {
+		t.Fatal("failed Test!")
+	}
+}
+
+// expectFatal runs fn in a child process, as log.Fatal exits, and fails
+// unless the child exits with an error.
+func expectFatal(t *testing.T, name string, fn func()) {
+	if env := os.Getenv("GMAT_FATAL"); env != "" {
+		if env == name {
+			fn()
+			os.Exit(0)
+		}
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$")
+	cmd.Env = append(os.Environ(), "GMAT_FATAL="+name)
+	if cmd.Run() == nil {
+		t.Fatal("failed Test!", name)
+	}
+}
+
+func TestSchedulerCheckSuccess(t *testing.T) {
+	opt := NewSGD(nil, Config{LR: 1})
+	expectFatal(t, "step", func() { (&StepDecay{Gamma: 0.5}).Step(opt) })
+	expectFatal(t, "cosine", func() { (&CosineWarmRestarts{}).Step(opt) })
+	expectFatal(t, "onecycle", func() {
+		s := NewOneCycle(1, 10)
+		s.PctStart = 1
+		s.Step(opt)
+	})
+	expectFatal(t, "plateau", func() { NewReduceOnPlateau(0, 1) })
+}
+
+func TestSchedulerStateSuccess(t *testing.T) {
+	p := newParam()
+	opt := NewSGD([]*autograd.Variable{p}, Config{LR: 1, Momentum: 0.5})
+	opt.Scheduler = NewLinearWarmup(2, 0.5, NewCosineWarmRestarts(3, 1, 0.01))
+	for i := 0; i < 4; i++ {
+		p.Grad = gmat.OnesLike(p.Value)
+		opt.Step()
+	}
+	var buf bytes.Buffer
+	if err := opt.Save(&buf); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	q := newParam()
+	restored := NewSGD([]*autograd.Variable{q}, Config{})
+	restored.Scheduler = NewLinearWarmup(2, 0.5, NewCosineWarmRestarts(3, 1, 0.01))
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	for i := 0; i < 3; i++ {
+		p.Grad = gmat.OnesLike(p.Value)
+		opt.Step()
+		restored.Step()
+		if restored.LR != opt.LR {
+			t.Fatal("failed Test!")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package optimizer
+
+import (
+	"encoding/gob"
+	"github.com/kuroko1t/gmat"
+	"io"
+	"log"
+)
+
+// TensorState is a backend independent copy of a tensor.
+type TensorState struct {
+	Shape []int
+	Data  []float64
+}
+
+// State is everything needed to resume an optimizer. Parameter values are
+// not part of it; save them with the model.
+type State struct {
+	Kind   string
+	Config Config
+	Steps  int
+	Slots  map[string][]TensorState
+	// Scheduler is the state of the attached scheduler, if any.
+	Scheduler *SchedulerState
+}
+
+func NewTensorState(x gmat.Tensor) TensorState {
+	if len(x.Shape) == 0 {
+		return TensorState{}
+	}
+	return TensorState{Shape: append([]int{}, x.Shape...), Data: gmat.ToSlice(x)}
+}
+
+func (s TensorState) Tensor() gmat.Tensor {
+	if len(s.Shape) == 0 {
+		return gmat.Tensor{}
+	}
+	return gmat.FromSlice(s.Data, s.Shape...)
+}
+
+func (o *Optimizer) State() State {
+	state := State{Kind: o.Kind, Config: o.Config, Steps: o.Steps, Slots: map[string][]TensorState{}}
+	for name, slot := range o.slots {
+		for _, x := range slot {
+			state.Slots[name] = append(state.Slots[name], NewTensorState(x))
+		}
+	}
+	if o.Scheduler != nil {
+		scheduler := o.Scheduler.State()
+		state.Scheduler = &scheduler
+	}
+	return state
+}
+
+func (o *Optimizer) SetState(state State) {
+	if state.Kind != o.Kind {
+		log.Fatal("optimizer.SetState kind mismatch " + state.Kind + " " + o.Kind)
+	}
+	o.Config = state.Config
+	o.Steps = state.Steps
+	o.slots = map[string][]gmat.Tensor{}
+	for name, slot := range state.Slots {
+		if len(slot) != len(o.Params) {
+			log.Fatal("optimizer.SetState parameter count mismatch")
+		}
+		o.slots[name] = make([]gmat.Tensor, len(slot))
+		for i, s := range slot {
+			o.slots[name][i] = s.Tensor()
+		}
+	}
+	if o.Scheduler != nil && state.Scheduler != nil {
+		o.Scheduler.SetState(*state.Scheduler)
+	}
+}
+
+func (o *Optimizer) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(o.State())
+}
+
+func (o *Optimizer) Load(r io.Reader) error {
+	var state State
+	if err := gob.NewDecoder(r).Decode(&state); err != nil {
+		return err
+	}
+	o.SetState(state)
+	return nil
+}
@@ -0,0 +1,233 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package trainer
+
+import (
+	"fmt"
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/nn"
+	"github.com/kuroko1t/gmat/optimizer"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+type Callback interface {
+	OnEpochBegin(t *Trainer, epoch int)
+	OnBatchEnd(t *Trainer, batch int, logs Logs)
+	OnEpochEnd(t *Trainer, epoch int, logs Logs)
+}
+
+// Funcs turns optional functions into a Callback.
+type Funcs struct {
+	EpochBegin func(t *Trainer, epoch int)
+	BatchEnd   func(t *Trainer, batch int, logs Logs)
+	EpochEnd   func(t *Trainer, epoch int, logs Logs)
+}
+
+func (f Funcs) OnEpochBegin(t *Trainer, epoch int) {
+	if f.EpochBegin != nil {
+		f.EpochBegin(t, epoch)
+	}
+}
+
+func (f Funcs) OnBatchEnd(t *Trainer, batch int, logs Logs) {
+	if f.BatchEnd != nil {
+		f.BatchEnd(t, batch, logs)
+	}
+}
+
+func (f Funcs) OnEpochEnd(t *Trainer, epoch int, logs Logs) {
+	if f.EpochEnd != nil {
+		f.EpochEnd(t, epoch, logs)
+	}
+}
+
+func (l Logs) String() string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = fmt.Sprintf("%s=%.6g", k, l[k])
+	}
+	return strings.Join(fields, " ")
+}
+
+// Logger writes the logs of every epoch, and of every Every batches when
+// Every > 0.
+type Logger struct {
+	W     io.Writer
+	Every int
+}
+
+func (l *Logger) OnEpochBegin(t *Trainer, epoch int) {}
+
+func (l *Logger) OnBatchEnd(t *Trainer, batch int, logs Logs) {
+	if l.Every > 0 && (batch+1)%l.Every == 0 {
+		fmt.Fprintf(l.W, "epoch %d batch %d %v\n", t.Epoch+1, batch+1, logs)
+	}
+}
+
+func (l *Logger) OnEpochEnd(t *Trainer, epoch int, logs Logs) {
+	fmt.Fprintf(l.W, "epoch %d %v\n", epoch+1, logs)
+}
+
+// monitor tracks the best value of one log entry.
+type monitor struct {
+	Monitor string
+	// Max is set when larger values are better.
+	Max      bool
+	MinDelta float64
+	best     float64
+	seen     bool
+}
+
+// improved reports whether the monitored value of logs is a new best.
+func (m *monitor) improved(logs Logs) bool {
+	v, ok := logs[m.Monitor]
+	if !ok {
+		log.Fatal("trainer.missing monitored log " + m.Monitor)
+	}
+	better := !m.seen || m.Max && v > m.best+m.MinDelta || !m.Max && v < m.best-m.MinDelta
+	if better {
+		m.best = v
+		m.seen = true
+	}
+	return better
+}
+
+// EarlyStopping stops training after Patience epochs without improvement
+// of Monitor and can restore the parameters and buffers of the best epoch.
+type EarlyStopping struct {
+	monitor
+	Patience     int
+	RestoreBest  bool
+	StoppedEpoch int
+	wait         int
+	bestParams   []gmat.Tensor
+	bestBuffers  []gmat.Tensor
+}
+
+func NewEarlyStopping(monitorName string, max bool, patience int) *EarlyStopping {
+	return &EarlyStopping{monitor: monitor{Monitor: monitorName, Max: max}, Patience: patience, StoppedEpoch: -1}
+}
+
+func (e *EarlyStopping) OnEpochBegin(t *Trainer, epoch int) {}
+
+func (e *EarlyStopping) OnBatchEnd(t *Trainer, batch int, logs Logs) {}
+
+func (e *EarlyStopping) OnEpochEnd(t *Trainer, epoch int, logs Logs) {
+	if e.improved(logs) {
+		e.wait = 0
+		if e.RestoreBest {
+			// parameters and buffers are replaced, not modified, by
+			// training
+			e.bestParams, e.bestBuffers = e.bestParams[:0], e.bestBuffers[:0]
+			for _, p := range t.Model.Params() {
+				e.bestParams = append(e.bestParams, p.Value)
+			}
+			for _, b := range nn.Buffers(t.Model) {
+				e.bestBuffers = append(e.bestBuffers, *b)
+			}
+		}
+		return
+	}
+	e.wait++
+	if e.wait > e.Patience {
+		t.Stop = true
+		e.StoppedEpoch = epoch
+		if e.RestoreBest && e.bestParams != nil {
+			for i, p := range t.Model.Params() {
+				p.Value = e.bestParams[i]
+			}
+			for i, b := range nn.Buffers(t.Model) {
+				*b = e.bestBuffers[i]
+			}
+		}
+	}
+}
+
+// Checkpoint saves the model and optimizer after every epoch, or only
+// after improvements of Monitor with BestOnly. Path may contain %d for
+// the epoch number. A failed save does not stop training; Err keeps the
+// first error.
+type Checkpoint struct {
+	monitor
+	Path     string
+	BestOnly bool
+	Err      error
+}
+
+func NewCheckpoint(path string) *Checkpoint {
+	return &Checkpoint{Path: path}
+}
+
+// NewBestCheckpoint saves only when monitorName improves.
+func NewBestCheckpoint(path, monitorName string, max bool) *Checkpoint {
+	return &Checkpoint{monitor: monitor{Monitor: monitorName, Max: max}, Path: path, BestOnly: true}
+}
+
+func (c *Checkpoint) OnEpochBegin(t *Trainer, epoch int) {}
+
+func (c *Checkpoint) OnBatchEnd(t *Trainer, batch int, logs Logs) {}
+
+func (c *Checkpoint) OnEpochEnd(t *Trainer, epoch int, logs Logs) {
+	if c.BestOnly && !c.improved(logs) {
+		return
+	}
+	path := c.Path
+	if strings.Contains(path, "%d") {
+		path = fmt.Sprintf(path, epoch+1)
+	}
+	f, err := os.Create(path)
+	if err == nil {
+		err = Save(f, t)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil && c.Err == nil {
+		c.Err = err
+	}
+}
+
+// LRScheduler steps Scheduler once per epoch before training it. A
+// ReduceOnPlateau scheduler observes Monitor at the end of every epoch.
+type LRScheduler struct {
+	Scheduler optimizer.Scheduler
+	Monitor   string
+}
+
+func (s *LRScheduler) OnEpochBegin(t *Trainer, epoch int) {
+	s.Scheduler.Step(t.Optimizer)
+}
+
+func (s *LRScheduler) OnBatchEnd(t *Trainer, batch int, logs Logs) {}
+
+func (s *LRScheduler) OnEpochEnd(t *Trainer, epoch int, logs Logs) {
+	if plateau, ok := s.Scheduler.(*optimizer.ReduceOnPlateau); ok {
+		v, ok := logs[s.Monitor]
+		if !ok {
+			log.Fatal("LRScheduler.missing monitored log " + s.Monitor)
+		}
+		plateau.Observe(v)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package trainer
+
+import (
+	"encoding/gob"
+	"fmt"
+	"github.com/kuroko1t/gmat/nn"
+	"github.com/kuroko1t/gmat/optimizer"
+	"io"
+)
+
+// checkpoint is the saved form of a Trainer.
+type checkpoint struct {
+	Epoch      int
+	Params     []optimizer.TensorState
+	Buffers    []optimizer.TensorState
+	Optimizer  optimizer.State
+	Schedulers []optimizer.SchedulerState
+	History    []Logs
+}
+
+// Save writes the model parameters and buffers, the optimizer state, the
+// state of every LRScheduler callback and the epoch counter of t.
+func Save(w io.Writer, t *Trainer) error {
+	c := checkpoint{Epoch: t.Epoch, Optimizer: t.Optimizer.State(), History: t.History}
+	for _, p := range t.Model.Params() {
+		c.Params = append(c.Params, optimizer.NewTensorState(p.Value))
+	}
+	for _, b := range nn.Buffers(t.Model) {
+		c.Buffers = append(c.Buffers, optimizer.NewTensorState(*b))
+	}
+	for _, s := range schedulers(t) {
+		c.Schedulers = append(c.Schedulers, s.Scheduler.State())
+	}
+	return gob.NewEncoder(w).Encode(c)
+}
+
+// Load restores what Save wrote into a Trainer with the same model layout
+// and LRScheduler callbacks. A checkpoint of another layout is an error and
+// leaves t unchanged.
+func Load(r io.Reader, t *Trainer) error {
+	var c checkpoint
+	if err := gob.NewDecoder(r).Decode(&c); err != nil {
+		return err
+	}
+	params, buffers, lrs := t.Model.Params(), nn.Buffers(t.Model), schedulers(t)
+	switch {
+	case len(params) != len(c.Params):
+		return fmt.Errorf("trainer.Load: model has %d parameters, checkpoint %d", len(params), len(c.Params))
+	case len(buffers) != len(c.Buffers):
+		return fmt.Errorf("trainer.Load: model has %d buffers, checkpoint %d", len(buffers), len(c.Buffers))
+	case len(lrs) != len(c.Schedulers):
+		return fmt.Errorf("trainer.Load: trainer has %d schedulers, checkpoint %d", len(lrs), len(c.Schedulers))
+	case c.Optimizer.Kind != t.Optimizer.Kind:
+		return fmt.Errorf("trainer.Load: optimizer is %s, checkpoint %s", t.Optimizer.Kind, c.Optimizer.Kind)
+	}
+	for _, slot := range c.Optimizer.Slots {
+		if len(slot) != len(t.Optimizer.Params) {
+			return fmt.Errorf("trainer.Load: optimizer has %d parameters, checkpoint %d", len(t.Optimizer.Params), len(slot))
+		}
+	}
+	for i, p := range params {
+		p.Value = c.Params[i].Tensor()
+	}
+	for i, b := range buffers {
+		*b = c.Buffers[i].Tensor()
+	}
+	t.Optimizer.SetState(c.Optimizer)
+	for i, s := range lrs {
+		s.Scheduler.SetState(c.Schedulers[i])
+	}
+	t.Epoch = c.Epoch
+	t.History = c.History
+	return nil
+}
+
+// schedulers returns the LRScheduler callbacks of t in order.
+func schedulers(t *Trainer) []*LRScheduler {
+	var lrs []*LRScheduler
+	for _, c := range t.Callbacks {
+		if s, ok := c.(*LRScheduler); ok {
+			lrs = append(lrs, s)
+		}
+	}
+	return lrs
+}
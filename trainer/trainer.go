@@ -0,0 +1,190 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package trainer runs the epoch and batch loop of an nn model with an
+// optimizer, running metrics, evaluation passes and callbacks.
+package trainer
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/nn"
+	"github.com/kuroko1t/gmat/optimizer"
+	"log"
+)
+
+// Batch is one minibatch of inputs and targets.
+type Batch struct {
+	X gmat.Tensor
+	Y gmat.Tensor
+}
+
+// Iterator yields the batches of one epoch; Reset starts the next epoch.
+type Iterator interface {
+	Next() (Batch, bool)
+	Reset()
+}
+
+// Batches iterates over a fixed list of batches.
+type Batches struct {
+	List []Batch
+	pos  int
+}
+
+func NewBatches(list ...Batch) *Batches {
+	return &Batches{List: list}
+}
+
+func (b *Batches) Next() (Batch, bool) {
+	if b.pos >= len(b.List) {
+		return Batch{}, false
+	}
+	b.pos++
+	return b.List[b.pos-1], true
+}
+
+func (b *Batches) Reset() {
+	b.pos = 0
+}
+
+// LossFunc returns the [1, 1] loss of a batch and its gradient with respect
+// to pred, like the functions of package loss.
+type LossFunc func(pred, target gmat.Tensor) (gmat.Tensor, gmat.Tensor)
+
+// Metric is averaged over the samples of an epoch.
+type Metric struct {
+	Name string
+	Func func(pred, target gmat.Tensor) float64
+}
+
+// Logs maps "loss", metric names and their "val_" counterparts, and "lr"
+// to values.
+type Logs map[string]float64
+
+type Trainer struct {
+	Model     nn.Layer
+	Loss      LossFunc
+	Optimizer *optimizer.Optimizer
+	Metrics   []Metric
+	Callbacks []Callback
+	// Stop ends Fit after the current epoch; callbacks set it.
+	Stop bool
+	// Epoch counts the finished epochs; History has their logs.
+	Epoch   int
+	History []Logs
+}
+
+func New(model nn.Layer, loss LossFunc, opt *optimizer.Optimizer) *Trainer {
+	return &Trainer{Model: model, Loss: loss, Optimizer: opt}
+}
+
+// running keeps sample weighted means of the loss and metrics.
+type running struct {
+	sums  Logs
+	count float64
+}
+
+func (r *running) add(t *Trainer, loss float64, pred, target gmat.Tensor) {
+	if r.sums == nil {
+		r.sums = Logs{}
+	}
+	n := float64(pred.Shape[0])
+	r.count += n
+	r.sums["loss"] += loss * n
+	for _, m := range t.Metrics {
+		r.sums[m.Name] += m.Func(pred, target) * n
+	}
+}
+
+func (r *running) logs(prefix string) Logs {
+	logs := Logs{}
+	for k, v := range r.sums {
+		logs[prefix+k] = v / r.count
+	}
+	return logs
+}
+
+// TrainEpoch runs one pass over data in training mode.
+func (t *Trainer) TrainEpoch(data Iterator) Logs {
+	nn.Train(t.Model)
+	data.Reset()
+	r := running{}
+	for i := 0; ; i++ {
+		batch, ok := data.Next()
+		if !ok {
+			break
+		}
+		t.Optimizer.ZeroGrad()
+		pred := t.Model.Forward(batch.X)
+		loss, grad := t.Loss(pred, batch.Y)
+		t.Model.Backward(grad)
+		t.Optimizer.Step()
+		r.add(t, gmat.Sum(loss), pred, batch.Y)
+		logs := r.logs("")
+		logs["lr"] = t.Optimizer.LR
+		for _, c := range t.Callbacks {
+			c.OnBatchEnd(t, i, logs)
+		}
+	}
+	if r.count == 0 {
+		log.Fatal("Trainer.TrainEpoch no batches")
+	}
+	logs := r.logs("")
+	logs["lr"] = t.Optimizer.LR
+	return logs
+}
+
+// Evaluate runs data through the model in eval mode and returns the logs
+// with keys prefixed by "val_". The model is left in training mode.
+func (t *Trainer) Evaluate(data Iterator) Logs {
+	nn.Eval(t.Model)
+	defer nn.Train(t.Model)
+	data.Reset()
+	r := running{}
+	for {
+		batch, ok := data.Next()
+		if !ok {
+			break
+		}
+		pred := t.Model.Forward(batch.X)
+		loss, _ := t.Loss(pred, batch.Y)
+		r.add(t, gmat.Sum(loss), pred, batch.Y)
+	}
+	return r.logs("val_")
+}
+
+// Fit trains for epochs more epochs, evaluating on val after each one when
+// val is not nil, and returns the logs of every epoch.
+func (t *Trainer) Fit(train, val Iterator, epochs int) []Logs {
+	t.Stop = false
+	for e := 0; e < epochs && !t.Stop; e++ {
+		for _, c := range t.Callbacks {
+			c.OnEpochBegin(t, t.Epoch)
+		}
+		logs := t.TrainEpoch(train)
+		if val != nil {
+			for k, v := range t.Evaluate(val) {
+				logs[k] = v
+			}
+		}
+		// count the epoch first so that checkpoints resume after it
+		epoch := t.Epoch
+		t.History = append(t.History, logs)
+		t.Epoch++
+		for _, c := range t.Callbacks {
+			c.OnEpochEnd(t, epoch, logs)
+		}
+	}
+	return t.History
+}
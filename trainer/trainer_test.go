@@ -0,0 +1,220 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package trainer
+
+import (
+	"bytes"
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/loss"
+	"github.com/kuroko1t/gmat/nn"
+	"github.com/kuroko1t/gmat/optimizer"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mse(pred, target gmat.Tensor) (gmat.Tensor, gmat.Tensor) {
+	return loss.MSE(pred, target, loss.Options{})
+}
+
+// regression returns batches of y = x w with w = [1, -2, 3]^T.
+func regression(n int) *Batches {
+	w := gmat.FromSlice([]float64{1, -2, 3}, 3, 1)
+	batches := NewBatches()
+	for i := 0; i < n; i++ {
+		x := gmat.Normal(0, 1, 8, 3)
+		batches.List = append(batches.List, Batch{X: x, Y: gmat.Dot(x, w)})
+	}
+	return batches
+}
+
+func newTrainer(lr float64) *Trainer {
+	model := nn.NewSequential(nn.NewDense(3, 1, true))
+	return New(model, mse, optimizer.NewSGD(model.Params(), optimizer.Config{LR: lr}))
+}
+
+func TestFitSuccess(t *testing.T) {
+	tr := newTrainer(0.1)
+	tr.Metrics = []Metric{{Name: "mae", Func: func(pred, target gmat.Tensor) float64 {
+		l, _ := loss.MAE(pred, target, loss.Options{})
+		return gmat.Sum(l)
+	}}}
+	var out bytes.Buffer
+	tr.Callbacks = []Callback{&Logger{W: &out, Every: 2}}
+	history := tr.Fit(regression(4), regression(2), 30)
+	if len(history) != 30 || tr.Epoch != 30 {
+		t.Fatal("failed Test!")
+	}
+	last := history[29]
+	if last["loss"] > 1e-4 || last["val_loss"] > 1e-4 || last["val_mae"] > 1e-2 || history[0]["loss"] < last["loss"] {
+		t.Fatal("failed Test!", last)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 90 || !strings.HasPrefix(lines[2], "epoch 1 loss=") || !strings.Contains(lines[2], "val_loss=") {
+		t.Fatal("failed Test!", lines[:3])
+	}
+}
+
+func TestEarlyStoppingSuccess(t *testing.T) {
+	tr := newTrainer(0)
+	stop := NewEarlyStopping("val_loss", false, 2)
+	stop.RestoreBest = true
+	tr.Callbacks = []Callback{stop}
+	history := tr.Fit(regression(2), regression(1), 10)
+	// the first epoch is the best one, the next three do not improve
+	if len(history) != 4 || stop.StoppedEpoch != 3 {
+		t.Fatal("failed Test!", len(history))
+	}
+}
+
+func TestBuffersSuccess(t *testing.T) {
+	newModel := func() *nn.Sequential {
+		return nn.NewSequential(nn.NewDense(3, 4, true), nn.NewBatchNorm1D(4, true), nn.NewDense(4, 1, true))
+	}
+	model := newModel()
+	tr := New(model, mse, optimizer.NewSGD(model.Params(), optimizer.Config{LR: 0}))
+	// a constant metric makes the first epoch the best one
+	tr.Metrics = []Metric{{Name: "flat", Func: func(pred, target gmat.Tensor) float64 { return 1 }}}
+	stop := NewEarlyStopping("flat", false, 1)
+	stop.RestoreBest = true
+	var best []float64
+	record := Funcs{EpochEnd: func(t *Trainer, epoch int, logs Logs) {
+		if epoch == 0 {
+			best = gmat.ToSlice(*nn.Buffers(t.Model)[0])
+		}
+	}}
+	tr.Callbacks = []Callback{record, stop}
+	tr.Fit(regression(2), nil, 5)
+	mean := gmat.ToSlice(*nn.Buffers(model)[0])
+	if stop.StoppedEpoch != 2 || !equal(mean, best) || equal(mean, make([]float64, 4)) {
+		t.Fatal("failed Test!", stop.StoppedEpoch)
+	}
+	// the running statistics are saved with the parameters
+	var b bytes.Buffer
+	if err := Save(&b, tr); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	restoredModel := newModel()
+	restored := New(restoredModel, mse, optimizer.NewSGD(restoredModel.Params(), optimizer.Config{LR: 0}))
+	if err := Load(&b, restored); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	// a checkpoint of another layout is an error, not an exit
+	var other bytes.Buffer
+	Save(&other, newTrainer(0))
+	if err := Load(&other, restored); err == nil {
+		t.Fatal("failed Test!")
+	}
+	buffers := nn.Buffers(restoredModel)
+	if len(buffers) != 2 || !equal(gmat.ToSlice(*buffers[0]), mean) || !equal(gmat.ToSlice(*buffers[1]), gmat.ToSlice(*nn.Buffers(model)[1])) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestEvaluateSuccess(t *testing.T) {
+	model := nn.NewSequential(nn.NewDense(3, 4, true), nn.NewDropout(0.5), nn.NewDense(4, 1, true))
+	tr := New(model, mse, optimizer.NewSGD(model.Params(), optimizer.Config{LR: 0.01}))
+	data := regression(2)
+	first, second := tr.Evaluate(data), tr.Evaluate(data)
+	if first["val_loss"] != second["val_loss"] {
+		t.Fatal("failed Test!")
+	}
+	// back in training mode dropout is active again
+	x := gmat.Ones(100, 3)
+	if equal(gmat.ToSlice(model.Forward(x)), gmat.ToSlice(model.Forward(x))) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestCheckpointSuccess(t *testing.T) {
+	train := regression(2)
+	tr := newTrainer(0.05)
+	tr.Optimizer.Momentum = 0.9
+	path := filepath.Join(t.TempDir(), "epoch%d.ckpt")
+	tr.Callbacks = []Callback{&LRScheduler{Scheduler: optimizer.NewStepDecay(1, 0.5)}, NewCheckpoint(path)}
+	tr.Fit(train, nil, 2)
+	f, err := os.Open(strings.Replace(path, "%d", "2", 1))
+	if err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	defer f.Close()
+	restored := newTrainer(0.05)
+	restored.Callbacks = []Callback{&LRScheduler{Scheduler: optimizer.NewStepDecay(1, 0.5)}}
+	if err := Load(f, restored); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	if restored.Epoch != 2 || len(restored.History) != 2 {
+		t.Fatal("failed Test!")
+	}
+	// a save that fails is recorded and training goes on
+	broken := NewCheckpoint(filepath.Join(t.TempDir(), "missing", "epoch%d.ckpt"))
+	other := newTrainer(0.05)
+	other.Callbacks = []Callback{broken}
+	if len(other.Fit(train, nil, 2)) != 2 || broken.Err == nil {
+		t.Fatal("failed Test!")
+	}
+	// both resume the step decay where the checkpoint left it
+	tr.Callbacks = tr.Callbacks[:1]
+	history := tr.Fit(train, nil, 2)
+	restoredHistory := restored.Fit(train, nil, 2)
+	for i, want := range []float64{0.05, 0.025, 0.0125, 0.00625} {
+		if history[i]["lr"] != want || restoredHistory[i]["lr"] != want {
+			t.Fatal("failed Test!", i, restoredHistory[i]["lr"])
+		}
+	}
+	for i, p := range tr.Model.Params() {
+		if !equal(gmat.ToSlice(p.Value), gmat.ToSlice(restored.Model.Params()[i].Value)) {
+			t.Fatal("failed Test!")
+		}
+	}
+}
+
+func TestLRSchedulerSuccess(t *testing.T) {
+	tr := newTrainer(0.1)
+	tr.Callbacks = []Callback{&LRScheduler{Scheduler: optimizer.NewStepDecay(1, 0.5)}}
+	history := tr.Fit(regression(1), nil, 3)
+	for i, want := range []float64{0.1, 0.05, 0.025} {
+		if history[i]["lr"] != want {
+			t.Fatal("failed Test!")
+		}
+	}
+	// a constant metric never improves after the first epoch
+	tr = newTrainer(0.1)
+	tr.Metrics = []Metric{{Name: "flat", Func: func(pred, target gmat.Tensor) float64 { return 1 }}}
+	tr.Callbacks = []Callback{&LRScheduler{Scheduler: optimizer.NewReduceOnPlateau(0.5, 0), Monitor: "flat"}}
+	history = tr.Fit(regression(1), nil, 4)
+	for i, want := range []float64{0.1, 0.1, 0.05, 0.025} {
+		if history[i]["lr"] != want {
+			t.Fatal("failed Test!", i, history[i]["lr"])
+		}
+	}
+}
+
+func equal(x, y []float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package vision
+
+import (
+	"github.com/kuroko1t/gmat"
+	"github.com/kuroko1t/gmat/cpu"
+	"log"
+	"math"
+	"math/rand"
+)
+
+// host copies the NCHW tensor x into nested slices.
+func host(x gmat.Tensor) [][][][]float64 {
+	dims(x.Shape, NCHW)
+	return cpu.Unflatten(gmat.ToSlice(x), x.Shape).CPU4D
+}
+
+func device(x [][][][]float64) gmat.Tensor {
+	n, c, h, w := cpu.Shape4D(x)
+	shape := []int{n, c, h, w}
+	return gmat.FromSliceNoCopy(cpu.Flatten(cpu.Tensor{CPU4D: x, Shape: shape}), shape...)
+}
+
+func plane(h, w int) [][]float64 {
+	z := make([][]float64, h)
+	for i := range z {
+		z[i] = make([]float64, w)
+	}
+	return z
+}
+
+// rot90 rotates a plane a quarter turn counter-clockwise.
+func rot90(p [][]float64) [][]float64 {
+	h, w := len(p), len(p[0])
+	z := plane(w, h)
+	for i := 0; i < w; i++ {
+		for j := 0; j < h; j++ {
+			z[i][j] = p[j][w-1-i]
+		}
+	}
+	return z
+}
+
+// Rot90 rotates every image of the NCHW batch x by k quarter turns
+// counter-clockwise.
+func Rot90(x gmat.Tensor, k int) gmat.Tensor {
+	z := host(x)
+	k = ((k % 4) + 4) % 4
+	for b := range z {
+		for c := range z[b] {
+			for i := 0; i < k; i++ {
+				z[b][c] = rot90(z[b][c])
+			}
+		}
+	}
+	return device(z)
+}
+
+// Resize scales the NCHW batch x to h x w by bilinear interpolation.
+func Resize(x gmat.Tensor, h, w int) gmat.Tensor {
+	return device(cpu.UpsampleBilinear(host(x), h, w, false))
+}
+
+// Augmenter draws independent random augmentations for every sample of an
+// NCHW batch from its own generator, so a seed reproduces them.
+type Augmenter struct {
+	rng *rand.Rand
+}
+
+func NewAugmenter(seed int64) *Augmenter {
+	return &Augmenter{rng: rand.New(rand.NewSource(seed))}
+}
+
+// RandomCrop zero-pads the images by padding on every side and cuts an
+// h x w window at a random position.
+func (a *Augmenter) RandomCrop(x gmat.Tensor, h, w, padding int) gmat.Tensor {
+	n, c, ih, iw := dims(x.Shape, NCHW)
+	if h > ih+2*padding || w > iw+2*padding {
+		log.Fatal("vision.RandomCrop: crop larger than the padded image")
+	}
+	src := host(x)
+	z := make([][][][]float64, n)
+	for b := range z {
+		top := a.rng.Intn(ih+2*padding-h+1) - padding
+		left := a.rng.Intn(iw+2*padding-w+1) - padding
+		z[b] = make([][][]float64, c)
+		for ch := range z[b] {
+			z[b][ch] = plane(h, w)
+			for y := 0; y < h; y++ {
+				sy := top + y
+				if sy < 0 || sy >= ih {
+					continue
+				}
+				for xx := 0; xx < w; xx++ {
+					if sx := left + xx; sx >= 0 && sx < iw {
+						z[b][ch][y][xx] = src[b][ch][sy][sx]
+					}
+				}
+			}
+		}
+	}
+	return device(z)
+}
+
+// HorizontalFlip mirrors every image left to right with probability p.
+func (a *Augmenter) HorizontalFlip(x gmat.Tensor, p float64) gmat.Tensor {
+	z := host(x)
+	for b := range z {
+		if a.rng.Float64() >= p {
+			continue
+		}
+		for _, ch := range z[b] {
+			for _, row := range ch {
+				for i, j := 0, len(row)-1; i < j; i, j = i+1, j-1 {
+					row[i], row[j] = row[j], row[i]
+				}
+			}
+		}
+	}
+	return device(z)
+}
+
+// VerticalFlip mirrors every image top to bottom with probability p.
+func (a *Augmenter) VerticalFlip(x gmat.Tensor, p float64) gmat.Tensor {
+	z := host(x)
+	for b := range z {
+		if a.rng.Float64() >= p {
+			continue
+		}
+		for _, ch := range z[b] {
+			for i, j := 0, len(ch)-1; i < j; i, j = i+1, j-1 {
+				ch[i], ch[j] = ch[j], ch[i]
+			}
+		}
+	}
+	return device(z)
+}
+
+// RandomRot90 rotates every image by a random number of quarter turns.
+// The images must be square to keep the batch shape.
+func (a *Augmenter) RandomRot90(x gmat.Tensor) gmat.Tensor {
+	if _, _, h, w := dims(x.Shape, NCHW); h != w {
+		log.Fatal("vision.RandomRot90: images must be square")
+	}
+	z := host(x)
+	for b := range z {
+		k := a.rng.Intn(4)
+		for c := range z[b] {
+			for i := 0; i < k; i++ {
+				z[b][c] = rot90(z[b][c])
+			}
+		}
+	}
+	return device(z)
+}
+
+// factor draws uniformly from [max(0, 1-v), 1+v].
+func (a *Augmenter) factor(v float64) float64 {
+	low := math.Max(0, 1-v)
+	return low + a.rng.Float64()*(1+v-low)
+}
+
+// gray returns the luma of an RGB image and the channel itself otherwise.
+func gray(img [][][]float64) [][]float64 {
+	if len(img) != 3 {
+		return img[0]
+	}
+	h, w := len(img[0]), len(img[0][0])
+	z := plane(h, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			z[y][x] = 0.299*img[0][y][x] + 0.587*img[1][y][x] + 0.114*img[2][y][x]
+		}
+	}
+	return z
+}
+
+// ColorJitter scales brightness, contrast and then saturation of every
+// image by random factors around 1 and clips the result to [0, 1].
+// Saturation only applies to RGB images.
+func (a *Augmenter) ColorJitter(x gmat.Tensor, brightness, contrast, saturation float64) gmat.Tensor {
+	z := host(x)
+	for _, img := range z {
+		fb, fc, fs := a.factor(brightness), a.factor(contrast), a.factor(saturation)
+		blend := func(f float64, other func(c, y, x int) float64) {
+			for c := range img {
+				for y := range img[c] {
+					for xx := range img[c][y] {
+						v := other(c, y, xx) + f*(img[c][y][xx]-other(c, y, xx))
+						img[c][y][xx] = math.Min(math.Max(v, 0), 1)
+					}
+				}
+			}
+		}
+		blend(fb, func(c, y, x int) float64 { return 0 })
+		g := gray(img)
+		mean := 0.0
+		for _, row := range g {
+			for _, v := range row {
+				mean += v
+			}
+		}
+		mean /= float64(len(g) * len(g[0]))
+		blend(fc, func(c, y, x int) float64 { return mean })
+		if len(img) == 3 {
+			g = gray(img)
+			blend(fs, func(c, y, x int) float64 { return g[y][x] })
+		}
+	}
+	return device(z)
+}
+
+// Cutout zeroes a size x size square centred at a random pixel of every
+// image; the square is clipped at the borders.
+func (a *Augmenter) Cutout(x gmat.Tensor, size int) gmat.Tensor {
+	z := host(x)
+	_, _, h, w := dims(x.Shape, NCHW)
+	for _, img := range z {
+		cy, cx := a.rng.Intn(h), a.rng.Intn(w)
+		for y := cy - size/2; y < cy-size/2+size; y++ {
+			for xx := cx - size/2; xx < cx-size/2+size; xx++ {
+				if y < 0 || y >= h || xx < 0 || xx >= w {
+					continue
+				}
+				for c := range img {
+					img[c][y][xx] = 0
+				}
+			}
+		}
+	}
+	return device(z)
+}
@@ -0,0 +1,216 @@
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+// Package vision converts images to tensors and augments batches of them.
+// Pixel values are scaled to [0, 1].
+package vision
+
+import (
+	"github.com/kuroko1t/gmat"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Layout int
+
+const (
+	NCHW Layout = iota
+	NHWC
+)
+
+// dims returns n, c, h, w of a 4D tensor in the given layout.
+func dims(shape []int, layout Layout) (int, int, int, int) {
+	if len(shape) != 4 {
+		log.Fatal("vision.dims: tensor must be 4D")
+	}
+	if layout == NHWC {
+		return shape[0], shape[3], shape[1], shape[2]
+	}
+	return shape[0], shape[1], shape[2], shape[3]
+}
+
+// indexer returns the flat index of (n, c, y, x).
+func indexer(c, h, w int, layout Layout) func(n, ch, y, x int) int {
+	if layout == NHWC {
+		return func(n, ch, y, x int) int { return ((n*h+y)*w+x)*c + ch }
+	}
+	return func(n, ch, y, x int) int { return ((n*c+ch)*h+y)*w + x }
+}
+
+func shape(n, c, h, w int, layout Layout) []int {
+	if layout == NHWC {
+		return []int{n, h, w, c}
+	}
+	return []int{n, c, h, w}
+}
+
+// channels is 1 for grayscale images and 3 otherwise; alpha is dropped.
+func channels(img image.Image) int {
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		return 1
+	}
+	return 3
+}
+
+// FromImage returns img as a batch of one, [1, C, H, W] or [1, H, W, C].
+func FromImage(img image.Image, layout Layout) gmat.Tensor {
+	return FromImages([]image.Image{img}, layout)
+}
+
+// FromImages stacks images of the same size and kind.
+func FromImages(imgs []image.Image, layout Layout) gmat.Tensor {
+	b := imgs[0].Bounds()
+	c, h, w := channels(imgs[0]), b.Dy(), b.Dx()
+	data := make([]float64, len(imgs)*c*h*w)
+	index := indexer(c, h, w, layout)
+	for n, img := range imgs {
+		b := img.Bounds()
+		if b.Dx() != w || b.Dy() != h || channels(img) != c {
+			log.Fatal("vision.FromImages: images differ in size or channels")
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				p := img.At(b.Min.X+x, b.Min.Y+y)
+				if c == 1 {
+					data[index(n, 0, y, x)] = float64(color.Gray16Model.Convert(p).(color.Gray16).Y) / 0xffff
+					continue
+				}
+				q := color.NRGBA64Model.Convert(p).(color.NRGBA64)
+				data[index(n, 0, y, x)] = float64(q.R) / 0xffff
+				data[index(n, 1, y, x)] = float64(q.G) / 0xffff
+				data[index(n, 2, y, x)] = float64(q.B) / 0xffff
+			}
+		}
+	}
+	return gmat.FromSliceNoCopy(data, shape(len(imgs), c, h, w, layout)...)
+}
+
+func byte8(v float64) uint8 {
+	return uint8(math.Round(math.Min(math.Max(v, 0), 1) * 255))
+}
+
+// ToImage returns sample i of x as a Gray image for 1 channel and an
+// NRGBA image for 3 or 4 channels, clipping values to [0, 1].
+func ToImage(x gmat.Tensor, i int, layout Layout) image.Image {
+	_, c, h, w := dims(x.Shape, layout)
+	data := gmat.ToSlice(x)
+	index := indexer(c, h, w, layout)
+	rect := image.Rect(0, 0, w, h)
+	switch c {
+	case 1:
+		img := image.NewGray(rect)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				img.SetGray(x, y, color.Gray{Y: byte8(data[index(i, 0, y, x)])})
+			}
+		}
+		return img
+	case 3, 4:
+		img := image.NewNRGBA(rect)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				p := color.NRGBA{byte8(data[index(i, 0, y, x)]), byte8(data[index(i, 1, y, x)]), byte8(data[index(i, 2, y, x)]), 255}
+				if c == 4 {
+					p.A = byte8(data[index(i, 3, y, x)])
+				}
+				img.SetNRGBA(x, y, p)
+			}
+		}
+		return img
+	}
+	log.Fatal("vision.ToImage: tensor must have 1, 3 or 4 channels")
+	return nil
+}
+
+// Decode reads a PNG or JPEG image.
+func Decode(r io.Reader) (image.Image, error) {
+	img, _, err := image.Decode(r)
+	return img, err
+}
+
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Save writes img as JPEG when path ends in .jpg or .jpeg and as PNG
+// otherwise.
+func Save(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: jpeg.DefaultQuality})
+	default:
+		err = png.Encode(f, img)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Normalize returns (x - mean[c]) / std[c] for every channel c.
+func Normalize(x gmat.Tensor, mean, std []float64, layout Layout) gmat.Tensor {
+	return perChannel(x, layout, mean, std, func(v, m, s float64) float64 { return (v - m) / s })
+}
+
+// Denormalize inverts Normalize.
+func Denormalize(x gmat.Tensor, mean, std []float64, layout Layout) gmat.Tensor {
+	return perChannel(x, layout, mean, std, func(v, m, s float64) float64 { return v*s + m })
+}
+
+func perChannel(x gmat.Tensor, layout Layout, mean, std []float64, f func(v, m, s float64) float64) gmat.Tensor {
+	n, c, h, w := dims(x.Shape, layout)
+	if len(mean) != c || len(std) != c {
+		log.Fatal("vision.perChannel: mean and std need one value per channel")
+	}
+	data := gmat.ToSlice(x)
+	index := indexer(c, h, w, layout)
+	for b := 0; b < n; b++ {
+		for ch := 0; ch < c; ch++ {
+			for y := 0; y < h; y++ {
+				for xx := 0; xx < w; xx++ {
+					i := index(b, ch, y, xx)
+					data[i] = f(data[i], mean[ch], std[ch])
+				}
+			}
+		}
+	}
+	return gmat.FromSliceNoCopy(data, x.Shape...)
+}
+
+func ToNHWC(x gmat.Tensor) gmat.Tensor {
+	return gmat.Permute(x, []int{0, 2, 3, 1})
+}
+
+func ToNCHW(x gmat.Tensor) gmat.Tensor {
+	return gmat.Permute(x, []int{0, 3, 1, 2})
+}
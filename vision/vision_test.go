@@ -0,0 +1,172 @@
+//go:build !gpu
+// +build !gpu
+
+// Copyright 2018 kurosawa. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// =============================================================================
+
+package vision
+
+import (
+	"github.com/kuroko1t/gmat"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func near(x, y []float64, tol float64) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if math.Abs(x[i]-y[i]) > tol {
+			return false
+		}
+	}
+	return true
+}
+
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(40 * x), uint8(100 * y), 255, 255})
+		}
+	}
+	return img
+}
+
+func TestImageSuccess(t *testing.T) {
+	img := testImage()
+	x := FromImage(img, NCHW)
+	if x.Shape[1] != 3 || x.Shape[2] != 2 || x.Shape[3] != 3 {
+		t.Fatal("failed Test!")
+	}
+	// red of pixel (2, 0), green of pixel (0, 1)
+	data := gmat.ToSlice(x)
+	if math.Abs(data[2]-80.0/255) > 1e-12 || math.Abs(data[6+3]-100.0/255) > 1e-12 {
+		t.Fatal("failed Test!")
+	}
+	nhwc := FromImage(img, NHWC)
+	if !near(gmat.ToSlice(nhwc), gmat.ToSlice(ToNHWC(x)), 0) || !near(gmat.ToSlice(ToNCHW(nhwc)), data, 0) {
+		t.Fatal("failed Test!")
+	}
+	back := ToImage(nhwc, 0, NHWC).(*image.NRGBA)
+	for i := range img.Pix {
+		if back.Pix[i] != img.Pix[i] {
+			t.Fatal("failed Test!")
+		}
+	}
+	g := image.NewGray(image.Rect(0, 0, 2, 2))
+	g.SetGray(1, 0, color.Gray{Y: 51})
+	gx := FromImage(g, NCHW)
+	if gx.Shape[1] != 1 || math.Abs(gmat.ToSlice(gx)[1]-0.2) > 1e-12 || ToImage(gx, 0, NCHW).(*image.Gray).Pix[1] != 51 {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestSaveLoadSuccess(t *testing.T) {
+	dir := t.TempDir()
+	x := FromImage(testImage(), NCHW)
+	if err := Save(filepath.Join(dir, "a.png"), testImage()); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	img, err := Load(filepath.Join(dir, "a.png"))
+	if err != nil || !near(gmat.ToSlice(FromImage(img, NCHW)), gmat.ToSlice(x), 0) {
+		t.Fatal("failed Test!", err)
+	}
+	if err := Save(filepath.Join(dir, "a.jpg"), testImage()); err != nil {
+		t.Fatal("failed Test!", err)
+	}
+	img, err = Load(filepath.Join(dir, "a.jpg"))
+	if err != nil || img.Bounds().Dx() != 3 || img.Bounds().Dy() != 2 {
+		t.Fatal("failed Test!", err)
+	}
+}
+
+func TestNormalizeSuccess(t *testing.T) {
+	x := FromImage(testImage(), NHWC)
+	mean, std := []float64{0.5, 0.4, 0.3}, []float64{0.2, 0.25, 0.5}
+	z := Normalize(x, mean, std, NHWC)
+	if math.Abs(gmat.ToSlice(z)[2]-(1-0.3)/0.5) > 1e-12 {
+		t.Fatal("failed Test!")
+	}
+	if !near(gmat.ToSlice(Denormalize(z, mean, std, NHWC)), gmat.ToSlice(x), 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestRot90Success(t *testing.T) {
+	x := gmat.FromSlice([]float64{1, 2, 3, 4, 5, 6}, 1, 1, 2, 3)
+	z := Rot90(x, 1)
+	if z.Shape[2] != 3 || z.Shape[3] != 2 || !near(gmat.ToSlice(z), []float64{3, 6, 2, 5, 1, 4}, 0) {
+		t.Fatal("failed Test!")
+	}
+	if !near(gmat.ToSlice(Rot90(x, -2)), gmat.ToSlice(Rot90(z, 1)), 0) || !near(gmat.ToSlice(Rot90(x, 4)), gmat.ToSlice(x), 0) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestResizeSuccess(t *testing.T) {
+	x := gmat.Uniform(0, 1, 2, 3, 4, 4)
+	if !near(gmat.ToSlice(Resize(x, 4, 4)), gmat.ToSlice(x), 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	z := Resize(gmat.FromSlice([]float64{0, 1, 0, 1}, 1, 1, 2, 2), 2, 4)
+	if !near(gmat.ToSlice(z)[:4], []float64{0, 0.25, 0.75, 1}, 1e-12) {
+		t.Fatal("failed Test!")
+	}
+}
+
+func TestAugmenterSuccess(t *testing.T) {
+	x := gmat.Uniform(0, 1, 8, 3, 6, 6)
+	a, b := NewAugmenter(3), NewAugmenter(3)
+	augment := func(a *Augmenter) []float64 {
+		z := a.RandomCrop(x, 5, 5, 2)
+		z = a.HorizontalFlip(z, 0.5)
+		z = a.VerticalFlip(z, 0.5)
+		z = a.RandomRot90(z)
+		z = a.ColorJitter(z, 0.4, 0.4, 0.4)
+		return gmat.ToSlice(a.Cutout(z, 2))
+	}
+	z := augment(a)
+	if !near(z, augment(b), 0) || near(z, augment(a), 0) || len(z) != 8*3*5*5 {
+		t.Fatal("failed Test!")
+	}
+	for _, v := range z {
+		if v < 0 || v > 1 {
+			t.Fatal("failed Test!")
+		}
+	}
+	// no-op settings keep the images
+	same := a.ColorJitter(a.VerticalFlip(a.HorizontalFlip(a.RandomCrop(x, 6, 6, 0), 0), 0), 0, 0, 0)
+	if !near(gmat.ToSlice(same), gmat.ToSlice(x), 1e-12) {
+		t.Fatal("failed Test!")
+	}
+	flipped := gmat.ToSlice(a.HorizontalFlip(gmat.FromSlice([]float64{1, 2, 3}, 1, 1, 1, 3), 1))
+	if !near(flipped, []float64{3, 2, 1}, 0) {
+		t.Fatal("failed Test!")
+	}
+	zeros := 0
+	for _, v := range gmat.ToSlice(a.Cutout(gmat.Ones(1, 2, 5, 5), 3)) {
+		if v == 0 {
+			zeros++
+		}
+	}
+	if zeros == 0 || zeros > 18 || zeros%2 != 0 {
+		t.Fatal("failed Test!")
+	}
+}